@@ -0,0 +1,116 @@
+package engine
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/config"
+)
+
+var (
+	streamAliasLock sync.RWMutex
+	rewriteRegexps  sync.Map // 改写规则 -> *regexp.Regexp
+)
+
+// compileRewrite 编译改写规则，规则需要完整匹配流路径
+func compileRewrite(pattern string) (*regexp.Regexp, error) {
+	if re, ok := rewriteRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+	rewriteRegexps.Store(pattern, re)
+	return re, nil
+}
+
+// resolveStreamPath 将别名或者符合改写规则的流路径解析为实际的流路径，别名优先，改写规则按字符串顺序依次匹配，只解析一次
+func resolveStreamPath(streamPath string) string {
+	streamAliasLock.RLock()
+	defer streamAliasLock.RUnlock()
+	if realPath, ok := EngineConfig.StreamAlias[streamPath]; ok {
+		return realPath
+	}
+	if len(EngineConfig.StreamRewrite) == 0 {
+		return streamPath
+	}
+	patterns := make([]string, 0, len(EngineConfig.StreamRewrite))
+	for pattern := range EngineConfig.StreamRewrite {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		re, err := compileRewrite(pattern)
+		if err != nil {
+			Engine.Error("stream rewrite", zap.String("pattern", pattern), zap.Error(err))
+			continue
+		}
+		if m := re.FindStringSubmatchIndex(streamPath); m != nil {
+			return string(re.ExpandString(nil, EngineConfig.StreamRewrite[pattern], streamPath, m))
+		}
+	}
+	return streamPath
+}
+
+// SetStreamAlias 添加或修改别名（regex为true时为正则改写规则），并保存配置
+func SetStreamAlias(alias string, streamPath string, regex bool) error {
+	streamAliasLock.Lock()
+	defer streamAliasLock.Unlock()
+	if regex {
+		if _, err := compileRewrite(alias); err != nil {
+			return err
+		}
+		if EngineConfig.StreamRewrite == nil {
+			EngineConfig.StreamRewrite = make(map[string]string)
+		}
+		EngineConfig.StreamRewrite[alias] = streamPath
+	} else {
+		if EngineConfig.StreamAlias == nil {
+			EngineConfig.StreamAlias = make(map[string]string)
+		}
+		EngineConfig.StreamAlias[alias] = streamPath
+	}
+	return saveStreamAlias()
+}
+
+// DeleteStreamAlias 删除别名（regex为true时为正则改写规则），并保存配置
+func DeleteStreamAlias(alias string, regex bool) (ok bool, err error) {
+	streamAliasLock.Lock()
+	defer streamAliasLock.Unlock()
+	m := EngineConfig.StreamAlias
+	if regex {
+		m = EngineConfig.StreamRewrite
+	}
+	if _, ok = m[alias]; ok {
+		delete(m, alias)
+		err = saveStreamAlias()
+	}
+	return
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	r := make(map[string]string, len(m))
+	for k, v := range m {
+		r[k] = v
+	}
+	return r
+}
+
+// StreamAliasList 返回当前的别名和改写规则
+func StreamAliasList() (alias map[string]string, rewrite map[string]string) {
+	streamAliasLock.RLock()
+	defer streamAliasLock.RUnlock()
+	return copyStringMap(EngineConfig.StreamAlias), copyStringMap(EngineConfig.StreamRewrite)
+}
+
+func saveStreamAlias() error {
+	if Engine.Modified == nil {
+		Engine.Modified = make(config.Config)
+	}
+	Engine.Modified["streamalias"] = copyStringMap(EngineConfig.StreamAlias)
+	Engine.Modified["streamrewrite"] = copyStringMap(EngineConfig.StreamRewrite)
+	return Engine.Save()
+}
@@ -0,0 +1,55 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestResolveStreamPath(t *testing.T) {
+	defer func(conf *GlobalConfig) { EngineConfig = conf }(EngineConfig)
+	EngineConfig = &GlobalConfig{}
+	EngineConfig.StreamAlias = map[string]string{
+		"pub/cam1":  "live/camera1",
+		"pub/other": "live/other",
+	}
+	EngineConfig.StreamRewrite = map[string]string{
+		`pub/(\w+)`:       "live/$1",
+		`old/(\w+)/(\w+)`: "$2/$1",
+	}
+	for _, c := range []struct{ streamPath, want string }{
+		{"pub/cam1", "live/camera1"}, // 别名优先于改写规则
+		{"pub/cam2", "live/cam2"},
+		{"pub/a/b", "pub/a/b"}, // 改写规则需要完整匹配
+		{"old/test/vod", "vod/test"},
+		{"live/camera1", "live/camera1"}, // 只解析一次
+	} {
+		if got := resolveStreamPath(c.streamPath); got != c.want {
+			t.Errorf("resolveStreamPath(%s) = %s, want %s", c.streamPath, got, c.want)
+		}
+	}
+}
+
+// 通过别名访问时，/api/sign 和 /api/token 针对的是公开的别名路径
+func TestAuthAliasPath(t *testing.T) {
+	io := &IO{Stream: &Stream{Path: "live/camera1"}, requestPath: "pub/cam1"}
+	expire := time.Now().Add(time.Hour)
+	for _, streamPath := range []string{"pub/cam1", "live/camera1"} {
+		secret, expireHex := SignMD5("key", streamPath, expire)
+		if _, err := io.authKey("key", "", secret, expireHex, JWT_ROLE_SUBSCRIBE); err != nil {
+			t.Errorf("md5 signed for %s: %v", streamPath, err)
+		}
+		token, _ := SignJWT(JWT_HS256, "key", "", &JWTClaims{Path: streamPath, Role: JWT_ROLE_SUBSCRIBE, Exp: expire.Unix()})
+		if _, err := io.authKey("key", "", token, "", JWT_ROLE_SUBSCRIBE); err != nil {
+			t.Errorf("token for %s: %v", streamPath, err)
+		}
+	}
+	secret, expireHex := SignMD5("key", "pub/other", expire)
+	if _, err := io.authKey("key", "", secret, expireHex, JWT_ROLE_SUBSCRIBE); err != ErrAuth {
+		t.Errorf("md5 signed for other alias = %v, want ErrAuth", err)
+	}
+	token, _ := SignJWT(JWT_HS256, "key", "", &JWTClaims{Path: "pub/other", Role: JWT_ROLE_SUBSCRIBE, Exp: expire.Unix()})
+	if _, err := io.authKey("key", "", token, "", JWT_ROLE_SUBSCRIBE); !errors.Is(err, ErrAuth) {
+		t.Errorf("token for other alias = %v, want ErrAuth", err)
+	}
+}
@@ -0,0 +1,274 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/config"
+	"m7s.live/engine/v4/log"
+	"m7s.live/engine/v4/util"
+)
+
+var (
+	ErrAuthUnavailable = errors.New("Auth Service Unavailable")
+	ErrSecretMissing   = errors.New("missing secret")
+	ErrExpireFormat    = errors.New("bad expire format")
+	ErrSecretExpired   = errors.New("expired")
+	ErrSecretDigest    = errors.New("bad digest")
+)
+
+// SignMD5 按默认鉴权方式签名，返回 MD5(key+StreamPath+expire) 和十六进制的 expire
+func SignMD5(key string, streamPath string, expire time.Time) (secret string, expireHex string) {
+	expireHex = strconv.FormatInt(expire.Unix(), 16)
+	sum := md5.Sum([]byte(key + streamPath + expireHex))
+	return hex.EncodeToString(sum[:]), expireHex
+}
+
+// CheckMD5 校验默认鉴权的签名，返回具体的失败原因
+func CheckMD5(key string, streamPath string, secret string, expire string) error {
+	if secret == "" {
+		return ErrSecretMissing
+	}
+	unixTime, err := strconv.ParseInt(expire, 16, 64)
+	if err != nil {
+		return ErrExpireFormat
+	}
+	if time.Now().Unix() > unixTime {
+		return ErrSecretExpired
+	}
+	sum := md5.Sum([]byte(key + streamPath + expire))
+	// 常量时间比较，避免通过响应时间逐字节猜测签名
+	if subtle.ConstantTimeCompare([]byte(strings.ToLower(secret)), []byte(hex.EncodeToString(sum[:]))) != 1 {
+		return ErrSecretDigest
+	}
+	return nil
+}
+
+// signConfig 签名和校验时使用的鉴权配置
+type signConfig struct {
+	Key           string
+	RSAKeyFile    string
+	SecretArgName string
+	ExpireArgName string
+	Role          string
+}
+
+// getSignConfig 获取处理请求的插件的鉴权配置，插件没有单独配置时使用全局配置，role 可以是 pub、sub、publish、subscribe
+func getSignConfig(pluginName string, role string) (sc signConfig, err error) {
+	var c any = EngineConfig
+	if pluginName != "" {
+		p, ok := Plugins[pluginName]
+		if !ok {
+			return sc, fmt.Errorf("no such plugin %s", pluginName)
+		}
+		c = p.Config
+	}
+	switch role {
+	case "pub", JWT_ROLE_PUBLISH:
+		pubConf, ok := c.(config.PublishConfig)
+		if !ok {
+			pubConf = EngineConfig
+		}
+		conf := pubConf.GetPublishConfig()
+		sc = signConfig{conf.Key, conf.RSAKeyFile, conf.SecretArgName, conf.ExpireArgName, JWT_ROLE_PUBLISH}
+	case "", "sub", JWT_ROLE_SUBSCRIBE:
+		subConf, ok := c.(config.SubscribeConfig)
+		if !ok {
+			subConf = EngineConfig
+		}
+		conf := subConf.GetSubscribeConfig()
+		sc = signConfig{conf.Key, conf.RSAKeyFile, conf.SecretArgName, conf.ExpireArgName, JWT_ROLE_SUBSCRIBE}
+	default:
+		err = errors.New("role must be pub or sub")
+	}
+	return
+}
+
+// signPathCandidates 签名不匹配时尝试的流路径，用于判断签名是否针对了错误的路径（如带了扩展名或者插件前缀）
+func signPathCandidates(streamPath string) (r []string) {
+	r = append(r, "/"+streamPath)
+	if ext := path.Ext(streamPath); ext != "" {
+		r = append(r, strings.TrimSuffix(streamPath, ext))
+	}
+	if _, rest, ok := strings.Cut(streamPath, "/"); ok {
+		r = append(r, rest)
+		if ext := path.Ext(rest); ext != "" {
+			r = append(r, strings.TrimSuffix(rest, ext))
+		}
+	}
+	return
+}
+
+// SignVerifyResult 校验签名的结果，Reason 说明被拒绝的原因
+type SignVerifyResult struct {
+	OK         bool
+	Reason     string `json:",omitempty"`
+	StreamPath string
+	SignedPath string     `json:",omitempty"` // 签名实际对应的流路径
+	Expire     *time.Time `json:",omitempty"`
+}
+
+// verifySign 按配置校验流路径和参数中的签名，支持md5签名和JWT
+func verifySign(sc signConfig, streamPath string, args url.Values) (res SignVerifyResult) {
+	res.StreamPath = streamPath
+	secret, expire := args.Get(sc.SecretArgName), args.Get(sc.ExpireArgName)
+	var err error
+	if IsJWT(secret) {
+		var claims *JWTClaims
+		if claims, err = ParseJWT(secret, sc.Key, sc.RSAKeyFile); err == nil {
+			t := time.Unix(claims.Exp, 0)
+			res.Expire = &t
+			err = claims.Check(streamPath, sc.Role)
+		}
+	} else if sc.Key == "" {
+		err = errors.New("no key configured")
+	} else {
+		if unixTime, e := strconv.ParseInt(expire, 16, 64); e == nil {
+			t := time.Unix(unixTime, 0)
+			res.Expire = &t
+		}
+		if err = CheckMD5(sc.Key, streamPath, secret, expire); err == ErrSecretDigest {
+			for _, candidate := range signPathCandidates(streamPath) {
+				if CheckMD5(sc.Key, candidate, secret, expire) == nil {
+					res.SignedPath = candidate
+					err = fmt.Errorf("wrong path: signed for %s", candidate)
+					break
+				}
+			}
+		}
+	}
+	if res.OK = err == nil; !res.OK {
+		res.Reason = err.Error()
+	}
+	return
+}
+
+// HTTPAuthRequest 发送给HTTP鉴权服务的JSON内容，服务返回2xx表示通过，其他4xx表示拒绝
+type HTTPAuthRequest struct {
+	Action     string // publish 或 subscribe
+	StreamPath string
+	Type       string
+	RemoteAddr string
+	Token      string // SecretArgName 对应的参数值，用作缓存的键
+	Args       url.Values
+}
+
+type httpAuthConfig struct {
+	URL      string
+	Timeout  time.Duration
+	CacheTTL time.Duration
+	FailOpen bool
+}
+
+type httpAuthResult struct {
+	err    error
+	expire time.Time
+}
+
+var httpAuthCache = util.Map[string, httpAuthResult]{Map: make(map[string]httpAuthResult)}
+
+func (io *IO) authRequest(action string, tokenArgName string) *HTTPAuthRequest {
+	return &HTTPAuthRequest{
+		Action:     action,
+		StreamPath: io.Stream.GetPath(),
+		Type:       io.Type,
+		RemoteAddr: io.remoteAddr(),
+		Token:      io.Args.Get(tokenArgName),
+		Args:       io.Args,
+	}
+}
+
+func (req *HTTPAuthRequest) cacheKey() string {
+	return req.Action + "|" + req.StreamPath + "|" + req.Token
+}
+
+// onHTTPAuth 生成与 OnAuthPub/OnAuthSub 相同签名的鉴权函数，由HTTP回调的结果决定Promise
+func onHTTPAuth[T any](conf httpAuthConfig, req *HTTPAuthRequest, logger *log.Logger) func(*util.Promise[T]) error {
+	return func(p *util.Promise[T]) error {
+		if err := conf.check(req, logger); err != nil {
+			p.Reject(err)
+		} else {
+			p.Resolve()
+		}
+		return nil
+	}
+}
+
+func (conf httpAuthConfig) check(req *HTTPAuthRequest, logger *log.Logger) (err error) {
+	cacheable := conf.CacheTTL > 0 && req.Token != ""
+	if cacheable {
+		httpAuthCache.RLock()
+		result, ok := httpAuthCache.Map[req.cacheKey()]
+		httpAuthCache.RUnlock()
+		if ok && time.Now().Before(result.expire) {
+			return result.err
+		}
+	}
+	definite, err := conf.request(req)
+	if !definite {
+		// 鉴权服务不可用，根据配置决定放行还是拒绝，结果不缓存
+		logger.Warn("http auth unavailable", zap.String("url", conf.URL), zap.Bool("failOpen", conf.FailOpen), zap.Error(err))
+		if conf.FailOpen {
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrAuthUnavailable, err)
+	}
+	if cacheable {
+		now := time.Now()
+		httpAuthCache.Lock()
+		for k, v := range httpAuthCache.Map {
+			if now.After(v.expire) {
+				delete(httpAuthCache.Map, k)
+			}
+		}
+		httpAuthCache.Map[req.cacheKey()] = httpAuthResult{err, now.Add(conf.CacheTTL)}
+		httpAuthCache.Unlock()
+	}
+	return
+}
+
+// request 发起鉴权请求，definite为false表示未得到鉴权服务的明确答复（网络错误、超时、5xx）
+func (conf httpAuthConfig) request(req *HTTPAuthRequest) (definite bool, err error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), conf.Timeout)
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, conf.URL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	r.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return true, nil
+	case res.StatusCode >= 500:
+		return false, fmt.Errorf("auth service status %d", res.StatusCode)
+	default:
+		reason, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		if len(reason) > 0 {
+			return true, fmt.Errorf("%w: %s", ErrAuth, reason)
+		}
+		return true, ErrAuth
+	}
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckMD5(t *testing.T) {
+	secret, expire := SignMD5("key", "live/test", time.Now().Add(time.Hour))
+	if err := CheckMD5("key", "live/test", secret, expire); err != nil {
+		t.Errorf("CheckMD5 = %v", err)
+	}
+	if err := CheckMD5("key", "live/test", strings.ToUpper(secret), expire); err != nil {
+		t.Errorf("CheckMD5 upper case = %v", err)
+	}
+	for _, c := range []struct {
+		name                            string
+		key, streamPath, secret, expire string
+		want                            error
+	}{
+		{"no secret", "key", "live/test", "", expire, ErrSecretMissing},
+		{"bad expire", "key", "live/test", secret, "xyz", ErrExpireFormat},
+		{"wrong key", "other", "live/test", secret, expire, ErrSecretDigest},
+		{"wrong path", "key", "live/test2", secret, expire, ErrSecretDigest},
+		{"tampered expire", "key", "live/test", secret, expire + "0", ErrSecretDigest},
+	} {
+		if err := CheckMD5(c.key, c.streamPath, c.secret, c.expire); err != c.want {
+			t.Errorf("%s: CheckMD5 = %v, want %v", c.name, err, c.want)
+		}
+	}
+	secret, expire = SignMD5("key", "live/test", time.Now().Add(-time.Minute))
+	if err := CheckMD5("key", "live/test", secret, expire); err != ErrSecretExpired {
+		t.Errorf("expired: CheckMD5 = %v, want ErrSecretExpired", err)
+	}
+}
+
+func TestVerifySign(t *testing.T) {
+	sc := signConfig{Key: "key", SecretArgName: "secret", ExpireArgName: "expire", Role: JWT_ROLE_SUBSCRIBE}
+	secret, expire := SignMD5("key", "live/test", time.Now().Add(time.Hour))
+	args := url.Values{"secret": {secret}, "expire": {expire}}
+	if res := verifySign(sc, "live/test", args); !res.OK || res.Expire == nil {
+		t.Errorf("verifySign = %+v", res)
+	}
+	// 签名针对的是带扩展名的路径
+	if res := verifySign(sc, "live/test.flv", args); res.OK || res.SignedPath != "live/test" {
+		t.Errorf("verifySign(live/test.flv) = %+v, want SignedPath live/test", res)
+	}
+	token, _ := SignJWT(JWT_HS256, "key", "", &JWTClaims{Path: "live/*", Role: JWT_ROLE_PUBLISH, Exp: time.Now().Add(time.Hour).Unix()})
+	if res := verifySign(sc, "live/test", url.Values{"secret": {token}}); res.OK || res.Reason != ErrJWTRole.Error() {
+		t.Errorf("verifySign(publish token) = %+v, want %v", res, ErrJWTRole)
+	}
+}
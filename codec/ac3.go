@@ -0,0 +1,82 @@
+package codec
+
+import "errors"
+
+var ErrAC3 = errors.New("ac-3 syncframe header error")
+
+// AC3Header AC-3（bsid<=8）和E-AC-3（bsid 11~16）同步帧头 ETSI TS 102 366
+type AC3Header struct {
+	EAC3        bool
+	BSID        byte
+	SampleRate  int
+	ACMod       byte // 声道模式
+	LFEOn       byte
+	Channels    byte // 包括LFE
+	FrameLength int  // 整个同步帧的字节数
+	Samples     int  // 每帧的采样数
+}
+
+var ac3SampleRates = [...]int{48000, 44100, 32000}
+var eac3SampleRates2 = [...]int{24000, 22050, 16000}
+var ac3Bitrates = [...]int{32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 448, 512, 576, 640}
+var ac3Channels = [...]byte{2, 1, 2, 3, 3, 4, 4, 5}
+
+// ParseAC3Header 解析同步帧开头的至少8个字节
+func ParseAC3Header(b []byte) (h AC3Header, err error) {
+	if len(b) < 8 || b[0] != 0x0B || b[1] != 0x77 {
+		return h, ErrAC3
+	}
+	// AC-3 和 E-AC-3 的 bsid 位置相同
+	switch h.BSID = b[5] >> 3; {
+	case h.BSID <= 8:
+		fscod, frmsizecod := b[4]>>6, int(b[4]&0x3F)
+		if fscod == 3 || frmsizecod >= 2*len(ac3Bitrates) {
+			return h, ErrAC3
+		}
+		h.SampleRate = ac3SampleRates[fscod]
+		bitrate := ac3Bitrates[frmsizecod>>1]
+		switch fscod {
+		case 0:
+			h.FrameLength = bitrate * 4
+		case 1:
+			h.FrameLength = (bitrate*320/147 + frmsizecod&1) * 2
+		case 2:
+			h.FrameLength = bitrate * 6
+		}
+		h.Samples = 1536
+		h.ACMod = b[6] >> 5
+		// cmixlev、surmixlev、dsurmod 之后是 lfeon
+		bit := 3
+		if h.ACMod&1 != 0 && h.ACMod != 1 {
+			bit += 2
+		}
+		if h.ACMod&4 != 0 {
+			bit += 2
+		}
+		if h.ACMod == 2 {
+			bit += 2
+		}
+		h.LFEOn = byte((uint16(b[6])<<8|uint16(b[7]))>>(15-bit)) & 1
+	case h.BSID > 10 && h.BSID <= 16:
+		h.EAC3 = true
+		h.FrameLength = (int(b[2]&0x07)<<8 | int(b[3]) + 1) * 2
+		fscod := b[4] >> 6
+		if fscod == 3 {
+			fscod2 := (b[4] >> 4) & 0b11
+			if fscod2 == 3 {
+				return h, ErrAC3
+			}
+			h.SampleRate = eac3SampleRates2[fscod2]
+			h.Samples = 1536
+		} else {
+			h.SampleRate = ac3SampleRates[fscod]
+			h.Samples = [...]int{1, 2, 3, 6}[(b[4]>>4)&0b11] * 256
+		}
+		h.ACMod = (b[4] >> 1) & 0b111
+		h.LFEOn = b[4] & 1
+	default:
+		return h, ErrAC3
+	}
+	h.Channels = ac3Channels[h.ACMod] + h.LFEOn
+	return
+}
@@ -0,0 +1,26 @@
+package codec
+
+import "testing"
+
+func TestParseAC3Header(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		b    []byte
+		want AC3Header
+		err  error
+	}{
+		{"5.1 448k", []byte{0x0b, 0x77, 0x00, 0x00, 0x1e, 0x40, 0xe1, 0x00}, AC3Header{BSID: 8, SampleRate: 48000, ACMod: 7, LFEOn: 1, Channels: 6, FrameLength: 1792, Samples: 1536}, nil},
+		{"stereo 192k", []byte{0x0b, 0x77, 0x00, 0x00, 0x14, 0x40, 0x43, 0x00}, AC3Header{BSID: 8, SampleRate: 48000, ACMod: 2, Channels: 2, FrameLength: 768, Samples: 1536}, nil},
+		{"44.1k 192k", []byte{0x0b, 0x77, 0x00, 0x00, 0x54, 0x40, 0x43, 0x00}, AC3Header{BSID: 8, SampleRate: 44100, ACMod: 2, Channels: 2, FrameLength: 834, Samples: 1536}, nil},
+		{"44.1k 192k padding", []byte{0x0b, 0x77, 0x00, 0x00, 0x55, 0x40, 0x43, 0x00}, AC3Header{BSID: 8, SampleRate: 44100, ACMod: 2, Channels: 2, FrameLength: 836, Samples: 1536}, nil},
+		{"e-ac-3 5.1", []byte{0x0b, 0x77, 0x01, 0x7f, 0x3f, 0x86, 0x00, 0x00}, AC3Header{EAC3: true, BSID: 16, SampleRate: 48000, ACMod: 7, LFEOn: 1, Channels: 6, FrameLength: 768, Samples: 1536}, nil},
+		{"e-ac-3 1 block", []byte{0x0b, 0x77, 0x00, 0x3f, 0x04, 0x80, 0x00, 0x00}, AC3Header{EAC3: true, BSID: 16, SampleRate: 48000, ACMod: 2, Channels: 2, FrameLength: 128, Samples: 256}, nil},
+		{"bad frmsizecod", []byte{0x0b, 0x77, 0x00, 0x00, 0x26, 0x40, 0x43, 0x00}, AC3Header{BSID: 8}, ErrAC3},
+		{"bad bsid", []byte{0x0b, 0x77, 0x00, 0x00, 0x14, 0x48, 0x43, 0x00}, AC3Header{BSID: 9}, ErrAC3},
+		{"bad sync", []byte{0x77, 0x0b, 0x00, 0x00, 0x14, 0x40, 0x43, 0x00}, AC3Header{}, ErrAC3},
+	} {
+		if h, err := ParseAC3Header(c.b); h != c.want || err != c.err {
+			t.Errorf("%s: ParseAC3Header = %+v, %v, want %+v, %v", c.name, h, err, c.want, c.err)
+		}
+	}
+}
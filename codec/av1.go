@@ -0,0 +1,338 @@
+package codec
+
+import (
+	"errors"
+
+	"m7s.live/engine/v4/util"
+)
+
+// https://aomediacodec.github.io/av1-spec/#obu-header-syntax
+type AV1OBUType byte
+
+const (
+	AV1_OBU_SEQUENCE_HEADER        AV1OBUType = 1
+	AV1_OBU_TEMPORAL_DELIMITER     AV1OBUType = 2
+	AV1_OBU_FRAME_HEADER           AV1OBUType = 3
+	AV1_OBU_TILE_GROUP             AV1OBUType = 4
+	AV1_OBU_METADATA               AV1OBUType = 5
+	AV1_OBU_FRAME                  AV1OBUType = 6
+	AV1_OBU_REDUNDANT_FRAME_HEADER AV1OBUType = 7
+	AV1_OBU_TILE_LIST              AV1OBUType = 8
+	AV1_OBU_PADDING                AV1OBUType = 15
+)
+
+const (
+	AV1_OBU_HAS_EXTENSION  = 0b0000_0100
+	AV1_OBU_HAS_SIZE_FIELD = 0b0000_0010
+)
+
+var ErrAV1 = errors.New("av1 parse error")
+var FourCC_AV1 = []byte{'a', 'v', '0', '1'}
+var FourCC_AV1_32 = util.BigEndian.Uint32(FourCC_AV1)
+
+func ParseAV1OBUType(b byte) AV1OBUType {
+	return AV1OBUType((b >> 3) & 0x0F)
+}
+
+// AV1OBUHeaderSize OBU头的长度，包含扩展头
+func AV1OBUHeaderSize(b byte) int {
+	if b&AV1_OBU_HAS_EXTENSION != 0 {
+		return 2
+	}
+	return 1
+}
+
+// ReadLEB128 读取leb128编码的整数，n为占用的字节数
+func ReadLEB128(data []byte) (value uint64, n int, err error) {
+	for n < 8 {
+		if n >= len(data) {
+			return 0, 0, ErrAV1
+		}
+		b := data[n]
+		value |= uint64(b&0x7f) << (n * 7)
+		n++
+		if b&0x80 == 0 {
+			return
+		}
+	}
+	return 0, 0, ErrAV1
+}
+
+// AppendLEB128 将整数以leb128编码追加到b之后
+func AppendLEB128(b []byte, value uint64) []byte {
+	for {
+		if value < 0x80 {
+			return append(b, byte(value))
+		}
+		b = append(b, byte(value&0x7f)|0x80)
+		value >>= 7
+	}
+}
+
+// SizeOfLEB128 整数以leb128编码后的字节数
+func SizeOfLEB128(value uint64) (n int) {
+	for n = 1; value >= 0x80; n++ {
+		value >>= 7
+	}
+	return
+}
+
+// ParseAV1OBUHeader 解析OBU头和长度字段，n为二者占用的字节数，没有长度字段时size为-1
+func ParseAV1OBUHeader(data []byte) (header []byte, size int, n int, err error) {
+	if len(data) == 0 {
+		return nil, 0, 0, ErrAV1
+	}
+	hl := AV1OBUHeaderSize(data[0])
+	if len(data) < hl {
+		return nil, 0, 0, ErrAV1
+	}
+	header = data[:hl]
+	if data[0]&AV1_OBU_HAS_SIZE_FIELD == 0 {
+		return header, -1, hl, nil
+	}
+	v, l, err := ReadLEB128(data[hl:])
+	if err != nil || v > uint64(len(data)) {
+		return nil, 0, 0, ErrAV1
+	}
+	return header, int(v), hl + l, nil
+}
+
+// ParseAV1OBU 解析data开头的一个OBU，没有长度字段时OBU占用剩余全部数据，n为该OBU占用的字节数
+func ParseAV1OBU(data []byte) (header []byte, payload []byte, n int, err error) {
+	header, size, n, err := ParseAV1OBUHeader(data)
+	if err != nil {
+		return
+	}
+	if size < 0 {
+		return header, data[n:], len(data), nil
+	}
+	if n+size > len(data) {
+		return nil, nil, 0, ErrAV1
+	}
+	return header, data[n : n+size], n + size, nil
+}
+
+// AV1SequenceHeader 序列头中构建av1C以及显示需要的字段
+type AV1SequenceHeader struct {
+	SeqProfile                byte
+	StillPicture              bool
+	ReducedStillPictureHeader bool
+	SeqLevelIdx0              byte
+	SeqTier0                  byte
+	HighBitdepth              bool
+	TwelveBit                 bool
+	Monochrome                bool
+	ChromaSubsamplingX        byte
+	ChromaSubsamplingY        byte
+	ChromaSamplePosition      byte
+	ColorPrimaries            byte
+	TransferCharacteristics   byte
+	MatrixCoefficients        byte
+	ColorRange                byte
+	BitDepth                  int
+	Width                     uint
+	Height                    uint
+}
+
+// ParseAV1SequenceHeader 解析序列头OBU的负载（不含OBU头）
+// https://aomediacodec.github.io/av1-spec/#sequence-header-obu-syntax
+func ParseAV1SequenceHeader(payload []byte) (seq AV1SequenceHeader, err error) {
+	r := newBitReader(payload)
+	seq.SeqProfile = byte(r.f(3))
+	seq.StillPicture = r.flag()
+	seq.ReducedStillPictureHeader = r.flag()
+	if seq.ReducedStillPictureHeader {
+		seq.SeqLevelIdx0 = byte(r.f(5))
+	} else {
+		var decoderModelInfoPresent bool
+		var bufferDelayLength int
+		if timingInfoPresent := r.flag(); timingInfoPresent {
+			r.f(32) // num_units_in_display_tick
+			r.f(32) // time_scale
+			if equalPictureInterval := r.flag(); equalPictureInterval {
+				r.uvlc() // num_ticks_per_picture_minus_1
+			}
+			if decoderModelInfoPresent = r.flag(); decoderModelInfoPresent {
+				bufferDelayLength = int(r.f(5)) + 1
+				r.f(32) // num_units_in_decoding_tick
+				r.f(5)  // buffer_removal_time_length_minus_1
+				r.f(5)  // frame_presentation_time_length_minus_1
+			}
+		}
+		initialDisplayDelayPresent := r.flag()
+		operatingPointsCnt := int(r.f(5)) + 1
+		for i := 0; i < operatingPointsCnt; i++ {
+			r.f(12) // operating_point_idc
+			level := byte(r.f(5))
+			var tier byte
+			if level > 7 {
+				tier = byte(r.f(1))
+			}
+			if i == 0 {
+				seq.SeqLevelIdx0, seq.SeqTier0 = level, tier
+			}
+			if decoderModelInfoPresent && r.flag() {
+				r.f(bufferDelayLength) // decoder_buffer_delay
+				r.f(bufferDelayLength) // encoder_buffer_delay
+				r.f(1)                 // low_delay_mode_flag
+			}
+			if initialDisplayDelayPresent && r.flag() {
+				r.f(4) // initial_display_delay_minus_1
+			}
+		}
+	}
+	frameWidthBits := int(r.f(4)) + 1
+	frameHeightBits := int(r.f(4)) + 1
+	seq.Width = r.f(frameWidthBits) + 1
+	seq.Height = r.f(frameHeightBits) + 1
+	if !seq.ReducedStillPictureHeader && r.flag() { // frame_id_numbers_present_flag
+		r.f(4) // delta_frame_id_length_minus_2
+		r.f(3) // additional_frame_id_length_minus_1
+	}
+	r.f(1) // use_128x128_superblock
+	r.f(1) // enable_filter_intra
+	r.f(1) // enable_intra_edge_filter
+	if !seq.ReducedStillPictureHeader {
+		r.f(1) // enable_interintra_compound
+		r.f(1) // enable_masked_compound
+		r.f(1) // enable_warped_motion
+		r.f(1) // enable_dual_filter
+		enableOrderHint := r.flag()
+		if enableOrderHint {
+			r.f(1) // enable_jnt_comp
+			r.f(1) // enable_ref_frame_mvs
+		}
+		seqForceScreenContentTools := uint(2)
+		if seqChooseScreenContentTools := r.flag(); !seqChooseScreenContentTools {
+			seqForceScreenContentTools = r.f(1)
+		}
+		if seqForceScreenContentTools > 0 {
+			if seqChooseIntegerMv := r.flag(); !seqChooseIntegerMv {
+				r.f(1) // seq_force_integer_mv
+			}
+		}
+		if enableOrderHint {
+			r.f(3) // order_hint_bits_minus_1
+		}
+	}
+	r.f(1) // enable_superres
+	r.f(1) // enable_cdef
+	r.f(1) // enable_restoration
+	// color_config()
+	seq.HighBitdepth = r.flag()
+	seq.BitDepth = 8
+	if seq.SeqProfile == 2 && seq.HighBitdepth {
+		if seq.TwelveBit = r.flag(); seq.TwelveBit {
+			seq.BitDepth = 12
+		} else {
+			seq.BitDepth = 10
+		}
+	} else if seq.HighBitdepth {
+		seq.BitDepth = 10
+	}
+	if seq.SeqProfile != 1 {
+		seq.Monochrome = r.flag()
+	}
+	seq.ColorPrimaries, seq.TransferCharacteristics, seq.MatrixCoefficients = 2, 2, 2
+	if colorDescriptionPresent := r.flag(); colorDescriptionPresent {
+		seq.ColorPrimaries = byte(r.f(8))
+		seq.TransferCharacteristics = byte(r.f(8))
+		seq.MatrixCoefficients = byte(r.f(8))
+	}
+	switch {
+	case seq.Monochrome:
+		seq.ColorRange = byte(r.f(1))
+		seq.ChromaSubsamplingX, seq.ChromaSubsamplingY = 1, 1
+	case seq.ColorPrimaries == 1 && seq.TransferCharacteristics == 13 && seq.MatrixCoefficients == 0:
+		// sRGB
+		seq.ColorRange = 1
+	default:
+		seq.ColorRange = byte(r.f(1))
+		switch seq.SeqProfile {
+		case 0:
+			seq.ChromaSubsamplingX, seq.ChromaSubsamplingY = 1, 1
+		case 1:
+		default:
+			if seq.BitDepth == 12 {
+				if seq.ChromaSubsamplingX = byte(r.f(1)); seq.ChromaSubsamplingX == 1 {
+					seq.ChromaSubsamplingY = byte(r.f(1))
+				}
+			} else {
+				seq.ChromaSubsamplingX = 1
+			}
+		}
+		if seq.ChromaSubsamplingX == 1 && seq.ChromaSubsamplingY == 1 {
+			seq.ChromaSamplePosition = byte(r.f(2))
+		}
+	}
+	if r.err != nil {
+		err = ErrAV1
+	}
+	return
+}
+
+// BuildAV1C 根据序列头构建 AV1CodecConfigurationRecord，obu为完整的序列头OBU
+// https://aomediacodec.github.io/av1-isobmff/#av1codecconfigurationbox-syntax
+func BuildAV1C(seq *AV1SequenceHeader, obu []byte) []byte {
+	var b1, b2 byte
+	b1 = seq.SeqProfile<<5 | seq.SeqLevelIdx0&0x1F
+	b2 = seq.SeqTier0<<7 | seq.ChromaSubsamplingX<<3 | seq.ChromaSubsamplingY<<2 | seq.ChromaSamplePosition&0x03
+	if seq.HighBitdepth {
+		b2 |= 1 << 6
+	}
+	if seq.TwelveBit {
+		b2 |= 1 << 5
+	}
+	if seq.Monochrome {
+		b2 |= 1 << 4
+	}
+	record := []byte{0x81, b1, b2, 0}
+	// configOBUs 中的OBU必须带有长度字段
+	if len(obu) > 0 && obu[0]&AV1_OBU_HAS_SIZE_FIELD == 0 {
+		hl := AV1OBUHeaderSize(obu[0])
+		record = append(record, obu[0]|AV1_OBU_HAS_SIZE_FIELD)
+		record = append(record, obu[1:hl]...)
+		record = AppendLEB128(record, uint64(len(obu)-hl))
+		return append(record, obu[hl:]...)
+	}
+	return append(record, obu...)
+}
+
+// ParseAV1C 解析 AV1CodecConfigurationRecord，如果configOBUs中含有序列头则解析完整的序列头，obu返回该序列头OBU
+func ParseAV1C(record []byte) (seq AV1SequenceHeader, obu []byte, err error) {
+	if len(record) < 4 || record[0] != 0x81 {
+		return seq, nil, ErrAV1
+	}
+	seq.SeqProfile = record[1] >> 5
+	seq.SeqLevelIdx0 = record[1] & 0x1F
+	seq.SeqTier0 = record[2] >> 7
+	seq.HighBitdepth = record[2]&(1<<6) != 0
+	seq.TwelveBit = record[2]&(1<<5) != 0
+	seq.Monochrome = record[2]&(1<<4) != 0
+	seq.ChromaSubsamplingX = (record[2] >> 3) & 1
+	seq.ChromaSubsamplingY = (record[2] >> 2) & 1
+	seq.ChromaSamplePosition = record[2] & 0x03
+	for configOBUs := record[4:]; len(configOBUs) > 0; {
+		header, payload, n, e := ParseAV1OBU(configOBUs)
+		if e != nil {
+			return seq, nil, e
+		}
+		if ParseAV1OBUType(header[0]) == AV1_OBU_SEQUENCE_HEADER {
+			obu = configOBUs[:n]
+			seq, err = ParseAV1SequenceHeader(payload)
+			return
+		}
+		configOBUs = configOBUs[n:]
+	}
+	return
+}
+
+// IsAV1KeyFrame 根据帧头OBU负载的第一个字节判断是否为显示的关键帧
+// https://aomediacodec.github.io/av1-spec/#uncompressed-header-syntax
+func IsAV1KeyFrame(seq *AV1SequenceHeader, b byte) bool {
+	if seq != nil && seq.ReducedStillPictureHeader {
+		return true
+	}
+	// show_existing_frame(1) frame_type(2) show_frame(1)
+	return b&0b1000_0000 == 0 && (b>>5)&0b11 == 0 && b&0b0001_0000 != 0
+}
@@ -0,0 +1,102 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Chrome WebRTC 发送的序列头OBU（不带长度字段）
+var av1SequenceHeaderOBU = []byte{0x08, 0x00, 0x00, 0x00, 0x2c, 0xd6, 0xd3, 0x0c, 0xd5, 0x02, 0x00, 0x80}
+
+func TestParseAV1SequenceHeader(t *testing.T) {
+	header, payload, n, err := ParseAV1OBU(av1SequenceHeaderOBU)
+	if err != nil || n != len(av1SequenceHeaderOBU) || ParseAV1OBUType(header[0]) != AV1_OBU_SEQUENCE_HEADER {
+		t.Fatalf("ParseAV1OBU = %x, %d, %v", header, n, err)
+	}
+	seq, err := ParseAV1SequenceHeader(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := AV1SequenceHeader{
+		SeqLevelIdx0:            5,
+		ChromaSubsamplingX:      1,
+		ChromaSubsamplingY:      1,
+		ColorPrimaries:          2,
+		TransferCharacteristics: 2,
+		MatrixCoefficients:      2,
+		BitDepth:                8,
+		Width:                   874,
+		Height:                  1076,
+	}
+	if seq != want {
+		t.Errorf("seq = %+v, want %+v", seq, want)
+	}
+	if _, err = ParseAV1SequenceHeader(payload[:4]); err != ErrAV1 {
+		t.Errorf("truncated = %v, want ErrAV1", err)
+	}
+}
+
+func TestAV1C(t *testing.T) {
+	seq, _ := ParseAV1SequenceHeader(av1SequenceHeaderOBU[1:])
+	record := BuildAV1C(&seq, av1SequenceHeaderOBU)
+	// configOBUs 中的序列头加上了长度字段
+	want := append([]byte{0x81, 0x05, 0x0c, 0x00, 0x0a, 0x0b}, av1SequenceHeaderOBU[1:]...)
+	if !bytes.Equal(record, want) {
+		t.Fatalf("BuildAV1C = %x, want %x", record, want)
+	}
+	parsed, obu, err := ParseAV1C(record)
+	if err != nil || parsed != seq || !bytes.Equal(obu, record[4:]) {
+		t.Errorf("ParseAV1C = %+v, %x, %v", parsed, obu, err)
+	}
+	// 只有4字节头部时使用其中的字段
+	parsed, obu, err = ParseAV1C(record[:4])
+	if err != nil || obu != nil || parsed.SeqLevelIdx0 != 5 || parsed.ChromaSubsamplingX != 1 || parsed.ChromaSubsamplingY != 1 {
+		t.Errorf("ParseAV1C(header only) = %+v, %x, %v", parsed, obu, err)
+	}
+	if _, _, err = ParseAV1C([]byte{0x01, 0, 0, 0}); err != ErrAV1 {
+		t.Errorf("bad marker = %v, want ErrAV1", err)
+	}
+}
+
+func TestLEB128(t *testing.T) {
+	for _, c := range []struct {
+		value uint64
+		b     []byte
+	}{
+		{0, []byte{0x00}},
+		{11, []byte{0x0b}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x80, 0x01}},
+		{300, []byte{0xac, 0x02}},
+		{1 << 21, []byte{0x80, 0x80, 0x80, 0x01}},
+	} {
+		if b := AppendLEB128(nil, c.value); !bytes.Equal(b, c.b) || SizeOfLEB128(c.value) != len(c.b) {
+			t.Errorf("AppendLEB128(%d) = %x, want %x", c.value, b, c.b)
+		}
+		if v, n, err := ReadLEB128(c.b); v != c.value || n != len(c.b) || err != nil {
+			t.Errorf("ReadLEB128(%x) = %d, %d, %v", c.b, v, n, err)
+		}
+	}
+	if _, _, err := ReadLEB128([]byte{0x80}); err != ErrAV1 {
+		t.Errorf("ReadLEB128(truncated) = %v, want ErrAV1", err)
+	}
+}
+
+func TestIsAV1KeyFrame(t *testing.T) {
+	for _, c := range []struct {
+		b   byte
+		key bool
+	}{
+		{0b0001_0000, true},  // KEY_FRAME show_frame
+		{0b0000_0000, false}, // 不显示的关键帧
+		{0b0011_0000, false}, // INTER_FRAME
+		{0b1000_0000, false}, // show_existing_frame
+	} {
+		if got := IsAV1KeyFrame(nil, c.b); got != c.key {
+			t.Errorf("IsAV1KeyFrame(%08b) = %v, want %v", c.b, got, c.key)
+		}
+	}
+	if !IsAV1KeyFrame(&AV1SequenceHeader{ReducedStillPictureHeader: true}, 0b0011_0000) {
+		t.Error("reduced still picture header should always be key frame")
+	}
+}
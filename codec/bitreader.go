@@ -0,0 +1,57 @@
+package codec
+
+import (
+	"bytes"
+
+	"m7s.live/engine/v4/util/bits"
+)
+
+// bitReader 出错后不再读取，最后统一检查错误，用于AV1、VP9、SEI、VUI等按位定义的语法
+type bitReader struct {
+	bits.GolombBitReader
+	err error
+	pos int // 已经读取的位数
+}
+
+func newBitReader(b []byte) *bitReader {
+	return &bitReader{GolombBitReader: bits.GolombBitReader{R: bytes.NewReader(b)}}
+}
+
+func (r *bitReader) f(n int) (v uint) {
+	if r.err == nil {
+		if v, r.err = r.ReadBits(n); r.err == nil {
+			r.pos += n
+		}
+	}
+	return
+}
+
+// ue 通过f读取，保持pos正确
+func (r *bitReader) ue() (v uint) {
+	leadingZeros := 0
+	for r.err == nil && leadingZeros < 32 && !r.flag() {
+		leadingZeros++
+	}
+	return r.f(leadingZeros) + 1<<leadingZeros - 1
+}
+
+func (r *bitReader) flag() bool {
+	return r.f(1) == 1
+}
+
+func (r *bitReader) uvlc() {
+	leadingZeros := 0
+	for r.err == nil && !r.flag() {
+		leadingZeros++
+	}
+	if leadingZeros < 32 {
+		r.f(leadingZeros)
+	}
+}
+
+func (r *bitReader) skip(n uint) {
+	for ; n >= 8 && r.err == nil; n -= 8 {
+		r.f(8)
+	}
+	r.f(int(n))
+}
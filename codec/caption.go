@@ -0,0 +1,136 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrCaption = errors.New("caption cc_data error")
+
+const (
+	CC_TYPE_608_FIELD1  = 0
+	CC_TYPE_608_FIELD2  = 1
+	CC_TYPE_DTVCC_DATA  = 2
+	CC_TYPE_DTVCC_START = 3
+)
+
+// CCData cc_data中的一个三元组 CEA-708 4.4
+type CCData struct {
+	Valid bool
+	Type  byte
+	Data  [2]byte
+}
+
+// ParseA53CCData 从 user_data_registered_itu_t_t35 中取出 ATSC A/53 的 cc_data
+func ParseA53CCData(r SEIUserDataRegistered) (ccs []CCData, err error) {
+	b := r.Data
+	// itu_t_t35_provider_code=0x0031 user_identifier=GA94 user_data_type_code=0x03
+	if r.CountryCode != 0xB5 || len(b) < 9 || b[0] != 0 || b[1] != 0x31 || string(b[2:6]) != "GA94" || b[6] != 3 {
+		return nil, ErrCaption
+	}
+	// process_em_data_flag process_cc_data_flag additional_data_flag cc_count
+	if b[7]&0x40 == 0 {
+		return
+	}
+	count := int(b[7] & 0x1F)
+	b = b[9:]
+	if len(b) < count*3 {
+		return nil, ErrCaption
+	}
+	for i := 0; i < count; i++ {
+		ccs = append(ccs, CCData{b[0]&0x04 != 0, b[0] & 0x03, [2]byte{b[1], b[2]}})
+		b = b[3:]
+	}
+	return
+}
+
+// CaptionDecoder 解码CEA-608（CC1~CC4）和CEA-708（SERVICE1~SERVICE63）字幕，显示的文本改变时回调OnCaption，text为空表示清屏
+type CaptionDecoder struct {
+	OnCaption func(channel string, text string)
+	cea608    [2]cea608Field
+	dtvcc     []byte // 正在组装的DTVCC包
+	services  map[byte]*cea708Service
+	last      map[string]string // 每个频道最后回调的文本，用于去重
+}
+
+func (d *CaptionDecoder) emit(channel string, text string) {
+	if d.last == nil {
+		d.last = make(map[string]string)
+	}
+	if d.last[channel] != text {
+		d.last[channel] = text
+		if d.OnCaption != nil {
+			d.OnCaption(channel, text)
+		}
+	}
+}
+
+func (d *CaptionDecoder) Decode(ccs []CCData) {
+	for _, cc := range ccs {
+		switch cc.Type {
+		case CC_TYPE_608_FIELD1, CC_TYPE_608_FIELD2:
+			if cc.Valid {
+				field := cc.Type
+				d.cea608[field].decode(cc.Data, func(ch byte, text string) {
+					d.emit(fmt.Sprintf("CC%d", field*2+ch+1), text)
+				})
+			}
+		case CC_TYPE_DTVCC_START:
+			d.flushDTVCC()
+			if cc.Valid {
+				d.dtvcc = append(d.dtvcc[:0], cc.Data[:]...)
+			}
+		case CC_TYPE_DTVCC_DATA:
+			if cc.Valid && len(d.dtvcc) > 0 {
+				d.dtvcc = append(d.dtvcc, cc.Data[:]...)
+			}
+		}
+		// packet_size_code 为0时表示128字节
+		if len(d.dtvcc) > 0 {
+			size := int(d.dtvcc[0]&0x3F) * 2
+			if size == 0 {
+				size = 128
+			}
+			if len(d.dtvcc) >= size {
+				d.flushDTVCC()
+			}
+		}
+	}
+}
+
+// flushDTVCC 解析一个完整的DTVCC包中的Service Block CEA-708 6.2
+func (d *CaptionDecoder) flushDTVCC() {
+	if len(d.dtvcc) == 0 {
+		return
+	}
+	b := d.dtvcc[1:]
+	d.dtvcc = d.dtvcc[:0]
+	for len(b) > 0 {
+		service, size := b[0]>>5, int(b[0]&0x1F)
+		if b = b[1:]; service == 0 {
+			return
+		}
+		if service == 7 && size != 0 {
+			if len(b) == 0 {
+				return
+			}
+			service, b = b[0]&0x3F, b[1:]
+		}
+		if size > len(b) {
+			return
+		}
+		if d.services == nil {
+			d.services = make(map[byte]*cea708Service)
+		}
+		s := d.services[service]
+		if s == nil {
+			s = &cea708Service{}
+			d.services[service] = s
+		}
+		channel := fmt.Sprintf("SERVICE%d", service)
+		s.decode(b[:size], func(text string) {
+			d.emit(channel, text)
+		})
+		b = b[size:]
+	}
+}
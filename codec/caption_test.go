@@ -0,0 +1,115 @@
+package codec
+
+import (
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+// cea608 加上奇校验位，生成field 1的cc_data
+func cea608(s ...byte) (ccs []CCData) {
+	for i := 0; i < len(s); i += 2 {
+		data := [2]byte{s[i], 0}
+		if i+1 < len(s) {
+			data[1] = s[i+1]
+		}
+		for j, b := range data {
+			if bits.OnesCount8(b)%2 == 0 {
+				data[j] |= 0x80
+			}
+		}
+		ccs = append(ccs, CCData{true, CC_TYPE_608_FIELD1, data})
+	}
+	return
+}
+
+// a53SEI 生成一个携带cc_data的H264 SEI NALU
+func a53SEI(ccs ...[3]byte) []byte {
+	payload := []byte{0xb5, 0x00, 0x31, 'G', 'A', '9', '4', 0x03, 0x40 | byte(len(ccs)), 0xff}
+	for _, cc := range ccs {
+		payload = append(payload, cc[:]...)
+	}
+	payload = append(payload, 0xff)
+	return append(append([]byte{0x06, byte(SEI_USER_DATA_REGISTERED_ITU_T_T35), byte(len(payload))}, payload...), 0x80)
+}
+
+type captionRecorder []string
+
+func (r *captionRecorder) decoder() *CaptionDecoder {
+	return &CaptionDecoder{OnCaption: func(channel, text string) {
+		*r = append(*r, channel+":"+strings.ReplaceAll(text, "\n", "|"))
+	}}
+}
+
+func TestParseA53CCData(t *testing.T) {
+	msgs, err := ParseSEI(a53SEI([3]byte{0xfc, 0x94, 0x20}, [3]byte{0xf8, 0x80, 0x80}, [3]byte{0xff, 0x03, 0x23}), false)
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("ParseSEI = %+v, %v", msgs, err)
+	}
+	r, err := ParseSEIUserDataRegistered(msgs[0].Payload)
+	if err != nil || r.CountryCode != 0xb5 {
+		t.Fatalf("ParseSEIUserDataRegistered = %+v, %v", r, err)
+	}
+	ccs, err := ParseA53CCData(r)
+	want := []CCData{{true, CC_TYPE_608_FIELD1, [2]byte{0x94, 0x20}}, {false, CC_TYPE_608_FIELD1, [2]byte{0x80, 0x80}}, {true, CC_TYPE_DTVCC_START, [2]byte{0x03, 0x23}}}
+	if err != nil || len(ccs) != len(want) {
+		t.Fatalf("ParseA53CCData = %+v, %v", ccs, err)
+	}
+	for i := range want {
+		if ccs[i] != want[i] {
+			t.Errorf("cc %d = %+v, want %+v", i, ccs[i], want[i])
+		}
+	}
+	// 其他用途的T.35数据，例如HDR10+
+	if _, err = ParseA53CCData(SEIUserDataRegistered{CountryCode: 0xb5, Data: []byte{0x00, 0x3c, 0x00, 0x01, 0x04, 0x01, 0x40, 0x00, 0x00}}); err != ErrCaption {
+		t.Errorf("hdr10+ = %v, want ErrCaption", err)
+	}
+	r.Data = r.Data[:len(r.Data)-4]
+	if _, err = ParseA53CCData(r); err != ErrCaption {
+		t.Errorf("truncated = %v, want ErrCaption", err)
+	}
+}
+
+func TestCEA608(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		data []byte
+		want []string
+	}{
+		// 控制码发送两次
+		{"pop-on", []byte{0x14, 0x20, 0x14, 0x20, 0x14, 0x70, 'H', 'E', 'L', 'L', 'O', 0x00, 0x14, 0x2f, 0x14, 0x2f, 0x14, 0x2c, 0x14, 0x2c}, []string{"CC1:HELLO", "CC1:"}},
+		{"roll-up", []byte{0x1c, 0x25, 0x1c, 0x25, 'H', 'I', 0x1c, 0x2d, 'T', 'H', 'E', 'R', 'E', 0x00, 0x1c, 0x2d}, []string{"CC2:HI", "CC2:HI|THERE"}},
+		{"paint-on", []byte{0x14, 0x29, 'O', 'K'}, []string{"CC1:OK"}},
+		// 扩展字符替换前面的基本字符，特殊字符
+		{"extended and special", []byte{0x14, 0x29, 'E', 0x00, 0x12, 0x21, 0x11, 0x37}, []string{"CC1:E", "CC1:É", "CC1:É♪"}},
+		{"backspace", []byte{0x14, 0x29, 'A', 'B', 0x14, 0x21}, []string{"CC1:AB", "CC1:A"}},
+	} {
+		var got captionRecorder
+		got.decoder().Decode(cea608(c.data...))
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s: %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCEA708(t *testing.T) {
+	var got captionRecorder
+	d := got.decoder()
+	// packet_size_code=3 service 1 block_size 3
+	d.Decode([]CCData{
+		{true, CC_TYPE_DTVCC_START, [2]byte{0x03, 0x23}},
+		{true, CC_TYPE_DTVCC_DATA, [2]byte{'H', 'I'}},
+		{true, CC_TYPE_DTVCC_DATA, [2]byte{0x03, 0x00}},
+	})
+	// 下一个包：CR 换行，文本不变时不回调，DLW 清屏
+	d.Decode([]CCData{
+		{true, CC_TYPE_DTVCC_START, [2]byte{0x44, 0x26}},
+		{true, CC_TYPE_DTVCC_DATA, [2]byte{0x0d, 'O'}},
+		{true, CC_TYPE_DTVCC_DATA, [2]byte{'K', 0x0d}},
+		{true, CC_TYPE_DTVCC_DATA, [2]byte{0x8c, 0xff}},
+	})
+	want := []string{"SERVICE1:HI", "SERVICE1:HI|OK", "SERVICE1:"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("CEA-708: %q, want %q", got, want)
+	}
+}
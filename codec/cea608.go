@@ -0,0 +1,220 @@
+package codec
+
+import "strings"
+
+// CEA-608 字幕模式
+const (
+	cea608PopOn = iota
+	cea608RollUp
+	cea608PaintOn
+	cea608Text // Text模式的数据不是字幕，忽略
+)
+
+// 基本字符集中与ASCII不同的字符
+var cea608Basic = map[byte]rune{
+	0x2A: 'á', 0x5C: 'é', 0x5E: 'í', 0x5F: 'ó', 0x60: 'ú',
+	0x7B: 'ç', 0x7C: '÷', 0x7D: 'Ñ', 0x7E: 'ñ', 0x7F: '█',
+}
+
+// 特殊字符 0x11/0x19 0x30~0x3F
+var cea608Special = []rune("®°½¿™¢£♪à èâêîôû")
+
+// 扩展字符 0x12/0x1A 0x20~0x3F 和 0x13/0x1B 0x20~0x3F
+var cea608Extended = [2][]rune{
+	[]rune("ÁÉÓÚÜü‘¡*’—©℠•“”ÀÂÇÈÊËëÎÏïÔÙùÛ«»"),
+	[]rune("ÃãÍÌìÒòÕõ{}\\^_|~ÄäÖöß¥¤│ÅåØø┌┐└┘"),
+}
+
+// PAC 中 b1&0x07 对应的行（从0开始），b2的0x20位为1时加一行
+var cea608PACRow = [8]int{10, 0, 2, 11, 13, 4, 6, 8}
+
+type cea608Screen [15][32]rune
+
+func (s *cea608Screen) String() string {
+	var rows []string
+	for _, row := range s {
+		var b strings.Builder
+		for _, r := range row {
+			if r == 0 {
+				r = ' '
+			}
+			b.WriteRune(r)
+		}
+		if text := strings.TrimSpace(b.String()); text != "" {
+			rows = append(rows, text)
+		}
+	}
+	return strings.Join(rows, "\n")
+}
+
+// cea608Channel 一个数据频道（CC1~CC4）的显示状态
+type cea608Channel struct {
+	mode                int
+	displayed, buffered cea608Screen
+	row, col            int
+	rollRows            int
+}
+
+// memory 当前写入的显存，Pop-On写入非显示的缓存，其他模式直接写入显示的
+func (c *cea608Channel) memory() *cea608Screen {
+	if c.mode == cea608PopOn {
+		return &c.buffered
+	}
+	return &c.displayed
+}
+
+func (c *cea608Channel) write(r rune) {
+	if c.mode == cea608Text {
+		return
+	}
+	c.memory()[c.row][c.col] = r
+	if c.col < 31 {
+		c.col++
+	}
+}
+
+func (c *cea608Channel) backspace() {
+	if c.col > 0 {
+		c.col--
+		c.memory()[c.row][c.col] = 0
+	}
+}
+
+// cea608Field 一个场中的两个数据频道，field 1 为 CC1/CC2，field 2 为 CC3/CC4
+type cea608Field struct {
+	channels    [2]cea608Channel
+	current     byte    // 当前数据频道，由最近的控制码决定
+	lastControl [2]byte // 控制码一般发送两次，忽略重复的
+}
+
+// decode 解码一对字节，显示的文本改变时回调emit CEA-608-E Annex B
+func (f *cea608Field) decode(data [2]byte, emit func(ch byte, text string)) {
+	b1, b2 := data[0]&0x7F, data[1]&0x7F
+	if b1 == 0 && b2 == 0 {
+		return
+	}
+	if b1 < 0x10 {
+		// XDS 数据
+		f.lastControl = [2]byte{}
+		return
+	}
+	if b1 >= 0x20 {
+		f.lastControl = [2]byte{}
+		c := &f.channels[f.current]
+		for _, b := range [2]byte{b1, b2} {
+			if b < 0x20 {
+				continue
+			}
+			if r, ok := cea608Basic[b]; ok {
+				c.write(r)
+			} else {
+				c.write(rune(b))
+			}
+		}
+		if c.mode == cea608PaintOn {
+			emit(f.current, c.displayed.String())
+		}
+		return
+	}
+	if f.lastControl == [2]byte{b1, b2} {
+		f.lastControl = [2]byte{}
+		return
+	}
+	f.lastControl = [2]byte{b1, b2}
+	f.current = (b1 >> 3) & 1
+	c := &f.channels[f.current]
+	b1 &= 0xF7
+	switch {
+	case (b1 == 0x14 || b1 == 0x15) && b2 >= 0x20 && b2 <= 0x2F:
+		c.control(b2, func(text string) {
+			emit(f.current, text)
+		})
+	case b1 == 0x17 && b2 >= 0x21 && b2 <= 0x23:
+		// Tab Offset
+		for i := byte(0x20); i < b2 && c.col < 31; i++ {
+			c.col++
+		}
+	case b2 >= 0x40:
+		// PAC
+		row := cea608PACRow[b1&0x07]
+		if b2&0x20 != 0 {
+			row++
+		}
+		if c.mode == cea608RollUp {
+			// 基准行改变时，已经显示的行随之移动
+			if row != c.row {
+				var moved cea608Screen
+				for i := 0; i < c.rollRows && i <= row && i <= c.row; i++ {
+					moved[row-i] = c.displayed[c.row-i]
+				}
+				c.displayed = moved
+			}
+		}
+		c.row, c.col = row, 0
+		if b2&0x10 != 0 {
+			c.col = int(b2&0x0E) << 1
+		}
+	case b1 == 0x11 && b2 >= 0x20 && b2 <= 0x2F:
+		// Mid-Row 占一个空格
+		c.write(' ')
+	case b1 == 0x11 && b2 >= 0x30 && b2 <= 0x3F:
+		c.write(cea608Special[b2-0x30])
+	case (b1 == 0x12 || b1 == 0x13) && b2 >= 0x20 && b2 <= 0x3F:
+		// 扩展字符替换前面一个用于兼容的基本字符
+		c.backspace()
+		c.write(cea608Extended[b1-0x12][b2-0x20])
+	}
+	// Paint-On模式下特殊字符、扩展字符、BS、DER等直接改变显示的文本
+	if c.mode == cea608PaintOn {
+		emit(f.current, c.displayed.String())
+	}
+}
+
+// control 杂项控制码 CEA-608-E B.13
+func (c *cea608Channel) control(b2 byte, emit func(text string)) {
+	switch b2 {
+	case 0x20: // RCL
+		c.mode = cea608PopOn
+	case 0x21: // BS
+		c.backspace()
+	case 0x24: // DER
+		for i := c.col; i < 32; i++ {
+			c.memory()[c.row][i] = 0
+		}
+	case 0x25, 0x26, 0x27: // RU2 RU3 RU4
+		if c.mode != cea608RollUp {
+			c.displayed, c.buffered = cea608Screen{}, cea608Screen{}
+			c.row, c.col = 14, 0
+			emit("")
+		}
+		c.mode = cea608RollUp
+		c.rollRows = int(b2-0x25) + 2
+	case 0x29: // RDC
+		c.mode = cea608PaintOn
+	case 0x2A, 0x2B: // TR RTD
+		c.mode = cea608Text
+	case 0x2C: // EDM
+		c.displayed = cea608Screen{}
+		emit("")
+	case 0x2D: // CR
+		if c.mode == cea608RollUp {
+			// 上卷之前显示的是完整的若干行
+			emit(c.displayed.String())
+			for i := c.row - c.rollRows + 1; i < c.row; i++ {
+				if i >= 0 {
+					c.displayed[i] = c.displayed[i+1]
+				}
+			}
+			if top := c.row - c.rollRows; top >= 0 {
+				c.displayed[top] = [32]rune{}
+			}
+			c.displayed[c.row] = [32]rune{}
+			c.col = 0
+		}
+	case 0x2E: // ENM
+		c.buffered = cea608Screen{}
+	case 0x2F: // EOC
+		c.displayed, c.buffered = c.buffered, c.displayed
+		emit(c.displayed.String())
+	}
+}
@@ -0,0 +1,127 @@
+package codec
+
+import "strings"
+
+// G2 字符集中常用的字符 CEA-708 7.1.7
+var cea708G2 = map[byte]rune{
+	0x20: ' ', 0x21: ' ', 0x25: '…', 0x2A: 'Š', 0x2C: 'Œ', 0x30: '█',
+	0x31: '‘', 0x32: '’', 0x33: '“', 0x34: '”', 0x35: '•', 0x39: '™',
+	0x3A: 'š', 0x3C: 'œ', 0x3D: '℠', 0x3F: 'Ÿ', 0x76: '⅛', 0x77: '⅜',
+	0x78: '⅝', 0x79: '⅞', 0x7A: '│', 0x7B: '┐', 0x7C: '└', 0x7D: '─',
+	0x7E: '┘', 0x7F: '┌',
+}
+
+// C1 命令的参数长度 CEA-708 7.1.5，0x80~0x9F
+var cea708C1Params = [32]int{
+	0, 0, 0, 0, 0, 0, 0, 0, // CW0~CW7
+	1, 1, 1, 1, 1, 1, 0, 0, // CLW DSW HDW TGW DLW DLY DLC RST
+	2, 3, 2, 0, 0, 0, 0, 4, // SPA SPC SPL - - - - SWA
+	6, 6, 6, 6, 6, 6, 6, 6, // DF0~DF7
+}
+
+// cea708MaxRows 保留的最大行数，超过时丢弃最上面的行
+const cea708MaxRows = 4
+
+// cea708Service 一个字幕服务的文本状态，不处理窗口的位置和样式，所有窗口的文本合并在一起
+type cea708Service struct {
+	rows []string
+	line []rune
+}
+
+func (s *cea708Service) String() string {
+	rows := s.rows
+	if len(s.line) > 0 {
+		rows = append(rows[:len(rows):len(rows)], string(s.line))
+	}
+	var lines []string
+	for _, row := range rows {
+		if row = strings.TrimSpace(row); row != "" {
+			lines = append(lines, row)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (s *cea708Service) clear() {
+	s.rows, s.line = nil, nil
+}
+
+// decode 解码一个Service Block的数据，显示的文本改变时回调emit CEA-708 7.1
+func (s *cea708Service) decode(b []byte, emit func(text string)) {
+	for len(b) > 0 {
+		c := b[0]
+		b = b[1:]
+		switch {
+		case c == 0x03: // ETX
+			emit(s.String())
+		case c == 0x08: // BS
+			if len(s.line) > 0 {
+				s.line = s.line[:len(s.line)-1]
+			}
+		case c == 0x0C: // FF
+			s.clear()
+			emit("")
+		case c == 0x0D: // CR
+			if s.rows = append(s.rows, string(s.line)); len(s.rows) > cea708MaxRows {
+				s.rows = s.rows[1:]
+			}
+			s.line = nil
+			emit(s.String())
+		case c == 0x0E: // HCR
+			s.line = nil
+		case c == 0x10: // EXT1
+			if len(b) == 0 {
+				return
+			}
+			c, b = b[0], b[1:]
+			switch {
+			case c < 0x08: // C2 没有参数
+			case c < 0x10:
+				b = cea708Skip(b, 1)
+			case c < 0x18:
+				b = cea708Skip(b, 2)
+			case c < 0x20:
+				b = cea708Skip(b, 3)
+			case c < 0x80: // G2
+				if r, ok := cea708G2[c]; ok {
+					s.line = append(s.line, r)
+				}
+			case c < 0x88: // C3
+				b = cea708Skip(b, 4)
+			case c < 0x90:
+				b = cea708Skip(b, 5)
+			case c < 0xA0:
+				// 长度可变的C3命令，忽略剩下的数据
+				return
+			default: // G3 只定义了[CC]图标
+			}
+		case c < 0x10: // C0 其他没有参数的命令
+		case c < 0x18:
+			b = cea708Skip(b, 1)
+		case c < 0x20:
+			b = cea708Skip(b, 2)
+		case c < 0x7F: // G0
+			s.line = append(s.line, rune(c))
+		case c == 0x7F:
+			s.line = append(s.line, '♪')
+		case c < 0xA0: // C1
+			switch c {
+			case 0x89, 0x8B: // DSW TGW
+				emit(s.String())
+			case 0x88, 0x8A, 0x8C, 0x8F: // CLW HDW DLW RST
+				s.clear()
+				emit("")
+			}
+			b = cea708Skip(b, cea708C1Params[c-0x80])
+		default: // G1 即 ISO 8859-1
+			s.line = append(s.line, rune(c))
+		}
+	}
+}
+
+func cea708Skip(b []byte, n int) []byte {
+	if n > len(b) {
+		n = len(b)
+	}
+	return b[n:]
+}
@@ -12,8 +12,16 @@ const (
 	CodecID_AAC      AudioCodecID = 0xA
 	CodecID_PCMA     AudioCodecID = 7
 	CodecID_PCMU     AudioCodecID = 8
+	CodecID_OPUS     AudioCodecID = 0xC
+	CodecID_MP3      AudioCodecID = 2
+	CodecID_AC3      AudioCodecID = 0xD // FLV中没有定义AC-3和E-AC-3，仅内部使用
+	CodecID_EAC3     AudioCodecID = 0xE
+	CodecID_LPCM     AudioCodecID = 3 // 小端的线性PCM
 	CodecID_H264     VideoCodecID = 7
 	CodecID_H265     VideoCodecID = 0xC
+	CodecID_AV1      VideoCodecID = 0xD
+	CodecID_VP8      VideoCodecID = 0xE
+	CodecID_VP9      VideoCodecID = 0xF
 )
 
 func (codecId AudioCodecID) String() string {
@@ -24,6 +32,16 @@ func (codecId AudioCodecID) String() string {
 		return "pcma"
 	case CodecID_PCMU:
 		return "pcmu"
+	case CodecID_OPUS:
+		return "opus"
+	case CodecID_MP3:
+		return "mp3"
+	case CodecID_AC3:
+		return "ac3"
+	case CodecID_EAC3:
+		return "eac3"
+	case CodecID_LPCM:
+		return "pcm"
 	}
 	return "unknow"
 }
@@ -34,6 +52,12 @@ func (codecId VideoCodecID) String() string {
 		return "h264"
 	case CodecID_H265:
 		return "h265"
+	case CodecID_AV1:
+		return "av1"
+	case CodecID_VP8:
+		return "vp8"
+	case CodecID_VP9:
+		return "vp9"
 	}
 	return "unknow"
 }
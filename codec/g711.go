@@ -0,0 +1,115 @@
+package codec
+
+// G.711 A-law、µ-law 与16位线性PCM之间的转换 ITU-T G.711
+
+var (
+	alawSegEnd = [8]int{0x1F, 0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF}
+	ulawSegEnd = [8]int{0x3F, 0x7F, 0xFF, 0x1FF, 0x3FF, 0x7FF, 0xFFF, 0x1FFF}
+	alaw2ulaw  [256]byte
+	ulaw2alaw  [256]byte
+)
+
+func init() {
+	for i := 0; i < 256; i++ {
+		alaw2ulaw[i] = Linear2ULaw(ALaw2Linear(byte(i)))
+		ulaw2alaw[i] = Linear2ALaw(ULaw2Linear(byte(i)))
+	}
+}
+
+func g711Segment(v int, ends *[8]int) int {
+	for i, end := range ends {
+		if v <= end {
+			return i
+		}
+	}
+	return 8
+}
+
+func ALaw2Linear(a byte) int16 {
+	a ^= 0x55
+	t := int16(a&0x0F) << 4
+	switch seg := (a & 0x70) >> 4; seg {
+	case 0:
+		t += 8
+	case 1:
+		t += 0x108
+	default:
+		t = (t + 0x108) << (seg - 1)
+	}
+	if a&0x80 != 0 {
+		return t
+	}
+	return -t
+}
+
+func ULaw2Linear(u byte) int16 {
+	u = ^u
+	t := (int16(u&0x0F)<<3 + 0x84) << ((u & 0x70) >> 4)
+	if u&0x80 != 0 {
+		return 0x84 - t
+	}
+	return t - 0x84
+}
+
+func Linear2ALaw(pcm int16) byte {
+	// 13位
+	p, mask := int(pcm)>>3, byte(0xD5)
+	if p < 0 {
+		p, mask = -p-1, 0x55
+	}
+	seg := g711Segment(p, &alawSegEnd)
+	if seg >= 8 {
+		return 0x7F ^ mask
+	}
+	a := byte(seg << 4)
+	if seg < 2 {
+		a |= byte(p>>1) & 0x0F
+	} else {
+		a |= byte(p>>seg) & 0x0F
+	}
+	return a ^ mask
+}
+
+func Linear2ULaw(pcm int16) byte {
+	// 14位
+	p, mask := int(pcm)>>2, byte(0xFF)
+	if p < 0 {
+		p, mask = -p, 0x7F
+	}
+	if p > 8159 {
+		p = 8159
+	}
+	p += 0x84 >> 2
+	seg := g711Segment(p, &ulawSegEnd)
+	if seg >= 8 {
+		return 0x7F ^ mask
+	}
+	return (byte(seg<<4) | byte(p>>(seg+1))&0x0F) ^ mask
+}
+
+// ALaw2ULaw dst 与 src 长度相同，可以是同一个切片
+func ALaw2ULaw(dst, src []byte) {
+	for i, a := range src {
+		dst[i] = alaw2ulaw[a]
+	}
+}
+
+// ULaw2ALaw dst 与 src 长度相同，可以是同一个切片
+func ULaw2ALaw(dst, src []byte) {
+	for i, u := range src {
+		dst[i] = ulaw2alaw[u]
+	}
+}
+
+// G711ToPCM 解码为16位小端的线性PCM，dst 的长度为 src 的两倍
+func G711ToPCM(dst, src []byte, alaw bool) {
+	for i, b := range src {
+		var v int16
+		if alaw {
+			v = ALaw2Linear(b)
+		} else {
+			v = ULaw2Linear(b)
+		}
+		dst[i*2], dst[i*2+1] = byte(v), byte(v>>8)
+	}
+}
@@ -0,0 +1,72 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestG711(t *testing.T) {
+	for _, c := range []struct {
+		b    byte
+		alaw bool
+		want int16
+	}{
+		{0xd5, true, 8},
+		{0x55, true, -8},
+		{0x80, true, 5504},
+		{0x00, true, -5504},
+		{0xaa, true, 32256},
+		{0x2a, true, -32256},
+		{0xff, false, 0},
+		{0x7f, false, 0},
+		{0x80, false, 32124},
+		{0x00, false, -32124},
+		{0xf0, false, 120},
+	} {
+		var v int16
+		if c.alaw {
+			v = ALaw2Linear(c.b)
+		} else {
+			v = ULaw2Linear(c.b)
+		}
+		if v != c.want {
+			t.Errorf("alaw=%v %02x = %d, want %d", c.alaw, c.b, v, c.want)
+		}
+	}
+	for i := 0; i < 256; i++ {
+		if a := Linear2ALaw(ALaw2Linear(byte(i))); a != byte(i) {
+			t.Errorf("alaw %02x round trip = %02x", i, a)
+		}
+		// 0x7F 是µ-law中的-0
+		if u := Linear2ULaw(ULaw2Linear(byte(i))); u != byte(i) && i != 0x7f {
+			t.Errorf("ulaw %02x round trip = %02x", i, u)
+		}
+	}
+}
+
+func TestG711ToPCM(t *testing.T) {
+	src := []byte{0xd5, 0x55, 0x2a}
+	dst := make([]byte, len(src)*2)
+	G711ToPCM(dst, src, true)
+	// 小端
+	if want := []byte{0x08, 0x00, 0xf8, 0xff, 0x00, 0x82}; !bytes.Equal(dst, want) {
+		t.Errorf("alaw G711ToPCM = %x, want %x", dst, want)
+	}
+	G711ToPCM(dst[:4], []byte{0x80, 0xff}, false)
+	if want := []byte{0x7c, 0x7d, 0x00, 0x00}; !bytes.Equal(dst[:4], want) {
+		t.Errorf("ulaw G711ToPCM = %x, want %x", dst[:4], want)
+	}
+	ulaw := make([]byte, 256)
+	alaw := make([]byte, 256)
+	for i := range alaw {
+		alaw[i] = byte(i)
+	}
+	ALaw2ULaw(ulaw, alaw)
+	ULaw2ALaw(ulaw, ulaw)
+	for i, a := range ulaw {
+		// 转换是有损的，最多相差一个量化级
+		if d := int(ALaw2Linear(a)) - int(ALaw2Linear(byte(i))); d < -1024 || d > 1024 {
+			t.Errorf("alaw %02x -> ulaw -> alaw = %02x", i, a)
+		}
+	}
+}
@@ -43,6 +43,11 @@ func (b H264NALUType) Byte() byte {
 func ParseH264NALUType(b byte) H264NALUType {
 	return H264NALUType(b & 0x1F)
 }
+// ParseH264NALURefIdc nal_ref_idc，为0时该NALU不被其他帧参考
+func ParseH264NALURefIdc(b byte) byte {
+	return b >> 5 & 0x03
+}
+
 func (H264NALUType) Parse(b byte) H264NALUType {
 	return H264NALUType(b & 0x1F)
 }
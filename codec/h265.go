@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 
+	"github.com/cnotch/ipchub/av/codec/hevc"
 	"github.com/q191201771/naza/pkg/nazabits"
 	"m7s.live/engine/v4/util"
 )
@@ -18,6 +19,11 @@ func ParseH265NALUType(b byte) H265NALUType {
 	return H265NALUType(b & 0x7E >> 1)
 }
 
+// IsSubLayerNonRef 0到14之间的偶数类型为子层非参考帧（TRAIL_N、TSA_N、STSA_N、RADL_N、RASL_N、RSV_VCL_N），不被同一时域子层的其他帧参考，只有一个时域子层时即为非参考帧
+func (t H265NALUType) IsSubLayerNonRef() bool {
+	return t <= NAL_UNIT_RESERVED_14 && t&1 == 0
+}
+
 const (
 	// HEVC_VPS    = 0x40
 	// HEVC_SPS    = 0x42
@@ -111,7 +117,8 @@ type HVCDecoderConfigurationRecord struct {
 	generalConstraintIndicatorFlags  uint64
 	generalLevelIdc                  uint8
 
-	lengthSizeMinusOne uint8
+	minSpatialSegmentationIdc uint16
+	lengthSizeMinusOne        uint8
 
 	numTemporalLayers    uint8
 	temporalIdNested     uint8
@@ -212,6 +219,12 @@ func BuildH265SeqHeaderFromVpsSpsPps(vps, sps, pps []byte) ([]byte, error) {
 	if err := ctx.ParseSps(sps); err != nil {
 		return nil, err
 	}
+	// VUI中的帧率和min_spatial_segmentation_idc
+	var rawsps hevc.H265RawSPS
+	if rawsps.Decode(sps) == nil {
+		ctx.avgFrameRate = uint16(rawsps.FrameRate() * 256)
+		ctx.minSpatialSegmentationIdc = rawsps.Vui.Min_spatial_segmentation_idc & 0x0FFF
+	}
 
 	// unsigned int(2) general_profile_space;
 	// unsigned int(1) general_tier_flag;
@@ -229,8 +242,8 @@ func BuildH265SeqHeaderFromVpsSpsPps(vps, sps, pps []byte) ([]byte, error) {
 	// unsigned int(12) min_spatial_segmentation_idc;
 	// bit(6) reserved = ‘111111’b;
 	// unsigned int(2) parallelismType;
-	// TODO chef: 这两个字段没有解析
-	util.PutBE(sh[18:20], 0xf000)
+	// parallelismType 需要解析PPS，使用0（未知）
+	util.PutBE(sh[18:20], 0xf000|ctx.minSpatialSegmentationIdc)
 	sh[20] = ctx.parallelismType | 0xfc
 
 	// bit(6) reserved = ‘111111’b;
@@ -0,0 +1,299 @@
+package codec
+
+import "errors"
+
+var ErrLATM = errors.New("latm parse error")
+
+// StreamMuxConfig ISO/IEC 14496-3 1.7.3.1，只支持一个program和一个layer
+type StreamMuxConfig struct {
+	AudioMuxVersion           byte
+	AllStreamsSameTimeFraming bool
+	NumSubFrames              byte   // 每个AudioMuxElement中有NumSubFrames+1个AU
+	AudioSpecificConfig       []byte // 按字节对齐的AudioSpecificConfig
+	FrameLengthType           byte
+	LatmBufferFullness        byte // FrameLengthType为0时有效
+	FrameLength               uint // FrameLengthType为1时有效
+	OtherDataLenBits          uint
+	CRCCheckPresent           bool
+}
+
+// NewStreamMuxConfig 根据AudioSpecificConfig生成用于RTP（RFC 3016 cpresent=0）的StreamMuxConfig
+func NewStreamMuxConfig(asc []byte) *StreamMuxConfig {
+	return &StreamMuxConfig{
+		AllStreamsSameTimeFraming: true,
+		AudioSpecificConfig:       asc,
+		LatmBufferFullness:        0xFF,
+	}
+}
+
+// latmReader LATM中的字段不按字节对齐，data用于按位置取出AudioSpecificConfig和负载
+type latmReader struct {
+	bitReader
+	data []byte
+}
+
+func newLatmReader(data []byte) *latmReader {
+	return &latmReader{*newBitReader(data), data}
+}
+
+// check 读取越界等错误统一返回ErrLATM
+func (r *latmReader) check() error {
+	if r.err != nil {
+		return ErrLATM
+	}
+	return nil
+}
+
+// bytes 取出[start,end)之间的位，最后一个字节补0
+func (r *latmReader) bytes(start, end int) []byte {
+	b := make([]byte, (end-start+7)/8)
+	for i := start; i < end; i++ {
+		if r.data[i>>3]>>(7-i&7)&1 == 1 {
+			b[(i-start)>>3] |= 0x80 >> ((i - start) & 7)
+		}
+	}
+	return b
+}
+
+// latmGetValue LatmGetValue()
+func (r *latmReader) latmGetValue() (v uint) {
+	bytesForValue := r.f(2)
+	for i := uint(0); i <= bytesForValue && r.err == nil; i++ {
+		v = v<<8 | r.f(8)
+	}
+	return
+}
+
+func (r *latmReader) audioObjectType() (aot uint) {
+	if aot = r.f(5); aot == 31 {
+		aot = 32 + r.f(6)
+	}
+	return
+}
+
+func (r *latmReader) samplingFrequencyIndex() {
+	if r.f(4) == 0xF {
+		r.f(24)
+	}
+}
+
+// audioSpecificConfig ISO/IEC 14496-3 1.6.2.1，只用来确定长度，不支持program_config_element
+func (r *latmReader) audioSpecificConfig() {
+	aot := r.audioObjectType()
+	r.samplingFrequencyIndex()
+	channel := r.f(4)
+	if aot == 5 || aot == 29 {
+		r.samplingFrequencyIndex()
+		if aot = r.audioObjectType(); aot == 22 {
+			r.f(4)
+		}
+	}
+	switch aot {
+	case 1, 2, 3, 4, 6, 7, 17, 19, 20, 21, 22, 23:
+		// GASpecificConfig frameLengthFlag dependsOnCoreCoder coreCoderDelay extensionFlag
+		r.f(1)
+		if r.flag() {
+			r.f(14)
+		}
+		extensionFlag := r.flag()
+		if channel == 0 {
+			r.err = ErrLATM
+			return
+		}
+		if aot == 6 || aot == 20 {
+			r.f(3)
+		}
+		if extensionFlag {
+			if aot == 22 {
+				r.f(16)
+			}
+			if aot == 17 || aot == 19 || aot == 20 || aot == 23 {
+				r.f(3)
+			}
+			r.f(1)
+		}
+		if aot >= 17 && aot != 18 {
+			// epConfig 为2和3时还有ErrorProtectionSpecificConfig
+			if ep := r.f(2); ep > 1 {
+				r.err = ErrLATM
+			}
+		}
+	default:
+		r.err = ErrLATM
+	}
+}
+
+func (r *latmReader) streamMuxConfig() (c *StreamMuxConfig) {
+	c = &StreamMuxConfig{AudioMuxVersion: byte(r.f(1))}
+	audioMuxVersionA := uint(0)
+	if c.AudioMuxVersion == 1 {
+		audioMuxVersionA = r.f(1)
+	}
+	if audioMuxVersionA != 0 {
+		r.err = ErrLATM
+		return
+	}
+	if c.AudioMuxVersion == 1 {
+		// taraBufferFullness
+		r.latmGetValue()
+	}
+	c.AllStreamsSameTimeFraming = r.flag()
+	c.NumSubFrames = byte(r.f(6))
+	// numProgram numLayer
+	if r.f(4) != 0 || r.f(3) != 0 {
+		r.err = ErrLATM
+		return
+	}
+	if c.AudioMuxVersion == 0 {
+		start := r.pos
+		r.audioSpecificConfig()
+		if r.err != nil {
+			return
+		}
+		c.AudioSpecificConfig = r.bytes(start, r.pos)
+	} else {
+		ascLen := r.latmGetValue()
+		start := r.pos
+		r.audioSpecificConfig()
+		if r.err != nil || uint(r.pos-start) > ascLen {
+			r.err = ErrLATM
+			return
+		}
+		r.skip(ascLen - uint(r.pos-start))
+		c.AudioSpecificConfig = r.bytes(start, r.pos)
+	}
+	switch c.FrameLengthType = byte(r.f(3)); c.FrameLengthType {
+	case 0:
+		c.LatmBufferFullness = byte(r.f(8))
+	case 1:
+		c.FrameLength = r.f(9)
+	default:
+		// CELP、HVXC
+		r.err = ErrLATM
+		return
+	}
+	if r.flag() {
+		if c.AudioMuxVersion == 1 {
+			c.OtherDataLenBits = r.latmGetValue()
+		} else {
+			for esc := true; esc && r.err == nil; {
+				esc = r.flag()
+				c.OtherDataLenBits = c.OtherDataLenBits<<8 | r.f(8)
+			}
+		}
+	}
+	if c.CRCCheckPresent = r.flag(); c.CRCCheckPresent {
+		r.f(8)
+	}
+	return
+}
+
+// ParseStreamMuxConfig 解析StreamMuxConfig，例如SDP中MP4A-LATM的config参数
+func ParseStreamMuxConfig(data []byte) (*StreamMuxConfig, error) {
+	// 部分设备的config截断在AudioSpecificConfig之后，补0作为默认值
+	r := newLatmReader(append(data[:len(data):len(data)], 0, 0))
+	c := r.streamMuxConfig()
+	return c, r.check()
+}
+
+// AudioMuxElement ISO/IEC 14496-3 1.7.3.1
+type AudioMuxElement struct {
+	Config   *StreamMuxConfig // 带内的StreamMuxConfig，没有时为nil
+	Payloads [][]byte         // 每个子帧的AU
+	Size     int              // AudioMuxElement按字节对齐后的长度
+}
+
+// ParseAudioMuxElement 解析一个AudioMuxElement，muxConfigPresent对应RFC 3016中的cpresent，config为当前生效的StreamMuxConfig
+func ParseAudioMuxElement(data []byte, muxConfigPresent bool, config *StreamMuxConfig) (el AudioMuxElement, err error) {
+	r := newLatmReader(data)
+	// useSameStreamMux
+	if muxConfigPresent && !r.flag() {
+		el.Config = r.streamMuxConfig()
+		config = el.Config
+	}
+	if err = r.check(); err != nil {
+		return
+	}
+	if config == nil || !config.AllStreamsSameTimeFraming {
+		return el, ErrLATM
+	}
+	for i := 0; i <= int(config.NumSubFrames) && r.err == nil; i++ {
+		// PayloadLengthInfo
+		var length uint
+		if config.FrameLengthType == 0 {
+			for tmp := uint(255); tmp == 255 && r.err == nil; length += tmp {
+				tmp = r.f(8)
+			}
+		} else {
+			length = config.FrameLength + 20
+		}
+		if r.pos+int(length)*8 > len(data)*8 {
+			return el, ErrLATM
+		}
+		// PayloadMux 按字节对齐时直接引用
+		if r.pos&7 == 0 {
+			el.Payloads = append(el.Payloads, data[r.pos>>3:r.pos>>3+int(length)])
+		} else {
+			el.Payloads = append(el.Payloads, r.bytes(r.pos, r.pos+int(length)*8))
+		}
+		r.skip(length * 8)
+	}
+	r.skip(config.OtherDataLenBits)
+	if err = r.check(); err != nil {
+		return
+	}
+	el.Size = (r.pos + 7) / 8
+	return
+}
+
+// LATMPayloadLengthInfo FrameLengthType为0时的PayloadLengthInfo
+func LATMPayloadLengthInfo(length int) (info []byte) {
+	for ; length >= 255; length -= 255 {
+		info = append(info, 255)
+	}
+	return append(info, byte(length))
+}
+
+type latmWriter struct {
+	data []byte
+	pos  int
+}
+
+func (w *latmWriter) put(v uint, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.pos&7 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[w.pos>>3] |= byte(v>>i&1) << (7 - w.pos&7)
+		w.pos++
+	}
+}
+
+// Bytes 生成AudioMuxVersion为0的StreamMuxConfig，用于SDP中的config参数
+func (c *StreamMuxConfig) Bytes() ([]byte, error) {
+	r := newLatmReader(c.AudioSpecificConfig)
+	if r.audioSpecificConfig(); r.err != nil {
+		return nil, r.check()
+	}
+	w := &latmWriter{}
+	w.put(0, 1)
+	if c.AllStreamsSameTimeFraming {
+		w.put(1, 1)
+	} else {
+		w.put(0, 1)
+	}
+	w.put(uint(c.NumSubFrames), 6)
+	w.put(0, 7)
+	for i := 0; i < r.pos; i++ {
+		w.put(uint(c.AudioSpecificConfig[i>>3]>>(7-i&7)), 1)
+	}
+	w.put(uint(c.FrameLengthType), 3)
+	if c.FrameLengthType == 0 {
+		w.put(uint(c.LatmBufferFullness), 8)
+	} else {
+		w.put(c.FrameLength, 9)
+	}
+	// otherDataPresent crcCheckPresent
+	w.put(0, 2)
+	return w.data, nil
+}
@@ -0,0 +1,79 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestStreamMuxConfig(t *testing.T) {
+	// AAC-LC 44100Hz 立体声，与RFC 3016中SDP的config=400024203fc0一致
+	asc := []byte{0x12, 0x10}
+	b, err := NewStreamMuxConfig(asc).Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x40, 0x00, 0x24, 0x20, 0x3f, 0xc0}
+	if !bytes.Equal(b, want) {
+		t.Fatalf("Bytes = %x, want %x", b, want)
+	}
+	c, err := ParseStreamMuxConfig(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.AllStreamsSameTimeFraming || c.NumSubFrames != 0 || !bytes.Equal(c.AudioSpecificConfig, asc) || c.FrameLengthType != 0 || c.LatmBufferFullness != 0xFF || c.OtherDataLenBits != 0 || c.CRCCheckPresent {
+		t.Errorf("ParseStreamMuxConfig = %+v", c)
+	}
+	// 截断在AudioSpecificConfig之后
+	if c, err = ParseStreamMuxConfig(want[:4]); err != nil || !bytes.Equal(c.AudioSpecificConfig, asc) {
+		t.Errorf("truncated: %+v, %v", c, err)
+	}
+	if _, err = ParseStreamMuxConfig([]byte{0x40}); err != ErrLATM {
+		t.Errorf("short = %v, want ErrLATM", err)
+	}
+	// AudioSpecificConfig不完整
+	if _, err = NewStreamMuxConfig([]byte{0x2b}).Bytes(); err != ErrLATM {
+		t.Errorf("truncated asc = %v, want ErrLATM", err)
+	}
+}
+
+func TestParseAudioMuxElement(t *testing.T) {
+	config := NewStreamMuxConfig([]byte{0x12, 0x10})
+	payload := make([]byte, 300)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+	data := append(LATMPayloadLengthInfo(len(payload)), payload...)
+	if !bytes.Equal(data[:2], []byte{0xff, 0x2d}) {
+		t.Fatalf("LATMPayloadLengthInfo = %x", data[:2])
+	}
+	el, err := ParseAudioMuxElement(data, false, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if el.Config != nil || len(el.Payloads) != 1 || !bytes.Equal(el.Payloads[0], payload) || el.Size != len(data) {
+		t.Errorf("ParseAudioMuxElement = %d payloads, size %d", len(el.Payloads), el.Size)
+	}
+	if _, err = ParseAudioMuxElement(data[:100], false, config); err != ErrLATM {
+		t.Errorf("truncated = %v, want ErrLATM", err)
+	}
+	if _, err = ParseAudioMuxElement(data, false, nil); err != ErrLATM {
+		t.Errorf("no config = %v, want ErrLATM", err)
+	}
+	// cpresent=1，带内的StreamMuxConfig后面的负载不按字节对齐
+	w := &latmWriter{}
+	w.put(0, 1) // useSameStreamMux
+	for _, b := range []byte{0x40, 0x00, 0x24, 0x20, 0x3f} {
+		w.put(uint(b), 8)
+	}
+	w.put(0x3, 2) // latmBufferFullness的最后两位
+	w.put(0, 2)   // otherDataPresent crcCheckPresent
+	w.put(3, 8)
+	w.put(0xABCDEF, 24)
+	el, err = ParseAudioMuxElement(w.data, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if el.Config == nil || el.Config.LatmBufferFullness != 0xFF || len(el.Payloads) != 1 || !bytes.Equal(el.Payloads[0], []byte{0xab, 0xcd, 0xef}) || el.Size != len(w.data) {
+		t.Errorf("in-band config: %+v %x", el.Config, el.Payloads)
+	}
+}
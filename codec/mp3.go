@@ -0,0 +1,72 @@
+package codec
+
+import "errors"
+
+var ErrMPEGAudio = errors.New("mpeg audio frame header error")
+
+// MPEGAudioHeader MPEG-1/2/2.5 Layer I/II/III 帧头 http://www.mp3-tech.org/programmer/frame_header.html
+type MPEGAudioHeader struct {
+	Version     byte // 3:MPEG-1 2:MPEG-2 0:MPEG-2.5
+	Layer       byte // 1:Layer I 2:Layer II 3:Layer III
+	Bitrate     int  // kbps，0表示free格式
+	SampleRate  int
+	Padding     byte
+	ChannelMode byte // 3为单声道
+	Channels    byte
+	FrameLength int // 整个帧的字节数（包括帧头），free格式时为0
+	Samples     int // 每帧的采样数
+}
+
+var mpegAudioBitrates = [...][16]int{
+	{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448}, // V1 L1
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},    // V1 L2
+	{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},     // V1 L3
+	{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},    // V2 L1
+	{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},         // V2 L2 L3
+}
+
+var mpegAudioSampleRates = [...]int{44100, 48000, 32000}
+
+// ParseMPEGAudioHeader 解析4字节的帧头
+func ParseMPEGAudioHeader(b []byte) (h MPEGAudioHeader, err error) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return h, ErrMPEGAudio
+	}
+	h.Version = (b[1] >> 3) & 0b11
+	h.Layer = 4 - (b[1]>>1)&0b11
+	bitrateIndex, sampleRateIndex := b[2]>>4, (b[2]>>2)&0b11
+	if h.Version == 1 || h.Layer == 4 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return h, ErrMPEGAudio
+	}
+	h.SampleRate = mpegAudioSampleRates[sampleRateIndex]
+	switch h.Version {
+	case 2:
+		h.SampleRate /= 2
+	case 0:
+		h.SampleRate /= 4
+	}
+	if h.Version == 3 {
+		h.Bitrate = mpegAudioBitrates[h.Layer-1][bitrateIndex]
+	} else if h.Layer == 1 {
+		h.Bitrate = mpegAudioBitrates[3][bitrateIndex]
+	} else {
+		h.Bitrate = mpegAudioBitrates[4][bitrateIndex]
+	}
+	h.Padding = (b[2] >> 1) & 1
+	h.ChannelMode = b[3] >> 6
+	if h.Channels = 2; h.ChannelMode == 3 {
+		h.Channels = 1
+	}
+	switch {
+	case h.Layer == 1:
+		h.Samples = 384
+		h.FrameLength = (12*h.Bitrate*1000/h.SampleRate + int(h.Padding)) * 4
+	case h.Layer == 3 && h.Version != 3:
+		h.Samples = 576
+		h.FrameLength = 72*h.Bitrate*1000/h.SampleRate + int(h.Padding)
+	default:
+		h.Samples = 1152
+		h.FrameLength = 144*h.Bitrate*1000/h.SampleRate + int(h.Padding)
+	}
+	return
+}
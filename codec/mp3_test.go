@@ -0,0 +1,26 @@
+package codec
+
+import "testing"
+
+func TestParseMPEGAudioHeader(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		b    []byte
+		want MPEGAudioHeader
+		err  error
+	}{
+		{"mpeg1 layer3 128k", []byte{0xff, 0xfb, 0x90, 0x64}, MPEGAudioHeader{Version: 3, Layer: 3, Bitrate: 128, SampleRate: 44100, ChannelMode: 1, Channels: 2, FrameLength: 417, Samples: 1152}, nil},
+		{"padding", []byte{0xff, 0xfb, 0x92, 0x64}, MPEGAudioHeader{Version: 3, Layer: 3, Bitrate: 128, SampleRate: 44100, Padding: 1, ChannelMode: 1, Channels: 2, FrameLength: 418, Samples: 1152}, nil},
+		{"mpeg2 layer3 mono", []byte{0xff, 0xf3, 0x64, 0xc4}, MPEGAudioHeader{Version: 2, Layer: 3, Bitrate: 48, SampleRate: 24000, ChannelMode: 3, Channels: 1, FrameLength: 144, Samples: 576}, nil},
+		{"mpeg1 layer2", []byte{0xff, 0xfd, 0xa4, 0x00}, MPEGAudioHeader{Version: 3, Layer: 2, Bitrate: 192, SampleRate: 48000, Channels: 2, FrameLength: 576, Samples: 1152}, nil},
+		{"mpeg1 layer1", []byte{0xff, 0xff, 0x40, 0x00}, MPEGAudioHeader{Version: 3, Layer: 1, Bitrate: 128, SampleRate: 44100, Channels: 2, FrameLength: 136, Samples: 384}, nil},
+		{"bad sync", []byte{0xff, 0x1b, 0x90, 0x64}, MPEGAudioHeader{}, ErrMPEGAudio},
+		{"reserved version", []byte{0xff, 0xeb, 0x90, 0x64}, MPEGAudioHeader{Version: 1, Layer: 3}, ErrMPEGAudio},
+		{"bad bitrate", []byte{0xff, 0xfb, 0xf0, 0x64}, MPEGAudioHeader{Version: 3, Layer: 3}, ErrMPEGAudio},
+		{"short", []byte{0xff, 0xfb}, MPEGAudioHeader{}, ErrMPEGAudio},
+	} {
+		if h, err := ParseMPEGAudioHeader(c.b); h != c.want || err != c.err {
+			t.Errorf("%s: ParseMPEGAudioHeader = %+v, %v, want %+v, %v", c.name, h, err, c.want, c.err)
+		}
+	}
+}
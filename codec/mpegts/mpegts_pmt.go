@@ -22,6 +22,9 @@ var (
 	aac      = []byte{STREAM_TYPE_AAC, 0xe0 | (PID_AUDIO >> 8), PID_AUDIO & 0xff, 0xf0, 0x00}
 	pcma     = []byte{STREAM_TYPE_G711A, 0xe0 | (PID_AUDIO >> 8), PID_AUDIO & 0xff, 0xf0, 0x00}
 	pcmu     = []byte{STREAM_TYPE_G711U, 0xe0 | (PID_AUDIO >> 8), PID_AUDIO & 0xff, 0xf0, 0x00}
+	mp3      = []byte{STREAM_TYPE_AUDIO_MPEG1, 0xe0 | (PID_AUDIO >> 8), PID_AUDIO & 0xff, 0xf0, 0x00}
+	ac3      = []byte{STREAM_TYPE_AC3, 0xe0 | (PID_AUDIO >> 8), PID_AUDIO & 0xff, 0xf0, 0x00}
+	eac3     = []byte{STREAM_TYPE_EAC3, 0xe0 | (PID_AUDIO >> 8), PID_AUDIO & 0xff, 0xf0, 0x00}
 	Stuffing []byte
 )
 
@@ -359,6 +362,12 @@ func WritePMTPacket(w io.Writer, videoCodec codec.VideoCodecID, audioCodec codec
 		pmt = append(pmt, pcma)
 	case codec.CodecID_PCMU:
 		pmt = append(pmt, pcmu)
+	case codec.CodecID_MP3:
+		pmt = append(pmt, mp3)
+	case codec.CodecID_AC3:
+		pmt = append(pmt, ac3)
+	case codec.CodecID_EAC3:
+		pmt = append(pmt, eac3)
 	default:
 		paddingSize += 5
 	}
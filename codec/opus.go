@@ -0,0 +1,106 @@
+package codec
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var ErrOpus = errors.New("opus parse error")
+
+// OpusHead https://datatracker.ietf.org/doc/html/rfc7845#section-5.1
+type OpusHead struct {
+	Version         byte
+	Channels        byte
+	PreSkip         uint16
+	InputSampleRate uint32
+	OutputGain      int16
+	MappingFamily   byte
+}
+
+var OpusHeadMagic = []byte("OpusHead")
+
+func NewOpusHead(channels byte) OpusHead {
+	return OpusHead{Version: 1, Channels: channels, PreSkip: 312, InputSampleRate: 48000}
+}
+
+// Marshal 只支持 MappingFamily 为0（单声道或立体声）
+func (h *OpusHead) Marshal() []byte {
+	b := make([]byte, 19)
+	copy(b, OpusHeadMagic)
+	b[8] = h.Version
+	b[9] = h.Channels
+	binary.LittleEndian.PutUint16(b[10:], h.PreSkip)
+	binary.LittleEndian.PutUint32(b[12:], h.InputSampleRate)
+	binary.LittleEndian.PutUint16(b[16:], uint16(h.OutputGain))
+	b[18] = h.MappingFamily
+	return b
+}
+
+func (h *OpusHead) Parse(b []byte) error {
+	if len(b) < 19 || string(b[:8]) != string(OpusHeadMagic) {
+		return ErrOpus
+	}
+	h.Version = b[8]
+	h.Channels = b[9]
+	h.PreSkip = binary.LittleEndian.Uint16(b[10:])
+	h.InputSampleRate = binary.LittleEndian.Uint32(b[12:])
+	h.OutputGain = int16(binary.LittleEndian.Uint16(b[16:]))
+	h.MappingFamily = b[18]
+	return nil
+}
+
+// OpusTOC https://datatracker.ietf.org/doc/html/rfc6716#section-3.1
+type OpusTOC byte
+
+func (toc OpusTOC) Config() byte {
+	return byte(toc) >> 3
+}
+
+func (toc OpusTOC) Stereo() bool {
+	return toc&0b100 != 0
+}
+
+// FrameCountCode 0:1帧 1:2帧等长 2:2帧不等长 3:任意帧数
+func (toc OpusTOC) FrameCountCode() byte {
+	return byte(toc) & 0b11
+}
+
+// FrameDuration 每一帧的时长，由config决定
+func (toc OpusTOC) FrameDuration() time.Duration {
+	config := toc.Config()
+	switch {
+	case config < 12: // SILK 10 20 40 60ms
+		return [...]time.Duration{10, 20, 40, 60}[config&0b11] * time.Millisecond
+	case config < 16: // Hybrid 10 20ms
+		return [...]time.Duration{10, 20}[config&0b1] * time.Millisecond
+	default: // CELT 2.5 5 10 20ms
+		return [...]time.Duration{2500, 5000, 10000, 20000}[config&0b11] * time.Microsecond
+	}
+}
+
+// OpusPacketDuration 计算一个Opus包包含的所有帧的总时长
+func OpusPacketDuration(packet []byte) (time.Duration, error) {
+	if len(packet) == 0 {
+		return 0, ErrOpus
+	}
+	toc := OpusTOC(packet[0])
+	var frames int
+	switch toc.FrameCountCode() {
+	case 0:
+		frames = 1
+	case 1, 2:
+		frames = 2
+	case 3:
+		if len(packet) < 2 {
+			return 0, ErrOpus
+		}
+		frames = int(packet[1] & 0x3F)
+	}
+	d := toc.FrameDuration() * time.Duration(frames)
+	// 一个包最长120ms
+	if frames == 0 || d > 120*time.Millisecond {
+		return 0, ErrOpus
+	}
+	return d, nil
+}
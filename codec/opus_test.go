@@ -0,0 +1,56 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestOpusHead(t *testing.T) {
+	// ffmpeg 生成的立体声 OpusHead
+	b := []byte{'O', 'p', 'u', 's', 'H', 'e', 'a', 'd', 0x01, 0x02, 0x38, 0x01, 0x80, 0xbb, 0x00, 0x00, 0x00, 0x00, 0x00}
+	var h OpusHead
+	if err := h.Parse(b); err != nil {
+		t.Fatal(err)
+	}
+	if h != NewOpusHead(2) {
+		t.Errorf("Parse = %+v", h)
+	}
+	if !bytes.Equal(h.Marshal(), b) {
+		t.Errorf("Marshal = %x", h.Marshal())
+	}
+	if err := h.Parse(b[:18]); err != ErrOpus {
+		t.Errorf("short = %v, want ErrOpus", err)
+	}
+	if err := h.Parse(append([]byte("OpusTags"), b[8:]...)); err != ErrOpus {
+		t.Errorf("bad magic = %v, want ErrOpus", err)
+	}
+}
+
+func TestOpusPacketDuration(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		packet []byte
+		want   time.Duration
+		err    error
+	}{
+		{"silk 10ms", []byte{0x00}, 10 * time.Millisecond, nil},
+		{"silk 60ms", []byte{0x18}, 60 * time.Millisecond, nil},
+		{"hybrid 20ms", []byte{0x78}, 20 * time.Millisecond, nil},
+		{"celt 2.5ms", []byte{0x80}, 2500 * time.Microsecond, nil},
+		{"celt 20ms stereo", []byte{0xfc}, 20 * time.Millisecond, nil},
+		{"two frames", []byte{0xfd}, 40 * time.Millisecond, nil},
+		{"code 3 three frames", []byte{0x0b, 0x03}, 60 * time.Millisecond, nil},
+		{"code 3 over 120ms", []byte{0x0b, 0x07}, 0, ErrOpus},
+		{"code 3 no frames", []byte{0x0b, 0x00}, 0, ErrOpus},
+		{"code 3 missing count", []byte{0x0b}, 0, ErrOpus},
+		{"empty", nil, 0, ErrOpus},
+	} {
+		if d, err := OpusPacketDuration(c.packet); d != c.want || err != c.err {
+			t.Errorf("%s: OpusPacketDuration = %v, %v, want %v, %v", c.name, d, err, c.want, c.err)
+		}
+	}
+	if toc := OpusTOC(0xfc); toc.Config() != 31 || !toc.Stereo() || toc.FrameCountCode() != 0 {
+		t.Errorf("toc 0xfc = %d %v %d", toc.Config(), toc.Stereo(), toc.FrameCountCode())
+	}
+}
@@ -0,0 +1,93 @@
+package codec
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// RFC 6381 中的codecs参数，用于HLS、DASH和浏览器MSE
+
+// VideoCodecString info中没有解析出profile等参数时只返回编码名称
+func VideoCodecString(codecID VideoCodecID, info *SPSInfo) string {
+	switch codecID {
+	case CodecID_H264:
+		if info.ProfileIdc == 0 {
+			return "avc1"
+		}
+		return fmt.Sprintf("avc1.%02x%02x%02x", info.ProfileIdc, info.ProfileCompatibility&0xFF, info.LevelIdc)
+	case CodecID_H265:
+		if info.ProfileIdc == 0 {
+			return "hvc1"
+		}
+		return hevcCodecString(info)
+	case CodecID_AV1:
+		// av01.P.LLT.DD
+		tier := "M"
+		if info.TierFlag == 1 {
+			tier = "H"
+		}
+		return fmt.Sprintf("av01.%d.%02d%s.%02d", info.ProfileIdc, info.LevelIdc, tier, info.BitDepthLuma)
+	case CodecID_VP8:
+		return "vp8"
+	case CodecID_VP9:
+		// vp09.PP.LL.DD
+		return fmt.Sprintf("vp09.%02d.%02d.%02d", info.ProfileIdc, info.LevelIdc, info.BitDepthLuma)
+	}
+	return codecID.String()
+}
+
+// hevcCodecString ISO/IEC 14496-15 E.3，例如hvc1.1.6.L120.90
+func hevcCodecString(info *SPSInfo) string {
+	var b strings.Builder
+	b.WriteString("hvc1.")
+	if info.ProfileSpace > 0 {
+		b.WriteByte(byte('A' + info.ProfileSpace - 1))
+	}
+	// profile_compatibility_flags 按位反序
+	fmt.Fprintf(&b, "%d.%X.", info.ProfileIdc, bits.Reverse32(info.ProfileCompatibility))
+	if info.TierFlag == 1 {
+		b.WriteByte('H')
+	} else {
+		b.WriteByte('L')
+	}
+	fmt.Fprintf(&b, "%d", info.LevelIdc)
+	// constraint_indicator_flags 每个字节一段，省略末尾为0的字节
+	constraint := make([]byte, 6)
+	for i := range constraint {
+		constraint[i] = byte(info.ConstraintIndicatorFlags >> (40 - 8*i))
+	}
+	for len(constraint) > 0 && constraint[len(constraint)-1] == 0 {
+		constraint = constraint[:len(constraint)-1]
+	}
+	for _, c := range constraint {
+		fmt.Fprintf(&b, ".%X", c)
+	}
+	return b.String()
+}
+
+// AudioCodecString objectType为AAC的AudioObjectType，其他编码忽略
+func AudioCodecString(codecID AudioCodecID, objectType byte) string {
+	switch codecID {
+	case CodecID_AAC:
+		if objectType == 0 {
+			objectType = 2
+		}
+		return fmt.Sprintf("mp4a.40.%d", objectType)
+	case CodecID_MP3:
+		return "mp4a.40.34"
+	case CodecID_AC3:
+		return "ac-3"
+	case CodecID_EAC3:
+		return "ec-3"
+	case CodecID_OPUS:
+		return "opus"
+	case CodecID_PCMA:
+		return "alaw"
+	case CodecID_PCMU:
+		return "ulaw"
+	case CodecID_LPCM:
+		return "lpcm"
+	}
+	return codecID.String()
+}
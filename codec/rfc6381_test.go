@@ -0,0 +1,62 @@
+package codec
+
+import "testing"
+
+func TestVideoCodecString(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		codecID VideoCodecID
+		info    SPSInfo
+		want    string
+	}{
+		{"h264 unknown profile", CodecID_H264, SPSInfo{}, "avc1"},
+		{"h264 constraint flags", CodecID_H264, SPSInfo{ProfileIdc: 66, ProfileCompatibility: 0xE0, LevelIdc: 31}, "avc1.42e01f"},
+		// general_profile_compatibility_flag[j] 对应第31-j位，反序后以十六进制输出
+		{"hevc main", CodecID_H265, SPSInfo{ProfileIdc: 1, ProfileCompatibility: 0x60000000, LevelIdc: 93, ConstraintIndicatorFlags: 0x900000000000}, "hvc1.1.6.L93.90"},
+		{"hevc main 10", CodecID_H265, SPSInfo{ProfileIdc: 2, ProfileCompatibility: 0x20000000, LevelIdc: 150, TierFlag: 1, ConstraintIndicatorFlags: 0xB00000000000}, "hvc1.2.4.H150.B0"},
+		{"hevc compat flag 31", CodecID_H265, SPSInfo{ProfileIdc: 1, ProfileCompatibility: 0x00000001, LevelIdc: 120}, "hvc1.1.80000000.L120"},
+		{"hevc profile space", CodecID_H265, SPSInfo{ProfileSpace: 1, ProfileIdc: 1, ProfileCompatibility: 0x40000000, LevelIdc: 120, ConstraintIndicatorFlags: 0x000100000000}, "hvc1.A1.2.L120.0.1"},
+		// seq_level_idx 5 即 level 3.1
+		{"av1 level 3.1", CodecID_AV1, SPSInfo{LevelIdc: 5, BitDepthLuma: 8}, "av01.0.05M.08"},
+		{"av1 level 5.1 high tier", CodecID_AV1, SPSInfo{ProfileIdc: 0, LevelIdc: 13, TierFlag: 1, BitDepthLuma: 10}, "av01.0.13H.10"},
+		{"av1 professional", CodecID_AV1, SPSInfo{ProfileIdc: 2, LevelIdc: 19, BitDepthLuma: 12}, "av01.2.19M.12"},
+		{"vp8", CodecID_VP8, SPSInfo{}, "vp8"},
+		{"vp9", CodecID_VP9, SPSInfo{LevelIdc: 31, BitDepthLuma: 8}, "vp09.00.31.08"},
+	} {
+		if s := VideoCodecString(c.codecID, &c.info); s != c.want {
+			t.Errorf("%s: VideoCodecString = %s, want %s", c.name, s, c.want)
+		}
+	}
+	// 从真实的序列头得到codec string
+	seq, _ := ParseAV1SequenceHeader(av1SequenceHeaderOBU[1:])
+	info := SPSInfo{ProfileIdc: uint(seq.SeqProfile), LevelIdc: uint(seq.SeqLevelIdx0), TierFlag: uint(seq.SeqTier0), BitDepthLuma: uint(seq.BitDepth)}
+	if s := VideoCodecString(CodecID_AV1, &info); s != "av01.0.05M.08" {
+		t.Errorf("av1 sequence header: VideoCodecString = %s", s)
+	}
+	h, _ := ParseVP9FrameHeader([]byte{0x92, 0x49, 0x83, 0x42, 0x20, 0x3b, 0xf8, 0x21, 0xb8})
+	r := h.VPCC()
+	if s := VideoCodecString(CodecID_VP9, &SPSInfo{ProfileIdc: uint(r.Profile), LevelIdc: uint(r.Level), BitDepthLuma: uint(r.BitDepth)}); s != "vp09.02.40.10" {
+		t.Errorf("vp9 frame header: VideoCodecString = %s", s)
+	}
+}
+
+func TestAudioCodecString(t *testing.T) {
+	for _, c := range []struct {
+		codecID    AudioCodecID
+		objectType byte
+		want       string
+	}{
+		{CodecID_AAC, 0, "mp4a.40.2"},
+		{CodecID_AAC, 5, "mp4a.40.5"},
+		{CodecID_MP3, 0, "mp4a.40.34"},
+		{CodecID_AC3, 0, "ac-3"},
+		{CodecID_EAC3, 0, "ec-3"},
+		{CodecID_OPUS, 0, "opus"},
+		{CodecID_PCMA, 0, "alaw"},
+		{CodecID_PCMU, 0, "ulaw"},
+	} {
+		if s := AudioCodecString(c.codecID, c.objectType); s != c.want {
+			t.Errorf("AudioCodecString(%s, %d) = %s, want %s", c.codecID, c.objectType, s, c.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package codec
+
+import "errors"
+
+var ErrSEI = errors.New("sei parse error")
+
+type SEIPayloadType uint
+
+const (
+	SEI_PIC_TIMING                     SEIPayloadType = 1
+	SEI_USER_DATA_REGISTERED_ITU_T_T35 SEIPayloadType = 4
+	SEI_USER_DATA_UNREGISTERED         SEIPayloadType = 5
+)
+
+// SEIMessage 一个sei_message，Payload已经去掉了防竞争字节
+type SEIMessage struct {
+	PayloadType SEIPayloadType
+	Payload     []byte
+}
+
+// ParseSEI 解析一个SEI NALU（包括NAL头）中的所有sei_message，H265的NAL头为两个字节
+func ParseSEI(nalu []byte, hevc bool) (msgs []SEIMessage, err error) {
+	headerSize := 1
+	if hevc {
+		headerSize = 2
+	}
+	if len(nalu) <= headerSize {
+		return nil, ErrSEI
+	}
+	rbsp := nal2rbsp(nalu[headerSize:])
+	// 最后一个字节为 rbsp_trailing_bits
+	for len(rbsp) > 1 || (len(rbsp) == 1 && rbsp[0] != 0x80) {
+		var payloadType, payloadSize int
+		for len(rbsp) > 0 && rbsp[0] == 0xFF {
+			payloadType += 0xFF
+			rbsp = rbsp[1:]
+		}
+		if len(rbsp) == 0 {
+			return msgs, ErrSEI
+		}
+		payloadType += int(rbsp[0])
+		rbsp = rbsp[1:]
+		for len(rbsp) > 0 && rbsp[0] == 0xFF {
+			payloadSize += 0xFF
+			rbsp = rbsp[1:]
+		}
+		if len(rbsp) == 0 {
+			return msgs, ErrSEI
+		}
+		payloadSize += int(rbsp[0])
+		rbsp = rbsp[1:]
+		if payloadSize > len(rbsp) {
+			return msgs, ErrSEI
+		}
+		msgs = append(msgs, SEIMessage{SEIPayloadType(payloadType), rbsp[:payloadSize]})
+		rbsp = rbsp[payloadSize:]
+	}
+	return
+}
+
+// SEIUserDataUnregistered user_data_unregistered，通常用来携带编码器自定义的数据
+type SEIUserDataUnregistered struct {
+	UUID [16]byte
+	Data []byte
+}
+
+func ParseSEIUserDataUnregistered(payload []byte) (u SEIUserDataUnregistered, err error) {
+	if len(payload) < 16 {
+		return u, ErrSEI
+	}
+	copy(u.UUID[:], payload)
+	u.Data = payload[16:]
+	return
+}
+
+// SEIUserDataRegistered user_data_registered_itu_t_t35，例如CEA-608/708字幕、HDR10+
+type SEIUserDataRegistered struct {
+	CountryCode          byte
+	CountryCodeExtension byte // CountryCode为0xFF时才有
+	Data                 []byte
+}
+
+func ParseSEIUserDataRegistered(payload []byte) (r SEIUserDataRegistered, err error) {
+	if len(payload) < 1 {
+		return r, ErrSEI
+	}
+	r.CountryCode, r.Data = payload[0], payload[1:]
+	if r.CountryCode == 0xFF {
+		if len(r.Data) < 1 {
+			return r, ErrSEI
+		}
+		r.CountryCodeExtension, r.Data = r.Data[0], r.Data[1:]
+	}
+	return
+}
+
+// PicTimingInfo 解析pic_timing需要的SPS（VUI）中的参数
+type PicTimingInfo struct {
+	CpbDpbDelaysPresent    bool // nal_hrd_parameters_present_flag 或 vcl_hrd_parameters_present_flag
+	CpbRemovalDelayLength  int
+	DpbOutputDelayLength   int
+	PicStructPresent       bool // H264为pic_struct_present_flag，H265为frame_field_info_present_flag
+	TimeOffsetLength       int  // 仅H264
+	SubPicHrdParamsPresent bool // 仅H265
+	DpbOutputDelayDuLength int  // 仅H265
+}
+
+// SEITimecode H264 pic_timing 中的 clock_timestamp
+type SEITimecode struct {
+	CtType             byte
+	NuitFieldBasedFlag bool
+	CountingType       byte
+	FullTimestampFlag  bool
+	DiscontinuityFlag  bool
+	CntDroppedFlag     bool
+	NFrames            byte
+	Seconds            byte
+	Minutes            byte
+	Hours              byte
+	TimeOffset         int
+}
+
+// SEIPicTiming pic_timing
+type SEIPicTiming struct {
+	CpbRemovalDelay  uint
+	DpbOutputDelay   uint
+	PicStructPresent bool
+	PicStruct        byte
+	SourceScanType   byte          // 仅H265
+	DuplicateFlag    bool          // 仅H265
+	Timecodes        []SEITimecode // 仅H264
+}
+
+// ParseH264PicTiming ITU-T H.264 D.1.3
+func ParseH264PicTiming(payload []byte, info PicTimingInfo) (t SEIPicTiming, err error) {
+	r := newBitReader(payload)
+	if info.CpbDpbDelaysPresent {
+		t.CpbRemovalDelay = r.f(info.CpbRemovalDelayLength)
+		t.DpbOutputDelay = r.f(info.DpbOutputDelayLength)
+	}
+	if t.PicStructPresent = info.PicStructPresent; t.PicStructPresent {
+		if t.PicStruct = byte(r.f(4)); t.PicStruct > 8 {
+			return t, ErrSEI
+		}
+		numClockTS := [...]int{1, 1, 1, 2, 2, 3, 3, 2, 3}[t.PicStruct]
+		for i := 0; i < numClockTS && r.err == nil; i++ {
+			// clock_timestamp_flag
+			if !r.flag() {
+				continue
+			}
+			var tc SEITimecode
+			tc.CtType = byte(r.f(2))
+			tc.NuitFieldBasedFlag = r.flag()
+			tc.CountingType = byte(r.f(5))
+			tc.FullTimestampFlag = r.flag()
+			tc.DiscontinuityFlag = r.flag()
+			tc.CntDroppedFlag = r.flag()
+			tc.NFrames = byte(r.f(8))
+			if tc.FullTimestampFlag {
+				tc.Seconds = byte(r.f(6))
+				tc.Minutes = byte(r.f(6))
+				tc.Hours = byte(r.f(5))
+			} else if r.flag() {
+				if tc.Seconds = byte(r.f(6)); r.flag() {
+					if tc.Minutes = byte(r.f(6)); r.flag() {
+						tc.Hours = byte(r.f(5))
+					}
+				}
+			}
+			if n := info.TimeOffsetLength; n > 0 {
+				// 有符号数
+				tc.TimeOffset = int(r.f(n))
+				if tc.TimeOffset >= 1<<(n-1) {
+					tc.TimeOffset -= 1 << n
+				}
+			}
+			t.Timecodes = append(t.Timecodes, tc)
+		}
+	}
+	if r.err != nil {
+		err = ErrSEI
+	}
+	return
+}
+
+// ParseH265PicTiming ITU-T H.265 D.2.3，不解析解码单元相关的字段
+func ParseH265PicTiming(payload []byte, info PicTimingInfo) (t SEIPicTiming, err error) {
+	r := newBitReader(payload)
+	if t.PicStructPresent = info.PicStructPresent; t.PicStructPresent {
+		t.PicStruct = byte(r.f(4))
+		t.SourceScanType = byte(r.f(2))
+		t.DuplicateFlag = r.flag()
+	}
+	if info.CpbDpbDelaysPresent {
+		// au_cpb_removal_delay_minus1
+		t.CpbRemovalDelay = r.f(info.CpbRemovalDelayLength) + 1
+		t.DpbOutputDelay = r.f(info.DpbOutputDelayLength)
+	}
+	if r.err != nil {
+		err = ErrSEI
+	}
+	return
+}
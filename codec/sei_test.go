@@ -0,0 +1,87 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+// x264 写入的user_data_unregistered
+var x264UUID = [16]byte{0xdc, 0x45, 0xe9, 0xbd, 0xe6, 0xd9, 0x48, 0xb7, 0x96, 0x2c, 0xd8, 0x20, 0xd9, 0x23, 0xee, 0xef}
+
+func TestParseSEI(t *testing.T) {
+	text := []byte("x264 - core 164 r3095 baee400 - H.264/MPEG-4 AVC codec - Copyleft 2003-2022 - http://www.videolan.org/x264.html - options: cabac=1 ref=3 deblock=1:0:0 analyse=0x3:0x113 me=hex subme=7 psy=1 psy_rd=1.00:0.00 mixed_ref=1 me_range=16 chroma_me=1 trellis=1 8x8dct=1 cqm=0 deadzone=21,11 fast_pskip=1 chroma_qp_offset=-2 threads=12")
+	size := 16 + len(text) + 1
+	nalu := []byte{0x06, byte(SEI_USER_DATA_UNREGISTERED)}
+	// payloadSize 大于255时使用多个字节
+	for ; size >= 255; size -= 255 {
+		nalu = append(nalu, 0xff)
+	}
+	nalu = append(nalu, byte(size))
+	nalu = append(append(append(nalu, x264UUID[:]...), text...), 0x00, 0x80)
+	msgs, err := ParseSEI(nalu, false)
+	if err != nil || len(msgs) != 1 || msgs[0].PayloadType != SEI_USER_DATA_UNREGISTERED {
+		t.Fatalf("ParseSEI = %+v, %v", msgs, err)
+	}
+	u, err := ParseSEIUserDataUnregistered(msgs[0].Payload)
+	if err != nil || u.UUID != x264UUID || !bytes.Equal(u.Data[:len(text)], text) {
+		t.Errorf("ParseSEIUserDataUnregistered = %x %q, %v", u.UUID, u.Data, err)
+	}
+	if _, err = ParseSEIUserDataUnregistered(msgs[0].Payload[:15]); err != ErrSEI {
+		t.Errorf("short = %v, want ErrSEI", err)
+	}
+
+	// H265 前缀SEI，两个sei_message，负载中有防竞争字节
+	msgs, err = ParseSEI([]byte{0x4e, 0x01, 0x01, 0x03, 0x00, 0x00, 0x03, 0x01, 0x04, 0x02, 0xb5, 0x00, 0x80}, true)
+	if err != nil || len(msgs) != 2 || !bytes.Equal(msgs[0].Payload, []byte{0x00, 0x00, 0x01}) || msgs[1].PayloadType != SEI_USER_DATA_REGISTERED_ITU_T_T35 || !bytes.Equal(msgs[1].Payload, []byte{0xb5, 0x00}) {
+		t.Errorf("ParseSEI(hevc) = %+v, %v", msgs, err)
+	}
+	for _, nalu := range [][]byte{{0x06}, {0x06, 0x05}, {0x06, 0x05, 0x10, 0x00}, {0x06, 0xff}} {
+		if _, err = ParseSEI(nalu, false); err != ErrSEI {
+			t.Errorf("ParseSEI(%x) = %v, want ErrSEI", nalu, err)
+		}
+	}
+}
+
+func TestParseH264PicTiming(t *testing.T) {
+	// nvenc 的SPS中的参数
+	info := PicTimingInfo{CpbDpbDelaysPresent: true, CpbRemovalDelayLength: 16, DpbOutputDelayLength: 6, PicStructPresent: true, TimeOffsetLength: 24}
+	w := &latmWriter{}
+	w.put(2, 16)       // cpb_removal_delay
+	w.put(4, 6)        // dpb_output_delay
+	w.put(0, 4)        // pic_struct 帧
+	w.put(1, 1)        // clock_timestamp_flag
+	w.put(0, 2)        // ct_type
+	w.put(0, 1)        // nuit_field_based_flag
+	w.put(0, 5)        // counting_type
+	w.put(1, 1)        // full_timestamp_flag
+	w.put(0, 2)        // discontinuity_flag cnt_dropped_flag
+	w.put(5, 8)        // n_frames
+	w.put(10, 6)       // seconds_value
+	w.put(20, 6)       // minutes_value
+	w.put(1, 5)        // hours_value
+	w.put(1<<24-3, 24) // time_offset -3
+	w.put(1, 1)
+	timing, err := ParseH264PicTiming(w.data, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SEITimecode{FullTimestampFlag: true, NFrames: 5, Seconds: 10, Minutes: 20, Hours: 1, TimeOffset: -3}
+	if timing.CpbRemovalDelay != 2 || timing.DpbOutputDelay != 4 || !timing.PicStructPresent || len(timing.Timecodes) != 1 || timing.Timecodes[0] != want {
+		t.Errorf("ParseH264PicTiming = %+v", timing)
+	}
+	if _, err = ParseH264PicTiming(w.data[:5], info); err != ErrSEI {
+		t.Errorf("truncated = %v, want ErrSEI", err)
+	}
+	if _, err = ParseH264PicTiming([]byte{0x90}, PicTimingInfo{PicStructPresent: true}); err != ErrSEI {
+		t.Errorf("pic_struct 9 = %v, want ErrSEI", err)
+	}
+}
+
+func TestParseH265PicTiming(t *testing.T) {
+	info := PicTimingInfo{CpbDpbDelaysPresent: true, CpbRemovalDelayLength: 8, DpbOutputDelayLength: 8, PicStructPresent: true}
+	// pic_struct=1 source_scan_type=1 duplicate_flag=0 au_cpb_removal_delay_minus1=1 pic_dpb_output_delay=2
+	timing, err := ParseH265PicTiming([]byte{0x14, 0x02, 0x04}, info)
+	if err != nil || timing.PicStruct != 1 || timing.SourceScanType != 1 || timing.DuplicateFlag || timing.CpbRemovalDelay != 2 || timing.DpbOutputDelay != 2 {
+		t.Errorf("ParseH265PicTiming = %+v, %v", timing, err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/cnotch/ipchub/av/codec/hevc"
 
+	"m7s.live/engine/v4/util"
 	"m7s.live/engine/v4/util/bits"
 )
 
@@ -22,10 +23,25 @@ type SPSInfo struct {
 
 	Width  uint
 	Height uint
+
+	FrameRate float64 // VUI中的timing_info，没有时为0
+
+	ChromaFormatIdc uint // 0:单色 1:4:2:0 2:4:2:2 3:4:4:4
+	BitDepthLuma    uint
+	BitDepthChroma  uint
+
+	// H264为constraint_set0_flag到constraint_set5_flag所在的字节，H265为general_profile_compatibility_flags
+	ProfileCompatibility uint32
+	// 以下仅H265（AV1只使用TierFlag）
+	ProfileSpace             uint
+	TierFlag                 uint
+	ConstraintIndicatorFlags uint64 // general_constraint_indicator_flags，48位
+
+	PicTiming PicTimingInfo `json:"-" yaml:"-"` // VUI中解析pic_timing需要的参数
 }
 
 func ParseSPS(data []byte) (self SPSInfo, err error) {
-	r := &bits.GolombBitReader{R: bytes.NewReader(data)}
+	r := &bits.GolombBitReader{R: bytes.NewReader(nal2rbsp(data))}
 
 	if _, err = r.ReadBits(8); err != nil {
 		return
@@ -35,10 +51,14 @@ func ParseSPS(data []byte) (self SPSInfo, err error) {
 		return
 	}
 
-	// constraint_set0_flag-constraint_set6_flag,reserved_zero_2bits
-	if _, err = r.ReadBits(8); err != nil {
+	// constraint_set0_flag-constraint_set5_flag,reserved_zero_2bits
+	var constraint uint
+	if constraint, err = r.ReadBits(8); err != nil {
 		return
 	}
+	self.ProfileCompatibility = uint32(constraint)
+	// 没有出现时的默认值
+	self.ChromaFormatIdc, self.BitDepthLuma, self.BitDepthChroma = 1, 8, 8
 
 	// level_idc
 	if self.LevelIdc, err = r.ReadBits(8); err != nil {
@@ -55,26 +75,28 @@ func ParseSPS(data []byte) (self SPSInfo, err error) {
 		self.ProfileIdc == 44 || self.ProfileIdc == 83 ||
 		self.ProfileIdc == 86 || self.ProfileIdc == 118 {
 
-		var chroma_format_idc uint
-		if chroma_format_idc, err = r.ReadExponentialGolombCode(); err != nil {
+		if self.ChromaFormatIdc, err = r.ReadExponentialGolombCode(); err != nil {
 			return
 		}
 
-		if chroma_format_idc == 3 {
+		if self.ChromaFormatIdc == 3 {
 			// residual_colour_transform_flag
 			if _, err = r.ReadBit(); err != nil {
 				return
 			}
 		}
 
+		var bitDepthMinus8 uint
 		// bit_depth_luma_minus8
-		if _, err = r.ReadExponentialGolombCode(); err != nil {
+		if bitDepthMinus8, err = r.ReadExponentialGolombCode(); err != nil {
 			return
 		}
+		self.BitDepthLuma = bitDepthMinus8 + 8
 		// bit_depth_chroma_minus8
-		if _, err = r.ReadExponentialGolombCode(); err != nil {
+		if bitDepthMinus8, err = r.ReadExponentialGolombCode(); err != nil {
 			return
 		}
+		self.BitDepthChroma = bitDepthMinus8 + 8
 		// qpprime_y_zero_transform_bypass_flag
 		if _, err = r.ReadBit(); err != nil {
 			return
@@ -213,6 +235,76 @@ func ParseSPS(data []byte) (self SPSInfo, err error) {
 	self.Width = (self.MbWidth * 16) - self.CropLeft*2 - self.CropRight*2
 	self.Height = ((2 - frame_mbs_only_flag) * self.MbHeight * 16) - self.CropTop*2 - self.CropBottom*2
 
+	var vui_parameters_present_flag uint
+	if vui_parameters_present_flag, err = r.ReadBit(); err != nil {
+		return
+	}
+	if vui_parameters_present_flag != 0 {
+		self.PicTiming, self.FrameRate, err = parseH264VUI(&bitReader{GolombBitReader: *r})
+	}
+	return
+}
+
+// parseH264VUI 只取出解析pic_timing需要的参数和帧率 ITU-T H.264 E.1.1
+func parseH264VUI(r *bitReader) (info PicTimingInfo, frameRate float64, err error) {
+	// aspect_ratio_info_present_flag
+	if r.flag() {
+		// aspect_ratio_idc Extended_SAR
+		if r.f(8) == 255 {
+			r.f(32) // sar_width sar_height
+		}
+	}
+	// overscan_info_present_flag
+	if r.flag() {
+		r.f(1)
+	}
+	// video_signal_type_present_flag
+	if r.flag() {
+		// video_format video_full_range_flag colour_description_present_flag
+		if r.f(4); r.flag() {
+			r.f(24)
+		}
+	}
+	// chroma_loc_info_present_flag
+	if r.flag() {
+		r.ue()
+		r.ue()
+	}
+	// timing_info_present_flag
+	if r.flag() {
+		numUnitsInTick, timeScale := r.f(32), r.f(32)
+		r.f(1) // fixed_frame_rate_flag
+		// 一帧为两个场，每个场一个tick
+		if numUnitsInTick > 0 {
+			frameRate = float64(timeScale) / float64(numUnitsInTick*2)
+		}
+	}
+	hrd := func() {
+		cpbCnt := r.ue() + 1
+		r.f(8) // bit_rate_scale cpb_size_scale
+		for i := uint(0); i < cpbCnt && r.err == nil; i++ {
+			r.ue()
+			r.ue()
+			r.f(1)
+		}
+		r.f(5) // initial_cpb_removal_delay_length_minus1
+		info.CpbRemovalDelayLength = int(r.f(5)) + 1
+		info.DpbOutputDelayLength = int(r.f(5)) + 1
+		info.TimeOffsetLength = int(r.f(5))
+	}
+	nalHrd := r.flag()
+	if nalHrd {
+		hrd()
+	}
+	vclHrd := r.flag()
+	if vclHrd {
+		hrd()
+	}
+	if info.CpbDpbDelaysPresent = nalHrd || vclHrd; info.CpbDpbDelaysPresent {
+		r.f(1) // low_delay_hrd_flag
+	}
+	info.PicStructPresent = r.flag()
+	err = r.err
 	return
 }
 
@@ -222,6 +314,37 @@ func ParseHevcSPS(data []byte) (self SPSInfo, err error) {
 		self.CropLeft, self.CropRight, self.CropTop, self.CropBottom = uint(rawsps.Conf_win_left_offset), uint(rawsps.Conf_win_right_offset), uint(rawsps.Conf_win_top_offset), uint(rawsps.Conf_win_bottom_offset)
 		self.Width = uint(rawsps.Pic_width_in_luma_samples)
 		self.Height = uint(rawsps.Pic_height_in_luma_samples)
+		self.ChromaFormatIdc = uint(rawsps.Chroma_format_idc)
+		self.BitDepthLuma = uint(rawsps.Bit_depth_luma_minus8) + 8
+		self.BitDepthChroma = uint(rawsps.Bit_depth_chroma_minus8) + 8
+		self.FrameRate = rawsps.FrameRate()
+		err = parseHevcGeneralPTL(data, &self)
+		if vui := &rawsps.Vui; rawsps.Vui_parameters_present_flag == 1 {
+			self.PicTiming.PicStructPresent = vui.Frame_field_info_present_flag == 1
+			if hrd := &vui.Hrd_parameters; vui.Vui_hrd_parameters_present_flag == 1 {
+				self.PicTiming.CpbDpbDelaysPresent = hrd.Nal_hrd_parameters_present_flag == 1 || hrd.Vcl_hrd_parameters_present_flag == 1
+				self.PicTiming.CpbRemovalDelayLength = int(hrd.Au_cpb_removal_delay_length_minus1) + 1
+				self.PicTiming.DpbOutputDelayLength = int(hrd.Dpb_output_delay_length_minus1) + 1
+				self.PicTiming.SubPicHrdParamsPresent = hrd.Sub_pic_hrd_params_present_flag == 1
+				self.PicTiming.DpbOutputDelayDuLength = int(hrd.Dpb_output_delay_du_length_minus1) + 1
+			}
+		}
 	}
 	return
 }
+
+// parseHevcGeneralPTL 解析SPS中profile_tier_level的general部分，固定在nalu头之后的第2到第13字节 ITU-T H.265 7.3.3
+func parseHevcGeneralPTL(data []byte, self *SPSInfo) error {
+	rbsp := nal2rbsp(data)
+	if len(rbsp) < 15 {
+		return ErrHevc
+	}
+	ptl := rbsp[3:15]
+	self.ProfileSpace = uint(ptl[0] >> 6)
+	self.TierFlag = uint(ptl[0] >> 5 & 1)
+	self.ProfileIdc = uint(ptl[0] & 0x1F)
+	self.ProfileCompatibility = util.ReadBE[uint32](ptl[1:5])
+	self.ConstraintIndicatorFlags = util.ReadBE[uint64](ptl[5:11])
+	self.LevelIdc = uint(ptl[11])
+	return nil
+}
@@ -0,0 +1,96 @@
+package codec
+
+import "testing"
+
+func TestParseSPS(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		sps           []byte
+		width, height uint
+		frameRate     float64
+		codec         string
+		picTiming     PicTimingInfo
+	}{
+		{
+			"352x288",
+			[]byte{0x67, 0x64, 0x00, 0x0c, 0xac, 0x3b, 0x50, 0xb0, 0x4b, 0x42, 0x00, 0x00, 0x03, 0x00, 0x02, 0x00, 0x00, 0x03, 0x00, 0x3d, 0x08},
+			352, 288, 15, "avc1.64000c", PicTimingInfo{},
+		},
+		{
+			"baseline constraint set",
+			[]byte{0x67, 0x42, 0xc0, 0x28, 0xd9, 0x00, 0x78, 0x02, 0x27, 0xe5, 0x84, 0x00, 0x00, 0x03, 0x00, 0x04, 0x00, 0x00, 0x03, 0x00, 0xf0, 0x3c, 0x60, 0xc9, 0x20},
+			1920, 1080, 30, "avc1.42c028", PicTimingInfo{},
+		},
+		{
+			"interlaced",
+			[]byte{0x67, 0x64, 0x00, 0x29, 0xac, 0x13, 0x31, 0x40, 0x78, 0x04, 0x47, 0xde, 0x03, 0xea, 0x02, 0x02, 0x03, 0xe0, 0x00, 0x00, 0x03, 0x00, 0x20, 0x00, 0x00, 0x06, 0x52},
+			1920, 1084, 25, "avc1.640029", PicTimingInfo{PicStructPresent: true},
+		},
+		{
+			"nvenc nal hrd",
+			[]byte{103, 100, 0, 42, 172, 44, 172, 7, 128, 34, 126, 92, 5, 168, 8, 8, 10, 0, 0, 7, 208, 0, 3, 169, 129, 192, 0, 0, 76, 75, 0, 0, 38, 37, 173, 222, 92, 20},
+			1920, 1080, 60, "avc1.64002a", PicTimingInfo{CpbDpbDelaysPresent: true, CpbRemovalDelayLength: 16, DpbOutputDelayLength: 6, PicStructPresent: true, TimeOffsetLength: 24},
+		},
+		{
+			"hikvision nal and vcl hrd",
+			[]byte{103, 77, 0, 41, 154, 100, 3, 192, 17, 63, 46, 2, 220, 4, 4, 5, 0, 0, 3, 3, 232, 0, 0, 195, 80, 232, 96, 0, 186, 180, 0, 2, 234, 196, 187, 203, 141, 12, 0, 23, 86, 128, 0, 93, 88, 151, 121, 112, 160},
+			1920, 1080, 25, "avc1.4d0029", PicTimingInfo{CpbDpbDelaysPresent: true, CpbRemovalDelayLength: 16, DpbOutputDelayLength: 6, PicStructPresent: true, TimeOffsetLength: 24},
+		},
+	} {
+		info, err := ParseSPS(c.sps)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if info.Width != c.width || info.Height != c.height || info.FrameRate != c.frameRate || info.PicTiming != c.picTiming {
+			t.Errorf("%s: %dx%d %v %+v, want %dx%d %v %+v", c.name, info.Width, info.Height, info.FrameRate, info.PicTiming, c.width, c.height, c.frameRate, c.picTiming)
+		}
+		if s := VideoCodecString(CodecID_H264, &info); s != c.codec {
+			t.Errorf("%s: codec = %s, want %s", c.name, s, c.codec)
+		}
+	}
+}
+
+func TestParseHevcSPS(t *testing.T) {
+	for _, c := range []struct {
+		name          string
+		sps           []byte
+		width, height uint
+		bitDepth      uint
+		chromaFormat  uint
+		tier          uint
+		codec         string
+	}{
+		{
+			"main 1080p",
+			[]byte{0x42, 0x01, 0x01, 0x01, 0x60, 0x00, 0x00, 0x03, 0x00, 0x90, 0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x78, 0xa0, 0x03, 0xc0, 0x80, 0x10, 0xe5, 0x96, 0x66, 0x69, 0x24, 0xca, 0xe0, 0x10, 0x00, 0x00, 0x03, 0x00, 0x10, 0x00, 0x00, 0x03, 0x01, 0xe0, 0x80},
+			1920, 1080, 8, 1, 0, "hvc1.1.6.L120.90",
+		},
+		{
+			// profile_compatibility_flags 中第4位置1，反序后为0x10
+			"range extensions 4:4:4",
+			[]byte{0x42, 0x01, 0x01, 0x04, 0x08, 0x00, 0x00, 0x03, 0x00, 0x98, 0x08, 0x00, 0x00, 0x03, 0x00, 0x00, 0x5d, 0x90, 0x00, 0x50, 0x10, 0x05, 0xa2, 0x29, 0x4b, 0x74, 0x94, 0x98, 0x5f, 0xfe, 0x00, 0x02, 0x00, 0x02, 0xd4, 0x04, 0x04, 0x04, 0x10, 0x00, 0x00, 0x03, 0x00, 0x10, 0x00, 0x00, 0x03, 0x01, 0xe0, 0x80},
+			1280, 720, 12, 3, 0, "hvc1.4.10.L93.98.8",
+		},
+		{
+			"main 10 high tier",
+			[]byte{0x42, 0x01, 0x01, 0x22, 0x20, 0x00, 0x00, 0x03, 0x00, 0x90, 0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x78, 0xa0, 0x03, 0xc0, 0x80, 0x10, 0xe4, 0xd9, 0x66, 0x66, 0x92, 0x4c, 0xaf, 0x01, 0x01, 0x00, 0x00, 0x03, 0x00, 0x64, 0x00, 0x00, 0x0b, 0xb5, 0x08},
+			1920, 1080, 10, 1, 1, "hvc1.2.4.H120.90",
+		},
+	} {
+		info, err := ParseHevcSPS(c.sps)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if info.Width != c.width || info.Height != c.height || info.BitDepthLuma != c.bitDepth || info.ChromaFormatIdc != c.chromaFormat || info.TierFlag != c.tier {
+			t.Errorf("%s: %+v", c.name, info)
+		}
+		if s := VideoCodecString(CodecID_H265, &info); s != c.codec {
+			t.Errorf("%s: codec = %s, want %s", c.name, s, c.codec)
+		}
+	}
+	if _, err := ParseHevcSPS([]byte{0x42, 0x01, 0x01, 0x01}); err == nil {
+		t.Error("truncated sps should fail")
+	}
+}
@@ -0,0 +1,182 @@
+package codec
+
+import (
+	"errors"
+
+	"m7s.live/engine/v4/util"
+)
+
+var ErrVPX = errors.New("vp8/vp9 parse error")
+var FourCC_VP8 = []byte{'v', 'p', '0', '8'}
+var FourCC_VP9 = []byte{'v', 'p', '0', '9'}
+var FourCC_VP9_32 = util.BigEndian.Uint32(FourCC_VP9)
+
+// VPCodecConfigurationRecord vpcC https://www.webmproject.org/vp9/mp4/#vp-codec-configuration-box
+type VPCodecConfigurationRecord struct {
+	Profile                 byte
+	Level                   byte
+	BitDepth                byte
+	ChromaSubsampling       byte // 0:4:2:0 vertical 1:4:2:0 colocated 2:4:2:2 3:4:4:4
+	VideoFullRangeFlag      byte
+	ColourPrimaries         byte
+	TransferCharacteristics byte
+	MatrixCoefficients      byte
+}
+
+// Marshal 包含 FullBox 的 version 和 flags，与ffmpeg写入flv和mp4的格式一致
+func (r *VPCodecConfigurationRecord) Marshal() []byte {
+	return []byte{
+		1, 0, 0, 0,
+		r.Profile,
+		r.Level,
+		r.BitDepth<<4 | r.ChromaSubsampling<<1 | r.VideoFullRangeFlag&1,
+		r.ColourPrimaries,
+		r.TransferCharacteristics,
+		r.MatrixCoefficients,
+		0, 0, // codecInitializationDataSize
+	}
+}
+
+func (r *VPCodecConfigurationRecord) Parse(b []byte) error {
+	if len(b) < 10 || b[0] != 1 {
+		return ErrVPX
+	}
+	r.Profile = b[4]
+	r.Level = b[5]
+	r.BitDepth = b[6] >> 4
+	r.ChromaSubsampling = (b[6] >> 1) & 0b111
+	r.VideoFullRangeFlag = b[6] & 1
+	r.ColourPrimaries = b[7]
+	r.TransferCharacteristics = b[8]
+	r.MatrixCoefficients = b[9]
+	return nil
+}
+
+// VP9Level 根据图像大小估算VP9的level https://www.webmproject.org/vp9/levels/
+func VP9Level(width, height uint) byte {
+	samples := width * height
+	for _, l := range [...]struct {
+		samples uint
+		level   byte
+	}{{36864, 10}, {73728, 11}, {122880, 20}, {245760, 21}, {552960, 30}, {983040, 31}, {2228224, 40}, {8912896, 50}, {35651584, 60}} {
+		if samples <= l.samples {
+			return l.level
+		}
+	}
+	return 62
+}
+
+// VP8FrameHeader https://datatracker.ietf.org/doc/html/rfc6386#section-9.1
+type VP8FrameHeader struct {
+	KeyFrame  bool
+	Version   byte
+	ShowFrame bool
+	Width     uint // 只有关键帧才有宽高
+	Height    uint
+}
+
+func ParseVP8FrameHeader(b []byte) (h VP8FrameHeader, err error) {
+	if len(b) < 3 {
+		return h, ErrVPX
+	}
+	h.KeyFrame = b[0]&1 == 0
+	h.Version = (b[0] >> 1) & 0b111
+	h.ShowFrame = b[0]&0x10 != 0
+	if h.KeyFrame {
+		if len(b) < 10 || b[3] != 0x9d || b[4] != 0x01 || b[5] != 0x2a {
+			return h, ErrVPX
+		}
+		h.Width = uint(b[6]) | uint(b[7]&0x3f)<<8
+		h.Height = uint(b[8]) | uint(b[9]&0x3f)<<8
+	}
+	return
+}
+
+// VP9FrameHeader 非压缩帧头中关键帧判断和构建vpcC需要的字段
+// https://storage.googleapis.com/downloads.webmproject.org/docs/vp9/vp9-bitstream-specification-v0.6-20160331-draft.pdf
+type VP9FrameHeader struct {
+	Profile           byte
+	ShowExistingFrame bool
+	KeyFrame          bool
+	ShowFrame         bool
+	BitDepth          byte
+	ColorSpace        byte
+	ColorRange        byte
+	SubsamplingX      byte
+	SubsamplingY      byte
+	Width             uint // 只有关键帧才有宽高
+	Height            uint
+}
+
+// VPCC 根据关键帧的帧头生成vpcC
+func (h *VP9FrameHeader) VPCC() (r VPCodecConfigurationRecord) {
+	r.Profile = h.Profile
+	r.Level = VP9Level(h.Width, h.Height)
+	r.BitDepth = h.BitDepth
+	switch {
+	case h.SubsamplingX == 1 && h.SubsamplingY == 1:
+		r.ChromaSubsampling = 1
+	case h.SubsamplingX == 1:
+		r.ChromaSubsampling = 2
+	default:
+		r.ChromaSubsampling = 3
+	}
+	r.VideoFullRangeFlag = h.ColorRange
+	r.ColourPrimaries, r.TransferCharacteristics = 2, 2
+	// color_space 到 matrix_coefficients 的对应关系
+	r.MatrixCoefficients = [...]byte{2, 6, 1, 6, 7, 9, 2, 0}[h.ColorSpace&0b111]
+	return
+}
+
+func ParseVP9FrameHeader(b []byte) (h VP9FrameHeader, err error) {
+	r := newBitReader(b)
+	if r.f(2) != 2 { // frame_marker
+		return h, ErrVPX
+	}
+	low := byte(r.f(1))
+	h.Profile = byte(r.f(1))<<1 | low
+	if h.Profile == 3 {
+		r.f(1) // reserved_zero
+	}
+	if h.ShowExistingFrame = r.flag(); h.ShowExistingFrame {
+		return h, r.err
+	}
+	h.KeyFrame = r.f(1) == 0
+	h.ShowFrame = r.flag()
+	r.f(1) // error_resilient_mode
+	if !h.KeyFrame {
+		return h, r.err
+	}
+	if r.f(24) != 0x498342 { // frame_sync_code
+		return h, ErrVPX
+	}
+	h.BitDepth = 8
+	if h.Profile >= 2 {
+		if r.flag() {
+			h.BitDepth = 12
+		} else {
+			h.BitDepth = 10
+		}
+	}
+	h.SubsamplingX, h.SubsamplingY = 1, 1
+	if h.ColorSpace = byte(r.f(3)); h.ColorSpace != 7 { // CS_RGB
+		h.ColorRange = byte(r.f(1))
+		if h.Profile == 1 || h.Profile == 3 {
+			h.SubsamplingX = byte(r.f(1))
+			h.SubsamplingY = byte(r.f(1))
+			r.f(1) // reserved_zero
+		}
+	} else {
+		h.ColorRange = 1
+		h.SubsamplingX, h.SubsamplingY = 0, 0
+		if h.Profile == 1 || h.Profile == 3 {
+			r.f(1) // reserved_zero
+		}
+	}
+	h.Width = r.f(16) + 1
+	h.Height = r.f(16) + 1
+	if r.err != nil {
+		err = ErrVPX
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package codec
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseVP8FrameHeader(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		b    []byte
+		want VP8FrameHeader
+		err  error
+	}{
+		{"key frame 640x480", []byte{0x50, 0x42, 0x00, 0x9d, 0x01, 0x2a, 0x80, 0x02, 0xe0, 0x01}, VP8FrameHeader{KeyFrame: true, ShowFrame: true, Width: 640, Height: 480}, nil},
+		{"key frame with scale", []byte{0x50, 0x42, 0x00, 0x9d, 0x01, 0x2a, 0x00, 0x45, 0xd0, 0x82}, VP8FrameHeader{KeyFrame: true, ShowFrame: true, Width: 1280, Height: 720}, nil},
+		{"inter frame", []byte{0x31, 0x0e, 0x00}, VP8FrameHeader{ShowFrame: true}, nil},
+		{"bad start code", []byte{0x50, 0x42, 0x00, 0x9d, 0x01, 0x2b, 0x80, 0x02, 0xe0, 0x01}, VP8FrameHeader{KeyFrame: true, ShowFrame: true}, ErrVPX},
+		{"short", []byte{0x50, 0x42}, VP8FrameHeader{}, ErrVPX},
+	} {
+		if h, err := ParseVP8FrameHeader(c.b); h != c.want || err != c.err {
+			t.Errorf("%s: ParseVP8FrameHeader = %+v, %v, want %+v, %v", c.name, h, err, c.want, c.err)
+		}
+	}
+}
+
+func TestParseVP9FrameHeader(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		b    []byte
+		want VP9FrameHeader
+		err  error
+	}{
+		// libvpx 640x480 profile 0
+		{"key frame", []byte{0x82, 0x49, 0x83, 0x42, 0x00, 0x27, 0xf0, 0x1d, 0xf0}, VP9FrameHeader{KeyFrame: true, ShowFrame: true, BitDepth: 8, SubsamplingX: 1, SubsamplingY: 1, Width: 640, Height: 480}, nil},
+		// profile 2 10bit BT.709 1920x1080
+		{"10 bit", []byte{0x92, 0x49, 0x83, 0x42, 0x20, 0x3b, 0xf8, 0x21, 0xb8}, VP9FrameHeader{Profile: 2, KeyFrame: true, ShowFrame: true, BitDepth: 10, ColorSpace: 2, SubsamplingX: 1, SubsamplingY: 1, Width: 1920, Height: 1080}, nil},
+		{"inter frame", []byte{0x86, 0x00}, VP9FrameHeader{ShowFrame: true}, nil},
+		{"show existing frame", []byte{0x88}, VP9FrameHeader{ShowExistingFrame: true}, nil},
+		{"bad sync code", []byte{0x82, 0x49, 0x83, 0x43, 0x00, 0x27, 0xf0, 0x1d, 0xf0}, VP9FrameHeader{KeyFrame: true, ShowFrame: true}, ErrVPX},
+		{"bad frame marker", []byte{0x02}, VP9FrameHeader{}, ErrVPX},
+	} {
+		if h, err := ParseVP9FrameHeader(c.b); h != c.want || err != c.err {
+			t.Errorf("%s: ParseVP9FrameHeader = %+v, %v, want %+v, %v", c.name, h, err, c.want, c.err)
+		}
+	}
+}
+
+func TestVPCC(t *testing.T) {
+	h, _ := ParseVP9FrameHeader([]byte{0x92, 0x49, 0x83, 0x42, 0x20, 0x3b, 0xf8, 0x21, 0xb8})
+	r := h.VPCC()
+	want := VPCodecConfigurationRecord{Profile: 2, Level: 40, BitDepth: 10, ChromaSubsampling: 1, ColourPrimaries: 2, TransferCharacteristics: 2, MatrixCoefficients: 1}
+	if r != want {
+		t.Fatalf("VPCC = %+v, want %+v", r, want)
+	}
+	b := r.Marshal()
+	if !bytes.Equal(b, []byte{1, 0, 0, 0, 2, 40, 0xa2, 2, 2, 1, 0, 0}) {
+		t.Errorf("Marshal = %x", b)
+	}
+	var parsed VPCodecConfigurationRecord
+	if err := parsed.Parse(b); err != nil || parsed != r {
+		t.Errorf("Parse = %+v, %v", parsed, err)
+	}
+	for _, c := range []struct {
+		width, height uint
+		level         byte
+	}{{320, 240, 20}, {640, 480, 30}, {1280, 720, 31}, {1920, 1080, 40}, {3840, 2160, 50}, {8192, 4352, 60}, {16384, 8704, 62}} {
+		if l := VP9Level(c.width, c.height); l != c.level {
+			t.Errorf("VP9Level(%dx%d) = %d, want %d", c.width, c.height, l, c.level)
+		}
+	}
+}
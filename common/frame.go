@@ -59,6 +59,7 @@ type DataFrame[T any] struct {
 type AVFrame struct {
 	BaseFrame
 	IFrame    bool
+	Droppable bool // 非参考帧，丢弃后不影响其他帧解码，订阅者读取落后时优先丢弃
 	CanRead   bool `json:"-" yaml:"-"`
 	PTS       time.Duration
 	DTS       time.Duration
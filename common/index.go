@@ -6,6 +6,7 @@ import (
 
 	"github.com/pion/rtp"
 	"go.uber.org/zap"
+	"m7s.live/engine/v4/config"
 	"m7s.live/engine/v4/log"
 	"m7s.live/engine/v4/util"
 )
@@ -21,6 +22,12 @@ const (
 	TrackStateOffline                   // 下线
 )
 
+// TrackOwner 创建track的发布者，作为SetStuff的参数传入，热备发布者创建的track不加入流中
+type TrackOwner interface {
+	IsStandby() bool
+	PublishConfig() *config.Publish // 发布者的配置，track据此缓存需要逐帧判断的开关
+}
+
 // Base 基础Track类
 type Base struct {
 	Name      string
@@ -37,6 +44,8 @@ type Base struct {
 	Drops     int   // 丢帧率
 	RawSize   int   // 裸数据长度
 	RawPart   []int // 裸数据片段用于UI上显示
+
+	Owner TrackOwner `json:"-" yaml:"-"` //创建track的发布者，没有传入时为nil
 }
 
 func (bt *Base) ComputeBPS(bytes int) {
@@ -76,6 +85,8 @@ func (bt *Base) SetStuff(stuff ...any) {
 			bt.Zap = v.With(zap.String("track", bt.Name))
 		case TrackState:
 			bt.State = v
+		case TrackOwner:
+			bt.Owner = v
 		case string:
 			bt.Name = v
 		}
@@ -102,6 +113,7 @@ type AVTrack interface {
 	Flush()
 	SetSpeedLimit(time.Duration)
 	GetRTPFromPool() *util.ListItem[RTPFrame]
+	GetSequenceHead() []byte
 }
 type VideoTrack interface {
 	AVTrack
@@ -16,6 +16,7 @@ import (
 type Config map[string]any
 
 var durationType = reflect.TypeOf(time.Duration(0))
+var configType = reflect.TypeOf(Config(nil))
 
 type Plugin interface {
 	// 可能的入参类型：FirstConfig 第一次初始化配置，Config 后续配置更新，SE系列（StateEvent）流状态变化事件
@@ -67,7 +68,8 @@ func (config Config) Unmarshal(s any) {
 	if t.Kind() == reflect.Map {
 		tt := t.Elem()
 		for k, v := range config {
-			if child, ok := v.(Config); ok {
+			// map[string]Config（例如streams）保留原始配置，使用时再解析到具体的类型
+			if child, ok := v.(Config); ok && tt != configType {
 				//复杂类型
 				el.SetMapIndex(reflect.ValueOf(k), child.CreateElem(tt))
 			} else {
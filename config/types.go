@@ -36,9 +36,20 @@ type Publish struct {
 	DelayCloseTimeout time.Duration // 延迟自动关闭（无订阅时）
 	IdleTimeout       time.Duration // 空闲(无订阅)超时
 	BufferTime        time.Duration // 缓冲长度(单位：秒)，0代表取最近关键帧
+	DVRWindow         time.Duration // 磁盘回看窗口，例如2h，超出内存缓冲的帧写入磁盘分段文件，0代表不启用
 	Key               string        // 发布鉴权key
 	SecretArgName     string        `default:"secret"` // 发布鉴权参数名
 	ExpireArgName     string        `default:"expire"` // 发布鉴权失效时间参数名
+	RSAKeyFile        string        // 发布鉴权JWT RS256密钥文件(PEM)，私钥可用于签发和校验，公钥只能校验
+	AuthURL           string        // 发布鉴权HTTP回调地址
+	AuthTimeout       time.Duration `default:"3s"` // 发布鉴权HTTP回调超时
+	AuthCacheTTL      time.Duration // 发布鉴权结果缓存时长（按SecretArgName参数值缓存），0代表不缓存
+	AuthFailOpen      bool          // 发布鉴权服务不可用时是否放行
+	Standby           bool          // 流已有发布者时是否作为热备发布者加入，发布者需要实现engine.IStandbyPublisher
+	Priority          int           // 热备优先级，数值越大越优先接替
+	PriorityArgName   string        `default:"priority"` // 指定热备优先级的参数名
+	ParseSEI          bool          // 是否解析H264、H265中的SEI并发布到名为sei的数据轨道
+	ParseCaption      bool          // 是否从SEI中提取CEA-608/708字幕并发布到名为caption的数据轨道
 }
 
 func (c Publish) GetPublishConfig() Publish {
@@ -59,11 +70,24 @@ type Subscribe struct {
 	IFrameOnly      bool          // 只要关键帧
 	WaitTimeout     time.Duration `default:"10s"`  // 等待流超时
 	WriteBufferSize int           `default:"0"`    // 写缓冲大小
-	Poll            time.Duration `default:"20ms"` // 读取Ring时的轮询间隔,单位毫秒
+	Poll            time.Duration `default:"20ms"` // 已废弃，读取Ring时等待写入的通知，不再轮询
 	Key             string        // 订阅鉴权key
 	SecretArgName   string        `default:"secret"` // 订阅鉴权参数名
 	ExpireArgName   string        `default:"expire"` // 订阅鉴权失效时间参数名
-	Internal        bool          `default:"false"`  // 是否内部订阅
+	RSAKeyFile      string        // 订阅鉴权JWT RS256密钥文件(PEM)，私钥可用于签发和校验，公钥只能校验
+	AuthURL         string        // 订阅鉴权HTTP回调地址
+	AuthTimeout     time.Duration `default:"3s"` // 订阅鉴权HTTP回调超时
+	AuthCacheTTL    time.Duration // 订阅鉴权结果缓存时长（按SecretArgName参数值缓存），0代表不缓存
+	AuthFailOpen    bool          // 订阅鉴权服务不可用时是否放行
+	Internal        bool          `default:"false"` // 是否内部订阅
+
+	TimeshiftArgName string        `default:"timeshift"` // 指定回看时长的参数名，例如timeshift=-30s，从30秒前最近的关键帧开始播放
+	MaxTimeshift     time.Duration `default:"0s"`        // 内存回看时长上限，超过时按上限处理，默认0不允许在内存中回看（开启磁盘回看的流上限为DVRWindow），轨道缓存增长后不会缩小，需要时再开启
+	SpeedArgName     string        `default:"speed"`     // 指定播放倍速的参数名，例如speed=2，范围0.25~8，只用于非RTP订阅
+	IFrameOnlySpeed  float64       `default:"2"`         // 倍速超过该值时只发送关键帧
+
+	DropNonRef float64 `default:"0.125"` // 视频读取落后的帧数超过环形缓冲大小的该比例时丢弃非参考帧，0为不启用
+	DropNonIDR float64 `default:"0.25"`  // 视频读取落后的帧数超过环形缓冲大小的该比例时只读取关键帧，0为不启用，落后超过一半时仍然跳到最新的关键帧
 }
 
 func (c *Subscribe) GetSubscribeConfig() *Subscribe {
@@ -95,6 +119,18 @@ func (p *Pull) AddPullOnSub(streamPath string, url string) {
 	p.PullOnSub[streamPath] = url
 }
 
+// MoveStreamPath 将拉流列表中的流路径修改为新路径，返回是否有修改
+func (p *Pull) MoveStreamPath(oldPath string, streamPath string) (moved bool) {
+	for _, m := range []map[string]string{p.PullOnStart, p.PullOnSub} {
+		if url, ok := m[oldPath]; ok {
+			delete(m, oldPath)
+			m[streamPath] = url
+			moved = true
+		}
+	}
+	return
+}
+
 type Push struct {
 	RePush   int               // 断开后自动重推,0 表示不自动重推，-1 表示无限重推，高于0 的数代表最大重推次数
 	PushList map[string]string // 自动推流列表
@@ -112,6 +148,17 @@ func (p *Push) AddPush(url string, streamPath string) {
 	p.PushList[url] = streamPath
 }
 
+// MoveStreamPath 将推流列表中的流路径修改为新路径，返回是否有修改
+func (p *Push) MoveStreamPath(oldPath string, streamPath string) (moved bool) {
+	for url, path := range p.PushList {
+		if path == oldPath {
+			p.PushList[url] = streamPath
+			moved = true
+		}
+	}
+	return
+}
+
 type Console struct {
 	Server        string `default:"console.monibuca.com:4242"` //远程控制台地址
 	Secret        string //远程控制台密钥
@@ -119,6 +166,38 @@ type Console struct {
 	PublicAddrTLS string
 }
 
+type Webhook struct {
+	URLList       []string      // 事件回调地址列表
+	Events        []string      // 需要回调的事件，为空则回调全部事件
+	Secret        string        // 签名密钥，HMAC-SHA256签名放在请求头X-M7S-Signature中
+	Timeout       time.Duration `default:"5s"`  // 单次请求超时
+	RetryTimes    int           `default:"3"`   // 失败后的重试次数，0为不重试，每个事件最多请求RetryTimes+1次
+	RetryInterval time.Duration `default:"1s"`  // 首次重试间隔，之后每次翻倍
+	QueueSize     int           `default:"100"` // 每个回调地址的待发送队列长度，队列满时丢弃事件
+}
+
+type DVR struct {
+	Path            string        `default:"dvr"`   // 磁盘回看文件目录，按流路径和轨道名称分目录
+	SegmentDuration time.Duration `default:"1m"`    // 每个分段文件的时长，超过回看窗口的分段整个删除
+	MaxSize         int64         `default:"10240"` // 所有流的磁盘回看文件总大小上限(MB)，超过时删除最早的分段，0为不限制
+}
+
+type Limit struct {
+	MaxSubscribers       int            // 全局最大订阅者数量，0为不限制
+	MaxStreamSubscribers int            // 单个流最大订阅者数量，0为不限制
+	MaxAppSubscribers    map[string]int // 按AppName限制订阅者数量，*代表未单独配置的App
+	MaxAppPublishers     map[string]int // 按AppName限制发布者数量，*代表未单独配置的App
+	MaxBandwidth         int            // 出口带宽上限(字节/秒)，按各流track的BPS乘以订阅者数量估算，0为不限制
+}
+
+// AppLimit 获取App对应的限制，未单独配置时使用*的配置
+func (l *Limit) AppLimit(m map[string]int, appName string) int {
+	if v, ok := m[appName]; ok {
+		return v
+	}
+	return m["*"]
+}
+
 type Engine struct {
 	Publish
 	Subscribe
@@ -128,6 +207,8 @@ type Engine struct {
 	EnableSubEvent bool `default:"true"` //启用订阅事件,禁用可以提高性能
 	EnableAuth     bool `default:"true"` //启用鉴权
 	Console
+	Webhook             Webhook       // 流状态事件回调
+	DVR                 DVR           // 磁盘回看
 	LogLang             string        `default:"zh"`    //日志语言
 	LogLevel            string        `default:"info"`  //日志级别
 	RTPReorderBufferLen int           `default:"50"`    //RTP重排序缓冲长度
@@ -137,6 +218,11 @@ type Engine struct {
 	enableReport        bool          `default:"false"` //启用报告,用于统计和监控
 	reportStream        quic.Stream   // console server connection
 	instanceId          string        // instance id 来自console
+
+	StreamAlias   map[string]string // 流路径别名，公开路径 -> 实际流路径
+	StreamRewrite map[string]string // 流路径正则改写规则，正则表达式 -> 实际流路径（可使用$1等引用分组）
+	Streams       map[string]Config // 按AppName或者流路径通配符覆盖publish、subscribe中的部分配置
+	Limit         Limit             // 准入限制，可通过修改配置接口热更新
 }
 
 func (cfg *Engine) GetEnableReport() bool {
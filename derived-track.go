@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/track"
+)
+
+// derivedTrack 订阅者按名称（pcma、pcmu、pcm）订阅而流中只有另一种G711时，从源轨道转换出的音频轨道，所有订阅者共享，没有订阅者或者源轨道被移除时销毁
+type derivedTrack struct {
+	source *track.Audio
+	context.CancelFunc
+}
+
+// deriveSource 查找可以转换出name的G711源轨道，不使用其他转换出的轨道
+func (s *Stream) deriveSource(name string) (source *track.Audio) {
+	s.Tracks.Range(func(n string, t Track) {
+		a, ok := t.(*track.Audio)
+		if _, derived := s.derived[n]; !ok || derived || source != nil {
+			return
+		}
+		switch name {
+		case "pcma":
+			ok = a.CodecID == codec.CodecID_PCMU
+		case "pcmu":
+			ok = a.CodecID == codec.CodecID_PCMA
+		case "pcm":
+			ok = a.CodecID == codec.CodecID_PCMA || a.CodecID == codec.CodecID_PCMU
+		default:
+			ok = false
+		}
+		if ok {
+			source = a
+		}
+	})
+	return
+}
+
+// deriveAudio 订阅者等待的音频轨道都不存在时，尝试转换出其中一个
+func (s *Stream) deriveAudio(w *waitTracks) {
+	if !w.audio.Waiting() || w.audio.Waitany() {
+		return
+	}
+	for _, name := range w.audio {
+		if _, ok := s.derived[name]; ok {
+			return
+		}
+	}
+	for _, name := range w.audio {
+		if source := s.deriveSource(name); source != nil {
+			if s.derived == nil {
+				s.derived = make(map[string]*derivedTrack)
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			dt := &derivedTrack{source, cancel}
+			s.derived[name] = dt
+			s.Info("derive audio track", zap.String("name", name), zap.String("source", source.Name))
+			go dt.run(ctx, s, name)
+			return
+		}
+	}
+}
+
+// releaseDerived 订阅者离开后，销毁没有订阅者读取也没有订阅者在等待的转换轨道
+func (s *Stream) releaseDerived() {
+	for name, dt := range s.derived {
+		t, _ := s.Tracks.Get(name).(*track.Audio)
+		inUse := false
+		s.Subscribers.rangeAll(func(sub ISubscriber, wait *waitTracks) {
+			if t != nil && sub.GetSubscriber().Audio == t {
+				inUse = true
+			} else if _, waiting := s.Subscribers.waits[wait]; waiting {
+				for _, n := range wait.audio {
+					inUse = inUse || n == name
+				}
+			}
+		})
+		if !inUse {
+			s.Info("release derived audio track", zap.String("name", name))
+			dt.CancelFunc()
+			delete(s.derived, name)
+		}
+	}
+}
+
+// removeDerived 源轨道被移除时停止从它转换的轨道，之后的订阅者可以从新的源轨道重新转换
+func (s *Stream) removeDerived(source Track) {
+	for name, dt := range s.derived {
+		if Track(dt.source) == source {
+			s.Info("release derived audio track", zap.String("name", name), zap.String("reason", "source removed"))
+			dt.CancelFunc()
+			delete(s.derived, name)
+		}
+	}
+}
+
+// disposeDerived 流关闭时停止所有的转换
+func (s *Stream) disposeDerived() {
+	for name, dt := range s.derived {
+		dt.CancelFunc()
+		delete(s.derived, name)
+	}
+}
+
+// run 读取源轨道的每一帧，转换后写入新的轨道，结束时移除新的轨道
+func (dt *derivedTrack) run(ctx context.Context, s *Stream, name string) {
+	alaw := dt.source.CodecID == codec.CodecID_PCMA
+	var audio *track.Audio
+	var convert func([]byte) []byte
+	switch name {
+	case "pcm":
+		pcm := track.NewPCM(s)
+		pcm.SetFormat(dt.source.SampleRate, dt.source.Channels)
+		audio = &pcm.Audio
+		convert = func(b []byte) []byte {
+			dst := make([]byte, len(b)*2)
+			codec.G711ToPCM(dst, b, alaw)
+			return dst
+		}
+	default:
+		g711 := track.NewG711(s, !alaw, dt.source.SampleRate)
+		audio = &g711.Audio
+		convert = func(b []byte) []byte {
+			dst := make([]byte, len(b))
+			if alaw {
+				codec.ALaw2ULaw(dst, b)
+			} else {
+				codec.ULaw2ALaw(dst, b)
+			}
+			return dst
+		}
+	}
+	audio.Attach()
+	defer audio.Detach()
+	reader := track.NewAVRingReader(&dt.source.Media, EngineConfig.Poll)
+	reader.Logger = audio.With(zap.String("source", dt.source.Name))
+	for ctx.Err() == nil {
+		// 与订阅模式1相同，不进行追赶
+		if err := reader.Read(ctx, 1); err != nil || ctx.Err() != nil {
+			return
+		}
+		frame := reader.Frame
+		audio.WriteRaw(uint32(frame.PTS), convert(frame.AUList.ToBytes()))
+	}
+}
@@ -0,0 +1,126 @@
+package engine
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/track"
+)
+
+var ErrBadDVRPath = errors.New("bad dvr path")
+
+// dvrDir 轨道的分段文件目录，流路径来自发布端，开始和结束时会删除整个目录
+// 不允许..以及清理后不在DVR.Path之下的路径，否则可能删除其他流甚至任意目录
+func dvrDir(root string, streamPath string, name string) (string, error) {
+	if strings.HasPrefix(streamPath, "/") || strings.HasPrefix(streamPath, "\\") {
+		return "", ErrBadDVRPath
+	}
+	for _, seg := range strings.FieldsFunc(streamPath+"/"+name, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if seg == ".." || seg == "." || filepath.IsAbs(seg) || filepath.VolumeName(seg) != "" {
+			return "", ErrBadDVRPath
+		}
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, streamPath, name)
+	if rel, err := filepath.Rel(root, dir); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", ErrBadDVRPath
+	}
+	return dir, nil
+}
+
+// startDVR 发布配置中DVRWindow大于0时，将音视频轨道写入磁盘，转换出的轨道不写入
+func (s *Stream) startDVR(t Track) {
+	window := s.GetPublisherConfig().DVRWindow
+	name := t.GetBase().Name
+	if _, derived := s.derived[name]; window <= 0 || derived {
+		return
+	}
+	dir, err := dvrDir(EngineConfig.DVR.Path, s.Path, name)
+	if err != nil {
+		s.Error("start dvr", zap.String("name", name), zap.Error(err))
+		return
+	}
+	switch v := t.(type) {
+	case *track.Video:
+		_, err = v.StartDVR(dir, window, &EngineConfig.DVR)
+	case *track.Audio:
+		_, err = v.StartDVR(dir, window, &EngineConfig.DVR)
+	}
+	if err != nil {
+		s.Error("start dvr", zap.String("name", name), zap.Error(err))
+	}
+}
+
+func getDVR(t Track) *track.DVR {
+	switch v := t.(type) {
+	case *track.Video:
+		return v.GetDVR()
+	case *track.Audio:
+		return v.GetDVR()
+	}
+	return nil
+}
+
+// stopDVR 轨道移除或者流关闭时停止写入并删除分段文件
+func (s *Stream) stopDVR(t Track) {
+	if dvr := getDVR(t); dvr != nil {
+		dvr.CancelFunc()
+	}
+}
+
+// moveDVR 流被移动后把分段文件移到新路径对应的目录，新路径不能写入磁盘时停止回看
+func (s *Stream) moveDVR() {
+	s.Tracks.Range(func(name string, t Track) {
+		dvr := getDVR(t)
+		if dvr == nil {
+			return
+		}
+		dir, err := dvrDir(EngineConfig.DVR.Path, s.Path, name)
+		if err == nil {
+			err = dvr.Move(dir)
+		}
+		if err != nil {
+			s.Error("move dvr", zap.String("name", name), zap.Error(err))
+			s.stopDVR(t)
+		}
+	})
+}
+
+type DVRTrackInfo struct {
+	Name         string
+	Window       time.Duration
+	MaxTimeshift time.Duration // 可以使用的最长回看时长，即最新的帧与最早可回看的帧的时间差
+	Ranges       []track.DVRRange
+}
+
+type DVRInfo struct {
+	StreamPath string
+	Tracks     []DVRTrackInfo
+}
+
+// DVRInfo 开启磁盘回看的轨道可回看的时间范围，没有开启时返回nil
+func (s *Stream) DVRInfo() *DVRInfo {
+	var info *DVRInfo
+	s.Tracks.Range(func(name string, t Track) {
+		dvr := getDVR(t)
+		if dvr == nil {
+			return
+		}
+		if info == nil {
+			info = &DVRInfo{StreamPath: s.GetPath()}
+		}
+		ti := DVRTrackInfo{Name: name, Window: dvr.Window(), Ranges: dvr.Ranges()}
+		if len(ti.Ranges) > 0 {
+			ti.MaxTimeshift = t.(AVTrack).PreFrame().Timestamp - ti.Ranges[0].Start
+		}
+		info.Tracks = append(info.Tracks, ti)
+	})
+	return info
+}
@@ -0,0 +1,21 @@
+package engine
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestDVRDir(t *testing.T) {
+	root, _ := filepath.Abs("dvr")
+	if dir, err := dvrDir("dvr", "live/test", "h264"); err != nil || dir != filepath.Join(root, "live", "test", "h264") {
+		t.Errorf("dvrDir(live/test) = %s, %v", dir, err)
+	}
+	for _, streamPath := range []string{"..", "live/../../etc", "live/../other", "live/./test", "/etc/passwd", "\\etc", "live\\..\\..\\etc"} {
+		if dir, err := dvrDir("dvr", streamPath, "h264"); err != ErrBadDVRPath {
+			t.Errorf("dvrDir(%s) = %s, %v, want ErrBadDVRPath", streamPath, dir, err)
+		}
+	}
+	if _, err := dvrDir("dvr", "live/test", ".."); err != ErrBadDVRPath {
+		t.Errorf("track name .. = %v, want ErrBadDVRPath", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/track"
 )
 
 type Event[T any] struct {
@@ -49,12 +50,15 @@ type SEwaitPublish struct {
 	Publisher IPublisher
 }
 
+// SEpublish Publisher为发出事件时的发布者，之后流的发布者可能被替换
 type SEpublish struct {
 	StateEvent
+	Publisher IPublisher
 }
 
 type SErepublish struct {
 	StateEvent
+	Publisher IPublisher
 }
 
 type SEwaitClose struct {
@@ -67,10 +71,29 @@ type SEcreate struct {
 	StreamEvent
 }
 
+// SEmove 流被移动到新的流路径，Target.GetPath() 为新路径，发布者和订阅者不受影响
+type SEmove struct {
+	StreamEvent
+	OldPath string
+}
+
+// SwitchPublisherEvent 热备发布者接替失效的发布者，流状态保持不变
+type SwitchPublisherEvent struct {
+	StreamEvent
+	Old IPublisher
+	New IPublisher
+}
+
 type SEKick struct {
 	Event[struct{}]
 }
 
+// KickEvent 通过接口停止单个发布者或者订阅者，在停止前发给该发布者或订阅者（插件可据此发送关闭消息）以及EventBus
+type KickEvent struct {
+	Event[IIO]
+	Reason string
+}
+
 type UnsubscribeEvent struct {
 	Event[ISubscriber]
 }
@@ -78,3 +101,13 @@ type UnsubscribeEvent struct {
 type AddTrackEvent struct {
 	Event[common.Track]
 }
+
+// TrackParamsChanged 轨道的解码参数（分辨率、profile、level、帧率、AudioSpecificConfig）发生变化，发给订阅者以及EventBus
+// HLS等可以从序号为Sequence的帧开始新的分片，录制可以在该帧处切分文件
+type TrackParamsChanged struct {
+	StreamEvent
+	Track    common.Track `json:"-" yaml:"-"`
+	Name     string       // 轨道名称
+	Old, New track.TrackParams
+	Sequence uint32
+}
@@ -3,7 +3,9 @@ package engine
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -88,6 +90,26 @@ func (conf *GlobalConfig) API_stream(rw http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// API_dvr 不带streamPath时返回所有开启磁盘回看的流
+func (conf *GlobalConfig) API_dvr(rw http.ResponseWriter, r *http.Request) {
+	if streamPath := r.URL.Query().Get("streamPath"); streamPath != "" {
+		if s := Streams.Get(streamPath); s != nil {
+			util.ReturnJson(s.DVRInfo, time.Second, rw, r)
+		} else {
+			http.Error(rw, NO_SUCH_STREAM, http.StatusNotFound)
+		}
+		return
+	}
+	util.ReturnJson(func() (list []*DVRInfo) {
+		for _, s := range Streams.ToList() {
+			if info := s.DVRInfo(); info != nil {
+				list = append(list, info)
+			}
+		}
+		return
+	}, time.Second, rw, r)
+}
+
 func (conf *GlobalConfig) API_sysInfo(rw http.ResponseWriter, r *http.Request) {
 	if err := json.NewEncoder(rw).Encode(&SysInfo); err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
@@ -107,6 +129,90 @@ func (conf *GlobalConfig) API_closeStream(w http.ResponseWriter, r *http.Request
 	}
 }
 
+// API_kick 停止流中指定ID的订阅者或者发布者，ID可以通过 /api/stream 获取
+func (conf *GlobalConfig) API_kick(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	streamPath, id := q.Get("streamPath"), q.Get("id")
+	if streamPath == "" || id == "" {
+		http.Error(w, "no streamPath or id", http.StatusBadRequest)
+		return
+	}
+	s := Streams.Get(streamPath)
+	if s == nil {
+		http.Error(w, NO_SUCH_STREAM, http.StatusNotFound)
+		return
+	}
+	if !s.Kick(id, q.Get("reason")) {
+		http.Error(w, "no such id", http.StatusNotFound)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// API_play_control 控制订阅者播放，参数speed、pause、resume、seek同时只能使用一个
+func (conf *GlobalConfig) API_play_control(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	streamPath, id := q.Get("streamPath"), q.Get("id")
+	if streamPath == "" || id == "" {
+		http.Error(w, "no streamPath or id", http.StatusBadRequest)
+		return
+	}
+	s := Streams.Get(streamPath)
+	if s == nil {
+		http.Error(w, NO_SUCH_STREAM, http.StatusNotFound)
+		return
+	}
+	var cmd any
+	switch {
+	case q.Has("speed"):
+		speed, err := strconv.ParseFloat(q.Get("speed"), 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cmd = PlaySpeed(speed)
+	case q.Has("pause"):
+		cmd = PlayPause{}
+	case q.Has("resume"):
+		cmd = PlayResume{}
+	case q.Has("seek"):
+		ts, err := time.ParseDuration(q.Get("seek"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cmd = PlaySeek(ts)
+	default:
+		http.Error(w, "no speed, pause, resume or seek", http.StatusBadRequest)
+		return
+	}
+	if err := s.Control(id, cmd); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// API_moveStream 将流移动到新的流路径，发布者和订阅者不会断开
+func (conf *GlobalConfig) API_moveStream(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	streamPath, to := q.Get("streamPath"), q.Get("to")
+	if streamPath == "" || to == "" {
+		http.Error(w, "no streamPath or to", http.StatusBadRequest)
+		return
+	}
+	s := Streams.Get(streamPath)
+	if s == nil {
+		http.Error(w, NO_SUCH_STREAM, http.StatusNotFound)
+		return
+	}
+	if err := s.Move(to); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
 // API_getConfig 获取指定的配置信息
 func (conf *GlobalConfig) API_getConfig(w http.ResponseWriter, r *http.Request) {
 	var p *Plugin
@@ -153,15 +259,34 @@ func (conf *GlobalConfig) API_modifyConfig(w http.ResponseWriter, r *http.Reques
 	} else {
 		p = Engine
 	}
+	// 先解析到新的map，校验通过后才合并到Modified并保存，避免无效的配置被写入磁盘
+	modified := make(config.Config)
 	if ShouldYaml(r) {
-		err = yaml.NewDecoder(r.Body).Decode(&p.Modified)
+		err = yaml.NewDecoder(r.Body).Decode(&modified)
 	} else {
-		err = json.NewDecoder(r.Body).Decode(&p.Modified)
+		err = json.NewDecoder(r.Body).Decode(&modified)
+	}
+	var limit *config.Limit
+	if err == nil && p == Engine {
+		limit, err = parseLimit(modified)
 	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
-	} else if err = p.Save(); err == nil {
+		return
+	}
+	if p.Modified == nil {
+		p.Modified = make(config.Config)
+	}
+	for k, v := range modified {
+		p.Modified[k] = v
+	}
+	if err = p.Save(); err == nil {
 		p.RawConfig.Assign(p.Modified)
+		// 修改后立即生效准入限制，不需要再调用热更新接口
+		if limit != nil {
+			limitConfig.Store(limit)
+			Engine.Info("limit updated")
+		}
 		out, err := yaml.Marshal(p.Modified)
 		if err == nil {
 			p.modifiedYaml = string(out)
@@ -221,6 +346,159 @@ func (conf *GlobalConfig) API_stopPush(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// API_token 签发JWT鉴权令牌，role为publish时使用发布鉴权配置，否则使用订阅鉴权配置，plugin 指定处理请求的插件，不传则使用全局配置
+func (conf *GlobalConfig) API_token(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	claims := JWTClaims{
+		Path: q.Get("streamPath"),
+		Role: q.Get("role"),
+		Iat:  time.Now().Unix(),
+	}
+	if claims.Path == "" {
+		http.Error(w, "no streamPath", http.StatusBadRequest)
+		return
+	}
+	switch claims.Role {
+	case JWT_ROLE_PUBLISH, JWT_ROLE_SUBSCRIBE, "":
+	default:
+		http.Error(w, "role must be publish or subscribe", http.StatusBadRequest)
+		return
+	}
+	sc, err := getSignConfig(q.Get("plugin"), claims.Role)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key, rsaKeyFile := sc.Key, sc.RSAKeyFile
+	ttl := time.Hour
+	if v := q.Get("ttl"); v != "" {
+		if ttl, err = time.ParseDuration(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	// 不签发永不过期的令牌
+	if ttl <= 0 {
+		http.Error(w, "ttl must be positive", http.StatusBadRequest)
+		return
+	}
+	claims.Exp = time.Now().Add(ttl).Unix()
+	if v := q.Get("maxDuration"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		claims.MaxDuration = int64(d / time.Second)
+	}
+	alg := q.Get("alg")
+	if alg == "" {
+		if alg = JWT_HS256; key == "" {
+			alg = JWT_RS256
+		}
+	}
+	token, err := SignJWT(alg, key, rsaKeyFile, &claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte(token))
+}
+
+// API_sign 按默认鉴权方式生成签名参数，plugin 指定处理请求的插件，不传则使用全局配置
+func (conf *GlobalConfig) API_sign(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	streamPath := strings.Trim(q.Get("streamPath"), "/")
+	if streamPath == "" {
+		http.Error(w, "no streamPath", http.StatusBadRequest)
+		return
+	}
+	sc, err := getSignConfig(q.Get("plugin"), q.Get("role"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if sc.Key == "" {
+		http.Error(w, "no key configured", http.StatusBadRequest)
+		return
+	}
+	ttl := time.Hour
+	if v := q.Get("ttl"); v != "" {
+		if ttl, err = time.ParseDuration(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	secret, expire := SignMD5(sc.Key, streamPath, time.Now().Add(ttl))
+	w.Write([]byte(url.Values{sc.SecretArgName: {secret}, sc.ExpireArgName: {expire}}.Encode()))
+}
+
+// API_verify 校验地址中的签名，并说明被拒绝的原因，url 可以是完整地址或者带参数的流路径，流路径与地址路径不同时可通过 streamPath 指定
+func (conf *GlobalConfig) API_verify(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	u, err := url.Parse(q.Get("url"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	streamPath := strings.Trim(q.Get("streamPath"), "/")
+	if streamPath == "" {
+		streamPath = strings.Trim(u.Path, "/")
+	}
+	if streamPath == "" {
+		http.Error(w, "no streamPath", http.StatusBadRequest)
+		return
+	}
+	sc, err := getSignConfig(q.Get("plugin"), q.Get("role"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res := verifySign(sc, streamPath, u.Query())
+	if err := json.NewEncoder(w).Encode(&res); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// API_alias_list 获取流路径别名和改写规则
+func (conf *GlobalConfig) API_alias_list(w http.ResponseWriter, r *http.Request) {
+	alias, rewrite := StreamAliasList()
+	if err := json.NewEncoder(w).Encode(map[string]any{"StreamAlias": alias, "StreamRewrite": rewrite}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// API_alias_set 添加或修改流路径别名，regex不为空时添加正则改写规则
+func (conf *GlobalConfig) API_alias_set(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	alias, streamPath := strings.Trim(q.Get("alias"), "/"), strings.Trim(q.Get("streamPath"), "/")
+	if alias == "" || streamPath == "" {
+		http.Error(w, "no alias or streamPath", http.StatusBadRequest)
+		return
+	}
+	if err := SetStreamAlias(alias, streamPath, q.Get("regex") != ""); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// API_alias_delete 删除流路径别名，regex不为空时删除正则改写规则
+func (conf *GlobalConfig) API_alias_delete(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if ok, err := DeleteStreamAlias(strings.Trim(q.Get("alias"), "/"), q.Get("regex") != ""); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else if !ok {
+		http.Error(w, "no such alias", http.StatusNotFound)
+	} else {
+		w.Write([]byte("ok"))
+	}
+}
+
+func (conf *GlobalConfig) API_webhook_status(w http.ResponseWriter, r *http.Request) {
+	util.ReturnJson(webhook.Targets, time.Second, w, r)
+}
+
 func (conf *GlobalConfig) API_replay_rtpdump(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	streamPath := q.Get("streamPath")
@@ -239,6 +517,12 @@ func (conf *GlobalConfig) API_replay_rtpdump(w http.ResponseWriter, r *http.Requ
 		pub.VCodec = codec.CodecID_H264
 	case "h265":
 		pub.VCodec = codec.CodecID_H265
+	case "av1":
+		pub.VCodec = codec.CodecID_AV1
+	case "vp8":
+		pub.VCodec = codec.CodecID_VP8
+	case "vp9":
+		pub.VCodec = codec.CodecID_VP9
 	default:
 		pub.VCodec = codec.CodecID_H264
 	}
@@ -249,6 +533,14 @@ func (conf *GlobalConfig) API_replay_rtpdump(w http.ResponseWriter, r *http.Requ
 		pub.ACodec = codec.CodecID_PCMA
 	case "pcmu":
 		pub.ACodec = codec.CodecID_PCMU
+	case "opus":
+		pub.ACodec = codec.CodecID_OPUS
+	case "mp3":
+		pub.ACodec = codec.CodecID_MP3
+	case "ac3":
+		pub.ACodec = codec.CodecID_AC3
+	case "eac3":
+		pub.ACodec = codec.CodecID_EAC3
 	default:
 		pub.ACodec = codec.CodecID_AAC
 	}
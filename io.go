@@ -2,13 +2,15 @@ package engine
 
 import (
 	"context"
-	"crypto/md5"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -45,7 +47,8 @@ type IO struct {
 	io.Writer          `json:"-" yaml:"-"`
 	io.Closer          `json:"-" yaml:"-"`
 	Args               url.Values
-	Spesific           IIO `json:"-" yaml:"-"`
+	Spesific           IIO    `json:"-" yaml:"-"`
+	requestPath        string // 解析别名之前的流路径，签名针对的是请求中的路径
 }
 
 func (io *IO) IsClosed() bool {
@@ -65,6 +68,16 @@ func (i *IO) SetIO(conn any) {
 	}
 }
 
+// remoteAddr 从SetIO传入的连接中获取对端地址
+func (i *IO) remoteAddr() string {
+	for _, conn := range []any{i.Closer, i.Reader, i.Writer} {
+		if c, ok := conn.(interface{ RemoteAddr() net.Addr }); ok && c.RemoteAddr() != nil {
+			return c.RemoteAddr().String()
+		}
+	}
+	return ""
+}
+
 // SetParentCtx（可选）
 func (i *IO) SetParentCtx(parent context.Context) {
 	i.Context, i.CancelFunc = context.WithCancel(parent)
@@ -120,27 +133,67 @@ func (io *IO) Stop() {
 }
 
 var (
-	ErrBadStreamName  = errors.New("Stream Already Exist")
-	ErrBadTrackName   = errors.New("Track Already Exist")
-	ErrStreamIsClosed = errors.New("Stream Is Closed")
-	ErrPublisherLost  = errors.New("Publisher Lost")
-	ErrAuth           = errors.New("Auth Failed")
-	OnAuthSub         func(p *util.Promise[ISubscriber]) error
-	OnAuthPub         func(p *util.Promise[IPublisher]) error
+	ErrBadStreamName      = errors.New("Stream Already Exist")
+	ErrBadStreamPath      = errors.New("Stream Path Format Error")
+	ErrBadTrackName       = errors.New("Track Already Exist")
+	ErrStreamIsClosed     = errors.New("Stream Is Closed")
+	ErrPublisherLost      = errors.New("Publisher Lost")
+	ErrAuth               = errors.New("Auth Failed")
+	ErrStandbyUnsupported = errors.New("Publisher Not Support Standby")
+	OnAuthSub             func(p *util.Promise[ISubscriber]) error
+	OnAuthPub             func(p *util.Promise[IPublisher]) error
+	ioSeq                 atomic.Uint64 // 用于生成发布者和订阅者的ID
 )
 
+// authPaths 鉴权时接受的流路径，先是请求中的路径，使用了别名时再加上实际的流路径
+func (io *IO) authPaths() []string {
+	streamPath := io.Stream.GetPath()
+	if io.requestPath == "" || io.requestPath == streamPath {
+		return []string{streamPath}
+	}
+	return []string{io.requestPath, streamPath}
+}
+
 func (io *IO) auth(key string, secret string, expire string) bool {
-	if unixTime, err := strconv.ParseInt(expire, 16, 64); err != nil || time.Now().Unix() > unixTime {
-		return false
+	for _, streamPath := range io.authPaths() {
+		if CheckMD5(key, streamPath, secret, expire) == nil {
+			return true
+		}
 	}
-	trueSecret := md5.Sum([]byte(key + io.Stream.Path + expire))
-	for i := 0; i < 16; i++ {
-		hex, err := strconv.ParseInt(secret[i<<1:(i<<1)+2], 16, 16)
-		if trueSecret[i] != byte(hex) || err != nil {
-			return false
+	return false
+}
+
+// authKey 使用配置的key进行鉴权，secret 参数为JWT时校验令牌，否则按md5签名校验
+func (io *IO) authKey(key string, rsaKeyFile string, secret string, expire string, role string) (claims *JWTClaims, err error) {
+	if IsJWT(secret) {
+		if claims, err = ParseJWT(secret, key, rsaKeyFile); err == nil {
+			for _, streamPath := range io.authPaths() {
+				if err = claims.Check(streamPath, role); err == nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrAuth, err)
 		}
+		return
+	}
+	if key == "" || !io.auth(key, secret, expire) {
+		return nil, ErrAuth
+	}
+	return
+}
+
+// stopAfter 到期后停止，用于限制最长播放时长
+func (io *IO) stopAfter(d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		io.Info("max duration reached", zap.Duration("duration", d))
+		io.Stop()
+	case <-io.Done():
 	}
-	return true
 }
 
 // receive 用于接收发布或者订阅
@@ -163,6 +216,11 @@ func (io *IO) receive(streamPath string, specific IIO) error {
 	if io.Context == nil {
 		io.Context, io.CancelFunc = context.WithCancel(Engine)
 	}
+	io.requestPath = u.Path
+	if realPath := resolveStreamPath(u.Path); realPath != u.Path {
+		Engine.Debug("stream alias", zap.String("alias", u.Path), zap.String("streamPath", realPath))
+		u.Path = realPath
+	}
 	Streams.Lock()
 	s, create := findOrCreateStream(u.Path, wt)
 	Streams.Unlock()
@@ -175,14 +233,23 @@ func (io *IO) receive(streamPath string, specific IIO) error {
 	if io.Type == "" {
 		io.Type = reflect.TypeOf(specific).Elem().Name()
 	}
+	if io.ID == "" {
+		io.ID = strconv.FormatUint(ioSeq.Add(1), 10)
+	}
 	io.Logger = s.With(zap.String("type", io.Type))
 	if io.ID != "" {
 		io.Logger = io.Logger.With(zap.String("ID", io.ID))
 	}
 	if v, ok := specific.(IPublisher); ok {
-		conf := v.GetPublisher().Config
+		puber := v.GetPublisher()
+		conf := puber.Config
 		io.Type = strings.TrimSuffix(io.Type, "Publisher")
 		io.Info("publish")
+		puber.Standby = false
+		puber.Priority = conf.Priority
+		if p := io.Args.Get(conf.PriorityArgName); p != "" {
+			puber.Priority, _ = strconv.Atoi(p)
+		}
 		oldPublisher := s.Publisher
 		if oldPublisher != nil && !oldPublisher.IsClosed() {
 			// 根据配置是否剔出原来的发布者
@@ -191,16 +258,26 @@ func (io *IO) receive(streamPath string, specific IIO) error {
 				oldPublisher.OnEvent(SEKick{})
 			} else if oldPublisher == specific {
 				//断线重连
+			} else if conf.Standby {
+				// 作为热备发布者加入，原发布者失效时接替
+				if sp, ok := specific.(IStandbyPublisher); !ok || !sp.SupportStandby() {
+					return ErrStandbyUnsupported
+				}
+				puber.Standby = true
+				puber.switchC = make(chan IPublisher, 1)
 			} else {
 				return ErrBadStreamName
 			}
 		}
-		s.PublishTimeout = conf.PublishTimeout
-		s.DelayCloseTimeout = conf.DelayCloseTimeout
-		s.IdleTimeout = conf.IdleTimeout
+		// 发布之后Standby只在流的协程中修改，这里使用发布之前的值
+		standby := puber.Standby
+		if !standby {
+			s.setTimeoutConfig(conf)
+		}
 		defer func() {
 			if err == nil {
-				if oldPublisher == nil {
+				// 热备发布者使用自己的track，接替时再接管前任的track
+				if oldPublisher == nil || standby {
 					specific.OnEvent(specific)
 				} else {
 					specific.OnEvent(oldPublisher)
@@ -211,6 +288,8 @@ func (io *IO) receive(streamPath string, specific IIO) error {
 			onAuthPub := OnAuthPub
 			if auth, ok := specific.(AuthPub); ok {
 				onAuthPub = auth.OnAuth
+			} else if onAuthPub == nil && conf.AuthURL != "" {
+				onAuthPub = onHTTPAuth[IPublisher](httpAuthConfig{conf.AuthURL, conf.AuthTimeout, conf.AuthCacheTTL, conf.AuthFailOpen}, io.authRequest("publish", conf.SecretArgName), io.Logger)
 			}
 			if onAuthPub != nil {
 				authPromise := util.NewPromise(specific.(IPublisher))
@@ -220,9 +299,9 @@ func (io *IO) receive(streamPath string, specific IIO) error {
 				if err != nil {
 					return err
 				}
-			} else if conf.Key != "" {
-				if !io.auth(conf.Key, io.Args.Get(conf.SecretArgName), io.Args.Get(conf.ExpireArgName)) {
-					return ErrAuth
+			} else if conf.Key != "" || conf.RSAKeyFile != "" {
+				if _, err = io.authKey(conf.Key, conf.RSAKeyFile, io.Args.Get(conf.SecretArgName), io.Args.Get(conf.ExpireArgName), JWT_ROLE_PUBLISH); err != nil {
+					return err
 				}
 			}
 		}
@@ -237,15 +316,22 @@ func (io *IO) receive(streamPath string, specific IIO) error {
 		if create {
 			EventBus <- s // 通知发布者按需拉流
 		}
+		var maxDuration time.Duration
 		defer func() {
 			if err == nil {
+				if maxDuration > 0 {
+					go io.stopAfter(maxDuration)
+				}
 				specific.OnEvent(specific)
 			}
 		}()
 		if config.Global.EnableAuth {
+			conf := specific.(ISubscriber).GetSubscriber().Config
 			onAuthSub := OnAuthSub
 			if auth, ok := specific.(AuthSub); ok {
 				onAuthSub = auth.OnAuth
+			} else if onAuthSub == nil && conf.AuthURL != "" {
+				onAuthSub = onHTTPAuth[ISubscriber](httpAuthConfig{conf.AuthURL, conf.AuthTimeout, conf.AuthCacheTTL, conf.AuthFailOpen}, io.authRequest("subscribe", conf.SecretArgName), io.Logger)
 			}
 			if onAuthSub != nil {
 				authPromise := util.NewPromise(specific.(ISubscriber))
@@ -255,9 +341,13 @@ func (io *IO) receive(streamPath string, specific IIO) error {
 				if err != nil {
 					return err
 				}
-			} else if conf := specific.(ISubscriber).GetSubscriber().Config; conf.Key != "" {
-				if !io.auth(conf.Key, io.Args.Get(conf.SecretArgName), io.Args.Get(conf.ExpireArgName)) {
-					return ErrAuth
+			} else if conf.Key != "" || conf.RSAKeyFile != "" {
+				var claims *JWTClaims
+				if claims, err = io.authKey(conf.Key, conf.RSAKeyFile, io.Args.Get(conf.SecretArgName), io.Args.Get(conf.ExpireArgName), JWT_ROLE_SUBSCRIBE); err != nil {
+					return err
+				}
+				if claims != nil && claims.MaxDuration > 0 {
+					maxDuration = time.Duration(claims.MaxDuration) * time.Second
 				}
 			}
 		}
@@ -282,3 +372,9 @@ func (c *ClientIO[C]) init(streamPath string, url string, conf *C) {
 	c.StreamPath = streamPath
 	c.RemoteURL = url
 }
+
+func (c *ClientIO[C]) moveStreamPath(oldPath string, streamPath string) {
+	if c.StreamPath == oldPath {
+		c.StreamPath = streamPath
+	}
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	JWT_HS256 = "HS256"
+	JWT_RS256 = "RS256"
+
+	JWT_ROLE_PUBLISH   = "publish"
+	JWT_ROLE_SUBSCRIBE = "subscribe"
+)
+
+var (
+	ErrJWTFormat    = errors.New("malformed token")
+	ErrJWTAlg       = errors.New("unsupported token alg")
+	ErrJWTSignature = errors.New("invalid token signature")
+	ErrJWTExpired   = errors.New("token expired")
+	ErrJWTNoExpire  = errors.New("token without exp")
+	ErrJWTPath      = errors.New("stream path not allowed")
+	ErrJWTRole      = errors.New("role not allowed")
+)
+
+// JWTClaims 鉴权令牌携带的声明
+type JWTClaims struct {
+	Path        string `json:"path,omitempty"`   // 允许的流路径，支持通配符，如 live/*，以/**结尾表示匹配该前缀下所有层级
+	Role        string `json:"role,omitempty"`   // publish 或 subscribe，为空代表均可
+	Exp         int64  `json:"exp,omitempty"`    // 过期时间，UNIX 时间戳(秒)，必须提供，不接受永不过期的令牌
+	Iat         int64  `json:"iat,omitempty"`    // 签发时间
+	MaxDuration int64  `json:"maxdur,omitempty"` // 最长播放时长(秒)，到期后引擎停止该订阅者
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// MatchStreamPath 流路径通配符匹配，pattern 为空或者 ** 匹配所有
+func MatchStreamPath(pattern, streamPath string) bool {
+	if pattern == "" || pattern == "**" {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/**") {
+		return strings.HasPrefix(streamPath, strings.TrimSuffix(pattern, "**"))
+	}
+	ok, _ := path.Match(pattern, streamPath)
+	return ok
+}
+
+// IsJWT 判断鉴权参数是否为JWT，md5签名是十六进制字符串不会包含.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// rsaKeyCache 缓存的密钥和读取时文件的修改时间、大小
+type rsaKeyCache struct {
+	key     any // *rsa.PrivateKey 或 *rsa.PublicKey
+	modTime time.Time
+	size    int64
+}
+
+var rsaKeys sync.Map // 文件路径 -> rsaKeyCache
+
+// loadRSAKey 读取PEM格式的RSA私钥或者公钥，结果按文件路径缓存，文件修改后重新读取
+func loadRSAKey(file string) (any, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := rsaKeys.Load(file); ok {
+		if c := v.(rsaKeyCache); c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+			return c.key, nil
+		}
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no pem data in %s", file)
+	}
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey, *rsa.PublicKey:
+	default:
+		return nil, fmt.Errorf("%s is not a rsa key", file)
+	}
+	rsaKeys.Store(file, rsaKeyCache{key, info.ModTime(), info.Size()})
+	return key, nil
+}
+
+func jwtEncode(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SignJWT 签发令牌，HS256 使用 key 作为密钥，RS256 使用 rsaKeyFile 中的私钥
+func SignJWT(alg string, key string, rsaKeyFile string, claims *JWTClaims) (token string, err error) {
+	header, err := jwtEncode(jwtHeader{alg, "JWT"})
+	if err != nil {
+		return
+	}
+	payload, err := jwtEncode(claims)
+	if err != nil {
+		return
+	}
+	signing := header + "." + payload
+	var sig []byte
+	switch alg {
+	case JWT_HS256:
+		if key == "" {
+			return "", errors.New("no key for HS256")
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(signing))
+		sig = mac.Sum(nil)
+	case JWT_RS256:
+		var k any
+		if k, err = loadRSAKey(rsaKeyFile); err != nil {
+			return
+		}
+		privateKey, ok := k.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("RS256 signing needs a private key")
+		}
+		digest := sha256.Sum256([]byte(signing))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:]); err != nil {
+			return
+		}
+	default:
+		return "", ErrJWTAlg
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// ParseJWT 校验令牌签名和有效期，返回其中的声明
+func ParseJWT(token string, key string, rsaKeyFile string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTFormat
+	}
+	var header jwtHeader
+	var claims JWTClaims
+	b, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(b, &header) != nil {
+		return nil, ErrJWTFormat
+	}
+	if b, err = base64.RawURLEncoding.DecodeString(parts[1]); err != nil || json.Unmarshal(b, &claims) != nil {
+		return nil, ErrJWTFormat
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTFormat
+	}
+	signing := parts[0] + "." + parts[1]
+	switch header.Alg {
+	case JWT_HS256:
+		if key == "" {
+			return nil, ErrJWTAlg
+		}
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(signing))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return nil, ErrJWTSignature
+		}
+	case JWT_RS256:
+		if rsaKeyFile == "" {
+			return nil, ErrJWTAlg
+		}
+		k, err := loadRSAKey(rsaKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		var publicKey *rsa.PublicKey
+		switch v := k.(type) {
+		case *rsa.PrivateKey:
+			publicKey = &v.PublicKey
+		case *rsa.PublicKey:
+			publicKey = v
+		}
+		digest := sha256.Sum256([]byte(signing))
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], sig) != nil {
+			return nil, ErrJWTSignature
+		}
+	default:
+		return nil, ErrJWTAlg
+	}
+	if claims.Exp <= 0 {
+		return nil, ErrJWTNoExpire
+	}
+	if time.Now().Unix() > claims.Exp {
+		return nil, ErrJWTExpired
+	}
+	return &claims, nil
+}
+
+// Check 校验声明是否允许以指定角色访问流
+func (c *JWTClaims) Check(streamPath string, role string) error {
+	if !MatchStreamPath(c.Path, streamPath) {
+		return ErrJWTPath
+	}
+	if c.Role != "" && c.Role != role {
+		return ErrJWTRole
+	}
+	return nil
+}
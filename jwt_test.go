@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeRSAKey(t *testing.T, file string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err = os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestJWT(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "jwt.pem")
+	writeRSAKey(t, keyFile)
+	exp := time.Now().Add(time.Hour).Unix()
+	for _, alg := range []string{JWT_HS256, JWT_RS256} {
+		t.Run(alg, func(t *testing.T) {
+			token, err := SignJWT(alg, "secret", keyFile, &JWTClaims{Path: "live/*", Role: JWT_ROLE_SUBSCRIBE, Exp: exp, MaxDuration: 60})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !IsJWT(token) {
+				t.Fatalf("IsJWT(%s) = false", token)
+			}
+			claims, err := ParseJWT(token, "secret", keyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.Path != "live/*" || claims.Exp != exp || claims.MaxDuration != 60 {
+				t.Errorf("claims = %+v", claims)
+			}
+			if err = claims.Check("live/test", JWT_ROLE_SUBSCRIBE); err != nil {
+				t.Errorf("Check(live/test, subscribe) = %v", err)
+			}
+			if err = claims.Check("live/test", JWT_ROLE_PUBLISH); err != ErrJWTRole {
+				t.Errorf("Check(live/test, publish) = %v, want ErrJWTRole", err)
+			}
+			if err = claims.Check("vod/test", JWT_ROLE_SUBSCRIBE); err != ErrJWTPath {
+				t.Errorf("Check(vod/test) = %v, want ErrJWTPath", err)
+			}
+			// 修改声明而不重新签名
+			parts := strings.Split(token, ".")
+			payload, _ := jwtEncode(&JWTClaims{Path: "**", Exp: exp})
+			if _, err = ParseJWT(parts[0]+"."+payload+"."+parts[2], "secret", keyFile); err != ErrJWTSignature {
+				t.Errorf("tampered payload = %v, want ErrJWTSignature", err)
+			}
+			sig := []byte(parts[2])
+			if sig[0] == 'A' {
+				sig[0] = 'B'
+			} else {
+				sig[0] = 'A'
+			}
+			if _, err = ParseJWT(parts[0]+"."+parts[1]+"."+string(sig), "secret", keyFile); err != ErrJWTSignature {
+				t.Errorf("tampered signature = %v, want ErrJWTSignature", err)
+			}
+			expired, err := SignJWT(alg, "secret", keyFile, &JWTClaims{Exp: time.Now().Add(-time.Minute).Unix()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = ParseJWT(expired, "secret", keyFile); err != ErrJWTExpired {
+				t.Errorf("expired token = %v, want ErrJWTExpired", err)
+			}
+			noExpire, err := SignJWT(alg, "secret", keyFile, &JWTClaims{Path: "live/*"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err = ParseJWT(noExpire, "secret", keyFile); err != ErrJWTNoExpire {
+				t.Errorf("token without exp = %v, want ErrJWTNoExpire", err)
+			}
+		})
+	}
+	t.Run("wrong key", func(t *testing.T) {
+		token, _ := SignJWT(JWT_HS256, "secret", "", &JWTClaims{})
+		if _, err := ParseJWT(token, "other", ""); err != ErrJWTSignature {
+			t.Errorf("HS256 with other key = %v, want ErrJWTSignature", err)
+		}
+		if _, err := ParseJWT(token, "", keyFile); err != ErrJWTAlg {
+			t.Errorf("HS256 without key = %v, want ErrJWTAlg", err)
+		}
+	})
+	t.Run("key file changed", func(t *testing.T) {
+		token, _ := SignJWT(JWT_RS256, "", keyFile, &JWTClaims{})
+		writeRSAKey(t, keyFile)
+		later := time.Now().Add(time.Minute)
+		os.Chtimes(keyFile, later, later)
+		if _, err := ParseJWT(token, "", keyFile); err != ErrJWTSignature {
+			t.Errorf("token signed with replaced key = %v, want ErrJWTSignature", err)
+		}
+	})
+}
+
+func TestMatchStreamPath(t *testing.T) {
+	for _, c := range []struct {
+		pattern, streamPath string
+		match               bool
+	}{
+		{"", "live/test", true},
+		{"**", "live/a/b", true},
+		{"live/*", "live/test", true},
+		{"live/*", "live/a/b", false},
+		{"live/**", "live/a/b", true},
+		{"live/**", "vod/a", false},
+		{"live/test", "live/test2", false},
+	} {
+		if got := MatchStreamPath(c.pattern, c.streamPath); got != c.match {
+			t.Errorf("MatchStreamPath(%s, %s) = %v, want %v", c.pattern, c.streamPath, got, c.match)
+		}
+	}
+}
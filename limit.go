@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+	"m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/config"
+)
+
+var (
+	ErrOverload        = errors.New("Server Overload") // 准入控制拒绝，插件可以据此返回503、NetStream.Play.Failed等
+	ErrSubscriberLimit = fmt.Errorf("%w: subscriber limit reached", ErrOverload)
+	ErrPublisherLimit  = fmt.Errorf("%w: publisher limit reached", ErrOverload)
+	ErrBandwidthLimit  = fmt.Errorf("%w: bandwidth limit reached", ErrOverload)
+)
+
+// limitConfig 热更新后的准入限制，为nil时使用启动时的EngineConfig.Limit
+var limitConfig atomic.Pointer[config.Limit]
+
+func currentLimit() *config.Limit {
+	if limit := limitConfig.Load(); limit != nil {
+		return limit
+	}
+	return &EngineConfig.Limit
+}
+
+// appCounter 同一App下所有流的统计
+type appCounter struct {
+	subscribers atomic.Int64
+	publishers  atomic.Int64
+}
+
+// admission 准入控制使用的统计，每个流在自己的协程中把变化累加进来，判断时不需要访问其他流
+var admission struct {
+	sync.Mutex
+	apps        map[string]*appCounter
+	subscribers atomic.Int64
+	bandwidth   atomic.Int64
+}
+
+func getAppCounter(appName string) *appCounter {
+	admission.Lock()
+	defer admission.Unlock()
+	if admission.apps == nil {
+		admission.apps = make(map[string]*appCounter)
+	}
+	c, ok := admission.apps[appName]
+	if !ok {
+		c = &appCounter{}
+		admission.apps[appName] = c
+	}
+	return c
+}
+
+// streamCounter 流已经累加到统计中的数值，只在流的协程中使用
+type streamCounter struct {
+	app         *appCounter
+	subscribers int64
+	publishing  bool
+	bandwidth   int64
+}
+
+// bps 流所有track的码率之和(字节/秒)
+func (s *Stream) bps() (bps int) {
+	s.Tracks.Range(func(_ string, t common.Track) {
+		bps += t.GetBase().BPS
+	})
+	return
+}
+
+// isPublishing 是否有正在发布的发布者，热备发布者不计算在内
+func (s *Stream) isPublishing() bool {
+	return s.Publisher != nil && !s.Publisher.IsClosed()
+}
+
+// syncCounter 在流的协程中调用，把订阅者数量、是否正在发布和估算的出口带宽的变化累加到统计中，release为true时全部减去
+func (s *Stream) syncCounter(release bool) {
+	c := &s.counter
+	if c.app == nil {
+		if release {
+			return
+		}
+		c.app = getAppCounter(s.AppName)
+	}
+	var subscribers, bandwidth int64
+	var publishing bool
+	if !release {
+		subscribers = int64(s.Subscribers.Len())
+		publishing = s.isPublishing()
+		if subscribers > 0 {
+			bandwidth = subscribers * int64(s.bps())
+		}
+	}
+	if d := subscribers - c.subscribers; d != 0 {
+		admission.subscribers.Add(d)
+		c.app.subscribers.Add(d)
+		c.subscribers = subscribers
+	}
+	if publishing != c.publishing {
+		if publishing {
+			c.app.publishers.Add(1)
+		} else {
+			c.app.publishers.Add(-1)
+		}
+		c.publishing = publishing
+	}
+	if d := bandwidth - c.bandwidth; d != 0 {
+		admission.bandwidth.Add(d)
+		c.bandwidth = bandwidth
+	}
+	if release {
+		c.app = nil
+	}
+}
+
+// reserve 先占用n再与max比较，超出时归还，并发的准入判断不会同时通过检查而超出限制，max不大于0时不限制
+func reserve(counter *atomic.Int64, n int64, max int64) bool {
+	if counter.Add(n) > max && max > 0 {
+		counter.Add(-n)
+		return false
+	}
+	return true
+}
+
+// admitSubscriber 订阅者准入控制，在流的协程中调用，内部订阅者不受限制
+// 通过时已经占用了名额，记入流已经累加的数值，订阅者随后加入，syncCounter不会重复累加
+func (s *Stream) admitSubscriber() error {
+	limit := currentLimit()
+	if limit.MaxStreamSubscribers > 0 && s.Subscribers.Len() >= limit.MaxStreamSubscribers {
+		return ErrSubscriberLimit
+	}
+	s.syncCounter(false)
+	c := &s.counter
+	if !reserve(&admission.subscribers, 1, int64(limit.MaxSubscribers)) {
+		return ErrSubscriberLimit
+	}
+	if !reserve(&c.app.subscribers, 1, int64(limit.AppLimit(limit.MaxAppSubscribers, s.AppName))) {
+		admission.subscribers.Add(-1)
+		return ErrSubscriberLimit
+	}
+	bps := int64(s.bps())
+	if !reserve(&admission.bandwidth, bps, int64(limit.MaxBandwidth)) {
+		admission.subscribers.Add(-1)
+		c.app.subscribers.Add(-1)
+		return ErrBandwidthLimit
+	}
+	c.subscribers++
+	c.bandwidth += bps
+	return nil
+}
+
+// admitPublisher 发布者准入控制，在流的协程中调用，只统计同一App下其他正在发布的流
+func (s *Stream) admitPublisher() error {
+	limit := currentLimit()
+	maxApp := limit.AppLimit(limit.MaxAppPublishers, s.AppName)
+	if maxApp <= 0 {
+		return nil
+	}
+	s.syncCounter(false)
+	c := &s.counter
+	// 已经在发布的流更换发布者，不需要再占用名额
+	if c.publishing {
+		if c.app.publishers.Load()-1 >= int64(maxApp) {
+			return ErrPublisherLimit
+		}
+		return nil
+	}
+	if !reserve(&c.app.publishers, 1, int64(maxApp)) {
+		return ErrPublisherLimit
+	}
+	c.publishing = true
+	return nil
+}
+
+// parseLimit 在保存配置之前解析并校验修改后的准入限制，没有修改limit时返回nil
+func parseLimit(modified config.Config) (limit *config.Limit, err error) {
+	v, ok := modified["limit"]
+	if !ok {
+		return nil, nil
+	}
+	// 经过yaml转换，使得json提交的配置也能得到config.Config类型的子配置
+	b, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var c config.Config
+	if err = yaml.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	l := *currentLimit()
+	// map需要重新创建，避免修改正在使用的map
+	if c.Has("maxappsubscribers") {
+		l.MaxAppSubscribers = nil
+	}
+	if c.Has("maxapppublishers") {
+		l.MaxAppPublishers = nil
+	}
+	// 类型不匹配时Unmarshal会panic
+	defer func() {
+		if r := recover(); r != nil {
+			limit, err = nil, fmt.Errorf("invalid limit: %v", r)
+		}
+	}()
+	c.Unmarshal(&l)
+	values := []int{l.MaxSubscribers, l.MaxStreamSubscribers, l.MaxBandwidth}
+	for _, m := range []map[string]int{l.MaxAppSubscribers, l.MaxAppPublishers} {
+		for _, v := range m {
+			values = append(values, v)
+		}
+	}
+	for _, v := range values {
+		if v < 0 {
+			return nil, fmt.Errorf("invalid limit: %d is negative", v)
+		}
+	}
+	return &l, nil
+}
@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"m7s.live/engine/v4/config"
+)
+
+func TestReserve(t *testing.T) {
+	var counter atomic.Int64
+	var admitted atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if reserve(&counter, 1, 10) {
+				admitted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if admitted.Load() != 10 || counter.Load() != 10 {
+		t.Errorf("admitted %d, counter %d, want 10", admitted.Load(), counter.Load())
+	}
+	// 不限制时同样占用
+	if !reserve(&counter, 5, 0) || counter.Load() != 15 {
+		t.Errorf("unlimited: counter %d, want 15", counter.Load())
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	defer func(conf *GlobalConfig) { EngineConfig = conf }(EngineConfig)
+	EngineConfig = &GlobalConfig{}
+	limit, err := parseLimit(config.Config{"limit": map[string]any{"maxsubscribers": 100, "maxappsubscribers": map[string]any{"live": 10}}})
+	if err != nil || limit.MaxSubscribers != 100 || limit.MaxAppSubscribers["live"] != 10 {
+		t.Errorf("parseLimit = %+v, %v", limit, err)
+	}
+	if limit, err = parseLimit(config.Config{"publish": map[string]any{}}); limit != nil || err != nil {
+		t.Errorf("without limit: parseLimit = %+v, %v", limit, err)
+	}
+	for _, v := range []any{
+		map[string]any{"maxsubscribers": -1},
+		map[string]any{"maxapppublishers": map[string]any{"*": -1}},
+		map[string]any{"maxbandwidth": "abc"},
+	} {
+		if limit, err = parseLimit(config.Config{"limit": v}); err == nil {
+			t.Errorf("parseLimit(%v) = %+v, want error", v, limit)
+		}
+	}
+}
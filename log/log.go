@@ -2,6 +2,7 @@ package log
 
 import (
 	// . "github.com/logrusorgru/aurora"
+	"fmt"
 	"io"
 
 	// "github.com/mattn/go-colorable"
@@ -81,6 +82,38 @@ func (l Logger) With(fields ...zap.Field) *Logger {
 	return &l
 }
 
+// WithDynamic 添加一个在每次输出时才取值的字段，用于会改变的值（例如流被移动后的路径），之后With出的Logger同样带有该字段
+func (l Logger) WithDynamic(key string, value fmt.Stringer) *Logger {
+	if v, ok := l.lang[key]; ok {
+		key = v
+	}
+	l.Logger = l.Logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &dynamicCore{core, key, value}
+	}))
+	return &l
+}
+
+type dynamicCore struct {
+	zapcore.Core
+	key   string
+	value fmt.Stringer
+}
+
+func (c *dynamicCore) With(fields []zapcore.Field) zapcore.Core {
+	return &dynamicCore{c.Core.With(fields), c.key, c.value}
+}
+
+func (c *dynamicCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *dynamicCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, append([]zapcore.Field{zap.String(c.key, c.value.String())}, fields...))
+}
+
 func (l *Logger) formatLang(msg *string, fields []zapcore.Field) {
 	if l.lang != nil {
 		if v, ok := l.lang[*msg]; ok {
@@ -109,6 +109,7 @@ func Run(ctx context.Context, configFile string) (err error) {
 	Engine.assign()
 	Engine.Logger.Debug("", zap.Any("config", EngineConfig))
 	EventBus = make(chan any, EngineConfig.EventBusSize)
+	webhook.Start(ctx, &EngineConfig.Webhook)
 	go EngineConfig.Listen(Engine)
 	for _, plugin := range plugins {
 		plugin.Logger = log.LocaleLogger.Named(plugin.Name)
@@ -209,6 +210,7 @@ func Run(ctx context.Context, configFile string) (err error) {
 				}
 			}
 			EngineConfig.OnEvent(event)
+			webhook.OnEvent(event)
 			if cost := time.Since(ts); cost > time.Millisecond*100 {
 				log.Warn("event cost too much time", zap.String("event", fmt.Sprintf("%v", event)), zap.Duration("cost", cost))
 			}
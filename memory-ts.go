@@ -153,6 +153,9 @@ func (ts *MemoryTs) WriteAudioFrame(frame AudioFrame, pes *mpegts.MpegtsPESFrame
 	packet.Header.PacketStartCodePrefix = 0x000001
 	packet.Header.ConstTen = 0x80
 	packet.Header.StreamID = mpegts.STREAM_ID_AUDIO
+	if frame.CodecID == codec.CodecID_AC3 || frame.CodecID == codec.CodecID_EAC3 {
+		packet.Header.StreamID = mpegts.STREAM_ID_PRIVATE_1
+	}
 	packet.Header.Pts = uint64(frame.PTS)
 	pes.ProgramClockReferenceBase = packet.Header.Pts
 	packet.Header.PtsDtsFlags = 0x80
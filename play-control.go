@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	MinPlaySpeed = 0.25
+	MaxPlaySpeed = 8
+)
+
+var (
+	ErrNotPlaying  = errors.New("Not Playing")
+	ErrBadSpeed    = errors.New("Bad Speed")
+	ErrControlBusy = errors.New("Control Busy")
+)
+
+// 播放控制命令，通过Subscriber.Control发给正在PlayBlock中的订阅者
+type (
+	PlaySpeed  float64       // 倍速，范围MinPlaySpeed~MaxPlaySpeed
+	PlayPause  struct{}      // 暂停，暂停期间不读取轨道，恢复后如果落后于缓冲会跳帧
+	PlayResume struct{}      // 继续播放
+	PlaySeek   time.Duration // 跳转到轨道中的绝对时间戳，即AVFrame.Timestamp
+)
+
+// playControl PlayBlock中的播放控制状态，只在PlayBlock所在协程中使用
+type playControl struct {
+	commands chan any
+	speed    float64
+	paused   bool
+	seek     *PlaySeek
+	noSpeed  bool      // RTP的时间戳不经过AVRingReader缩放，纯音频倍速播放会失真，都不支持倍速
+	noSeek   bool      // RTP不支持跳转
+	start    time.Time // 倍速播放时控制速度的起点
+	startAbs uint32
+}
+
+func (s *Subscriber) newPlayControl(rtp bool, hasVideo bool) *playControl {
+	pc := &playControl{commands: make(chan any, 8), speed: 1, noSpeed: rtp || !hasVideo, noSeek: rtp}
+	if arg := s.Args.Get(s.Config.SpeedArgName); arg != "" {
+		if speed, err := strconv.ParseFloat(arg, 64); err != nil || speed < MinPlaySpeed || speed > MaxPlaySpeed {
+			s.Warn("invalid speed", zap.String("speed", arg))
+		} else {
+			pc.commands <- PlaySpeed(speed)
+		}
+	}
+	return pc
+}
+
+// Control 发送播放控制命令，在PlayBlock的下一次读取之前生效
+func (s *Subscriber) Control(cmd any) error {
+	switch v := cmd.(type) {
+	case PlaySpeed:
+		if v < MinPlaySpeed || v > MaxPlaySpeed {
+			return ErrBadSpeed
+		}
+	case PlayPause, PlayResume, PlaySeek:
+	default:
+		return errors.New("unknown play control")
+	}
+	pc := s.playControl.Load()
+	if pc == nil || !s.IsPlaying() {
+		return ErrNotPlaying
+	}
+	select {
+	case pc.commands <- cmd:
+		return nil
+	default:
+		return ErrControlBusy
+	}
+}
+
+// handle 处理积压的命令，暂停时阻塞直到继续播放或者ctx结束，返回是否需要跳转
+func (pc *playControl) handle(ctx context.Context, s *Subscriber) (seek bool) {
+	for {
+		var cmd any
+		if pc.paused {
+			select {
+			case cmd = <-pc.commands:
+			case <-ctx.Done():
+				return false
+			}
+		} else {
+			select {
+			case cmd = <-pc.commands:
+			default:
+				return pc.applySeek(s)
+			}
+		}
+		switch v := cmd.(type) {
+		case PlayPause:
+			s.Info("pause")
+			pc.paused = true
+		case PlayResume:
+			s.Info("resume")
+			pc.paused = false
+		case PlaySpeed:
+			if pc.noSpeed {
+				s.Warn("speed not supported")
+				break
+			}
+			s.Info("speed", zap.Float64("speed", float64(v)))
+			pc.speed = float64(v)
+			for _, r := range s.readers() {
+				r.SetSpeed(pc.speed)
+			}
+		case PlaySeek:
+			if pc.noSeek {
+				s.Warn("seek not supported")
+				break
+			}
+			pc.seek = &v
+		}
+		pc.start = time.Time{}
+	}
+}
+
+func (pc *playControl) applySeek(s *Subscriber) bool {
+	if pc.seek == nil {
+		return false
+	}
+	ts := time.Duration(*pc.seek)
+	pc.seek = nil
+	s.Info("seek", zap.Duration("ts", ts))
+	for _, r := range s.readers() {
+		r.Seek(ts)
+	}
+	return true
+}
+
+// iframeOnly 倍速较大时只发送关键帧
+func (pc *playControl) iframeOnly(s *Subscriber) bool {
+	return pc.speed > s.Config.IFrameOnlySpeed
+}
+
+// pace 倍速播放时按缩放后的时间戳控制发送速度，正常速度时不控制
+func (pc *playControl) pace(ctx context.Context, absTime uint32) {
+	if pc.speed == 1 {
+		return
+	}
+	if pc.start.IsZero() {
+		pc.start, pc.startAbs = time.Now(), absTime
+		return
+	}
+	wait := time.Duration(absTime-pc.startAbs)*time.Millisecond - time.Since(pc.start)
+	if wait > time.Second {
+		// 时间戳跳变（例如跳帧追赶）时重新开始计时
+		pc.start = time.Time{}
+		return
+	}
+	if wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// Control 向流中指定ID的订阅者发送播放控制命令
+func (s *Stream) Control(id string, cmd any) error {
+	sub, ok := s.FindIO(id).(ISubscriber)
+	if !ok {
+		return ErrNotPlaying
+	}
+	return sub.GetSubscriber().Control(cmd)
+}
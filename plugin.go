@@ -228,8 +228,7 @@ func (opt *Plugin) Publish(streamPath string, pub IPublisher) error {
 		if !ok {
 			conf = EngineConfig
 		}
-		copyConfig := conf.GetPublishConfig()
-		puber.Config = &copyConfig
+		puber.Config = StreamPublishConfig(streamPath, conf.GetPublishConfig())
 	}
 	return pub.Publish(streamPath, pub)
 }
@@ -262,8 +261,7 @@ func (opt *Plugin) Subscribe(streamPath string, sub ISubscriber) error {
 		if !ok {
 			conf = EngineConfig
 		}
-		copyConfig := *conf.GetSubscribeConfig()
-		suber.Config = &copyConfig
+		suber.Config = StreamSubscribeConfig(streamPath, *conf.GetSubscribeConfig())
 	}
 	return sub.Subscribe(streamPath, sub)
 }
@@ -309,6 +307,9 @@ func (opt *Plugin) Pull(streamPath string, url string, puller IPuller, save int)
 				opt.Error("pull connect", zurl, zap.Error(err))
 				time.Sleep(time.Second * 5)
 			} else {
+				if stream := puller.GetPublisher().Stream; stream != nil {
+					streamPath = stream.GetPath() // 流可能已经被移动到新的路径
+				}
 				if err = opt.Publish(streamPath, puller); err != nil {
 					if stream := Streams.Get(streamPath); stream != nil {
 						if stream.Publisher != puller && stream.Publisher != nil {
@@ -375,6 +376,9 @@ func (opt *Plugin) Push(streamPath string, url string, pusher IPusher, save bool
 		Pushers.Store(url, pusher)
 		defer Pushers.Delete(url)
 		for opt.Info("start push", zp, zu); pusher.Reconnect(); opt.Warn("restart push", zp, zu) {
+			if stream := pusher.GetSubscriber().Stream; stream != nil {
+				streamPath = stream.GetPath() // 流可能已经被移动到新的路径
+			}
 			if err = opt.Subscribe(streamPath, pusher); err != nil {
 				opt.Error("push subscribe", zp, zu, zap.Error(err))
 				time.Sleep(time.Second * 5)
@@ -413,3 +417,31 @@ func (opt *Plugin) Push(streamPath string, url string, pusher IPusher, save bool
 	}
 	return
 }
+
+// moveStreamPath 流被移动后同步修改拉流和推流配置中的流路径并保存
+func (opt *Plugin) moveStreamPath(oldPath string, streamPath string) {
+	moved := false
+	if conf, ok := opt.Config.(config.PullConfig); ok {
+		if pullConf := conf.GetPullConfig(); pullConf.MoveStreamPath(oldPath, streamPath) {
+			if opt.Modified == nil {
+				opt.Modified = make(config.Config)
+			}
+			opt.Modified["pull"] = config.Struct2Config(pullConf)
+			moved = true
+		}
+	}
+	if conf, ok := opt.Config.(config.PushConfig); ok {
+		if pushConf := conf.GetPushConfig(); pushConf.MoveStreamPath(oldPath, streamPath) {
+			if opt.Modified == nil {
+				opt.Modified = make(config.Config)
+			}
+			opt.Modified["push"] = config.Struct2Config(pushConf)
+			moved = true
+		}
+	}
+	if moved {
+		if err := opt.Save(); err != nil {
+			opt.Error("save faild", zap.Error(err))
+		}
+	}
+}
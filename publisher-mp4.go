@@ -31,15 +31,15 @@ func (p *MP4Publisher) ReadMP4Data(source io.ReadSeeker) error {
 			p.Info("MP4 track", zap.Any("track", t))
 			switch t.Cid {
 			case mp4.MP4_CODEC_H264:
-				p.VideoTrack = track.NewH264(p.Stream)
+				p.VideoTrack = track.NewH264(p.Stream, &p.Publisher)
 			case mp4.MP4_CODEC_H265:
-				p.VideoTrack = track.NewH265(p.Stream)
+				p.VideoTrack = track.NewH265(p.Stream, &p.Publisher)
 			case mp4.MP4_CODEC_AAC:
-				p.AudioTrack = track.NewAAC(p.Stream)
+				p.AudioTrack = track.NewAAC(p.Stream, &p.Publisher)
 			case mp4.MP4_CODEC_G711A:
-				p.AudioTrack = track.NewG711(p.Stream, true)
+				p.AudioTrack = track.NewG711(p.Stream, true, &p.Publisher)
 			case mp4.MP4_CODEC_G711U:
-				p.AudioTrack = track.NewG711(p.Stream, false)
+				p.AudioTrack = track.NewG711(p.Stream, false, &p.Publisher)
 			}
 		}
 		for {
@@ -48,6 +48,9 @@ func (p *MP4Publisher) ReadMP4Data(source io.ReadSeeker) error {
 				p.Error("Error reading MP4 packet", zap.Error(err))
 				return err
 			}
+			if !p.beginWrite() {
+				return nil
+			}
 			switch pkg.Cid {
 			case mp4.MP4_CODEC_H264, mp4.MP4_CODEC_H265:
 				p.VideoTrack.WriteAnnexB(uint32(pkg.Pts*90), uint32(pkg.Dts*90), pkg.Data)
@@ -56,6 +59,7 @@ func (p *MP4Publisher) ReadMP4Data(source io.ReadSeeker) error {
 			case mp4.MP4_CODEC_G711A, mp4.MP4_CODEC_G711U:
 				p.AudioTrack.WriteRaw(uint32(pkg.Pts*90), pkg.Data)
 			}
+			p.endWrite()
 		}
 	}
 }
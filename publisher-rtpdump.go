@@ -34,16 +34,22 @@ func (t *RTPDumpPublisher) Feed(file *os.File) {
 	if t.VideoTrack == nil {
 		switch t.VCodec {
 		case codec.CodecID_H264:
-			t.VideoTrack = track.NewH264(t.Publisher.Stream)
+			t.VideoTrack = track.NewH264(t.Publisher.Stream, &t.Publisher)
 		case codec.CodecID_H265:
-			t.VideoTrack = track.NewH265(t.Publisher.Stream)
+			t.VideoTrack = track.NewH265(t.Publisher.Stream, &t.Publisher)
+		case codec.CodecID_AV1:
+			t.VideoTrack = track.NewAV1(t.Publisher.Stream, &t.Publisher)
+		case codec.CodecID_VP8:
+			t.VideoTrack = track.NewVP8(t.Publisher.Stream, &t.Publisher)
+		case codec.CodecID_VP9:
+			t.VideoTrack = track.NewVP9(t.Publisher.Stream, &t.Publisher)
 		}
 		t.VideoTrack.SetSpeedLimit(500 * time.Millisecond)
 	}
 	if t.AudioTrack == nil {
 		switch t.ACodec {
 		case codec.CodecID_AAC:
-			at := track.NewAAC(t.Publisher.Stream)
+			at := track.NewAAC(t.Publisher.Stream, &t.Publisher)
 			t.AudioTrack = at
 			var c mpeg4audio.Config
 			c.ChannelCount = 2
@@ -51,9 +57,15 @@ func (t *RTPDumpPublisher) Feed(file *os.File) {
 			asc, _ := c.Marshal()
 			at.WriteSequenceHead(append([]byte{0xAF, 0x00}, asc...))
 		case codec.CodecID_PCMA:
-			t.AudioTrack = track.NewG711(t.Publisher.Stream, true)
+			t.AudioTrack = track.NewG711(t.Publisher.Stream, true, &t.Publisher)
 		case codec.CodecID_PCMU:
-			t.AudioTrack = track.NewG711(t.Publisher.Stream, false)
+			t.AudioTrack = track.NewG711(t.Publisher.Stream, false, &t.Publisher)
+		case codec.CodecID_OPUS:
+			t.AudioTrack = track.NewOpus(t.Publisher.Stream, &t.Publisher)
+		case codec.CodecID_MP3:
+			t.AudioTrack = track.NewMP3(t.Publisher.Stream, &t.Publisher)
+		case codec.CodecID_AC3, codec.CodecID_EAC3:
+			t.AudioTrack = track.NewAC3(t.Publisher.Stream, t.ACodec == codec.CodecID_EAC3, &t.Publisher)
 		}
 		t.AudioTrack.SetSpeedLimit(500 * time.Millisecond)
 	}
@@ -89,12 +101,16 @@ func (t *RTPDumpPublisher) Feed(file *os.File) {
 	}
 }
 func (t *RTPDumpPublisher) WriteRTP(raw []byte) {
+	if !t.beginWrite() {
+		return
+	}
+	defer t.endWrite()
 	var frame common.RTPFrame
 	frame.Unmarshal(raw)
 	switch frame.PayloadType {
 	case 96:
 		t.VideoTrack.WriteRTP(&util.ListItem[common.RTPFrame]{Value: frame})
-	case 97, 0, 8:
+	case 97, 0, 8, 14:
 		t.AudioTrack.WriteRTP(&util.ListItem[common.RTPFrame]{Value: frame})
 	default:
 		t.Stream.Warn("RTPDumpPublisher unknown payload type", zap.Uint8("payloadType", frame.PayloadType))
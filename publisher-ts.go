@@ -36,23 +36,38 @@ func (t *TSPublisher) OnPmtStream(s mpegts.MpegTsPmtStream) {
 	switch s.StreamType {
 	case mpegts.STREAM_TYPE_H264:
 		if t.VideoTrack == nil {
-			t.VideoTrack = track.NewH264(t.Publisher.Stream, t.pool)
+			t.VideoTrack = track.NewH264(t.Publisher.Stream, t.pool, &t.Publisher)
 		}
 	case mpegts.STREAM_TYPE_H265:
 		if t.VideoTrack == nil {
-			t.VideoTrack = track.NewH265(t.Publisher.Stream, t.pool)
+			t.VideoTrack = track.NewH265(t.Publisher.Stream, t.pool, &t.Publisher)
 		}
 	case mpegts.STREAM_TYPE_AAC:
 		if t.AudioTrack == nil {
-			t.AudioTrack = track.NewAAC(t.Publisher.Stream, t.pool)
+			t.AudioTrack = track.NewAAC(t.Publisher.Stream, t.pool, &t.Publisher)
 		}
 	case mpegts.STREAM_TYPE_G711A:
 		if t.AudioTrack == nil {
-			t.AudioTrack = track.NewG711(t.Publisher.Stream, true, t.pool)
+			t.AudioTrack = track.NewG711(t.Publisher.Stream, true, t.pool, &t.Publisher)
 		}
 	case mpegts.STREAM_TYPE_G711U:
 		if t.AudioTrack == nil {
-			t.AudioTrack = track.NewG711(t.Publisher.Stream, false, t.pool)
+			t.AudioTrack = track.NewG711(t.Publisher.Stream, false, t.pool, &t.Publisher)
+		}
+	case mpegts.STREAM_TYPE_AUDIO_MPEG1, mpegts.STREAM_TYPE_AUDIO_MPEG2:
+		if t.AudioTrack == nil {
+			t.AudioTrack = track.NewMP3(t.Publisher.Stream, t.pool, &t.Publisher)
+		}
+	case mpegts.STREAM_TYPE_AC3, mpegts.STREAM_TYPE_EAC3:
+		if t.AudioTrack == nil {
+			t.AudioTrack = track.NewAC3(t.Publisher.Stream, s.StreamType == mpegts.STREAM_TYPE_EAC3, t.pool, &t.Publisher)
+		}
+	case mpegts.STREAM_TYPE_PRIVATE_DATA:
+		// DVB 中的 AC-3 和 E-AC-3
+		for _, d := range s.Descriptor {
+			if t.AudioTrack == nil && (d.Tag == mpegts.DESCRIPTOR_TAG_AC3 || d.Tag == mpegts.DESCRIPTOR_TAG_EAC3) {
+				t.AudioTrack = track.NewAC3(t.Publisher.Stream, d.Tag == mpegts.DESCRIPTOR_TAG_EAC3, t.pool, &t.Publisher)
+			}
 		}
 	default:
 		t.Warn("unsupport stream type:", zap.Uint8("type", s.StreamType))
@@ -64,6 +79,9 @@ func (t *TSPublisher) ReadPES() {
 		if pes.Header.Dts == 0 {
 			pes.Header.Dts = pes.Header.Pts
 		}
+		if !t.beginWrite() {
+			continue
+		}
 		switch pes.Header.StreamID & 0xF0 {
 		case mpegts.STREAM_ID_VIDEO:
 			if t.VideoTrack == nil {
@@ -84,10 +102,11 @@ func (t *TSPublisher) ReadPES() {
 				switch t.AudioTrack.(type) {
 				case *track.AAC:
 					t.AudioTrack.WriteADTS(uint32(pes.Header.Pts), pes.Payload)
-				case *track.G711:
+				case *track.G711, *track.MP3, *track.AC3:
 					t.AudioTrack.WriteRaw(uint32(pes.Header.Pts), pes.Payload)
 				}
 			}
 		}
+		t.endWrite()
 	}
 }
@@ -1,6 +1,9 @@
 package engine
 
 import (
+	"reflect"
+	"sync"
+
 	"go.uber.org/zap"
 	"m7s.live/engine/v4/codec"
 	"m7s.live/engine/v4/common"
@@ -19,11 +22,23 @@ type IPublisher interface {
 
 var _ IPublisher = (*Publisher)(nil)
 
+// IStandbyPublisher 可以作为热备加入的发布者，需要只通过Publisher的WriteAVCCVideo、WriteAVCCAudio写入track
+// 热备接替在这两个方法中完成，直接写入track（例如RTP、裸帧）的发布者无法接管前任的track，不能作为热备
+type IStandbyPublisher interface {
+	IPublisher
+	SupportStandby() bool
+}
+
 type Publisher struct {
 	IO
 	Config            *config.Publish
+	Priority          int  // 热备优先级
+	Standby           bool // 是否为热备发布者
 	common.AudioTrack `json:"-" yaml:"-"`
 	common.VideoTrack `json:"-" yaml:"-"`
+
+	switchC   chan IPublisher // 热备发布者接替时收到前任，在自己的协程中下一次写入之前处理
+	writeLock sync.Mutex      // 写入track期间持有，接替者据此等待前任停止写入
 }
 
 func (p *Publisher) Publish(streamPath string, pub IPublisher) error {
@@ -36,7 +51,7 @@ func (p *Publisher) GetPublisher() *Publisher {
 
 func (p *Publisher) Stop() {
 	p.IO.Stop()
-	p.Stream.Receive(ACTION_PUBLISHLOST)
+	p.Stream.Receive(PublisherLost{p.Spesific.(IPublisher)})
 }
 func (p *Publisher) getAudioTrack() common.AudioTrack {
 	return p.AudioTrack
@@ -47,6 +62,37 @@ func (p *Publisher) getVideoTrack() common.VideoTrack {
 func (p *Publisher) Equal(p2 IPublisher) bool {
 	return p == p2.GetPublisher()
 }
+
+// IsStandby 实现common.TrackOwner，只在流的协程中修改
+func (p *Publisher) IsStandby() bool {
+	return p.Standby
+}
+
+// PublishConfig 实现common.TrackOwner
+func (p *Publisher) PublishConfig() *config.Publish {
+	return p.Config
+}
+
+// beginWrite 写入track之前调用，处理热备接替，已经被踢出时返回false，不再写入track
+// writeLock只在接替时才有竞争，平时的加锁开销可以忽略；不经过这里写入track的发布者不能作为热备，见IStandbyPublisher
+func (p *Publisher) beginWrite() bool {
+	p.writeLock.Lock()
+	if p.Context != nil && p.IsClosed() {
+		p.writeLock.Unlock()
+		return false
+	}
+	select {
+	case old := <-p.switchC:
+		p.takeOver(old)
+	default:
+	}
+	return true
+}
+
+func (p *Publisher) endWrite() {
+	p.writeLock.Unlock()
+}
+
 func (p *Publisher) OnEvent(event any) {
 	switch v := event.(type) {
 	case IPublisher:
@@ -56,22 +102,81 @@ func (p *Publisher) OnEvent(event any) {
 			p.AudioTrack = v.getAudioTrack()
 			p.VideoTrack = v.getVideoTrack()
 		}
+	case SwitchPublisherEvent:
+		// 在流的协程中收到，交给发布者自己的协程处理
+		if p.Equal(v.New) && p.switchC != nil {
+			select {
+			case p.switchC <- v.Old:
+			default:
+			}
+		}
 	default:
 		p.IO.OnEvent(event)
 	}
 }
 
+// takeOver 热备发布者接替前任，写入前任的track（订阅者都挂在前任的track上），自身的解码配置同步过去
+// 前任已经被流踢出，等待它正在进行的写入结束，之后前任不会再写入track
+func (p *Publisher) takeOver(old IPublisher) {
+	if old != nil {
+		prev := old.GetPublisher()
+		prev.writeLock.Lock()
+		at, vt := old.getAudioTrack(), old.getVideoTrack()
+		prev.writeLock.Unlock()
+		if at != nil {
+			p.AudioTrack = inheritTrack(p.Stream, at, p.AudioTrack)
+		}
+		if vt != nil {
+			vt.SetLostFlag() // 从下一个关键帧开始接续
+			p.VideoTrack = inheritTrack(p.Stream, vt, p.VideoTrack)
+			// 接续的track按照接替者的配置解析SEI
+			p.VideoTrack.SetStuff(p.Config)
+		}
+	}
+	// 前任没有的track，热备时没有加入流中，现在加入
+	if p.AudioTrack != nil && !p.AudioTrack.GetBase().Attached.Load() {
+		p.AudioTrack.Attach()
+	}
+	if p.VideoTrack != nil && !p.VideoTrack.GetBase().Attached.Load() {
+		p.VideoTrack.Attach()
+	}
+}
+
+func inheritTrack[T common.AVTrack](stream *Stream, old T, own T) T {
+	if any(own) == nil {
+		return old
+	}
+	if reflect.TypeOf(own) != reflect.TypeOf(old) {
+		stream.Error("standby codec mismatch", zap.String("old", reflect.TypeOf(old).String()), zap.String("new", reflect.TypeOf(own).String()))
+		return own
+	}
+	old.CurrentFrame().Reset()
+	if sh := own.GetSequenceHead(); len(sh) > 0 {
+		var frame util.BLL
+		frame.Push(&util.ListItem[util.Buffer]{Value: sh})
+		old.WriteAVCC(0, &frame)
+	}
+	return old
+}
+
 func (p *Publisher) WriteAVCCVideo(ts uint32, frame *util.BLL, pool util.BytesPool) {
-	if frame.ByteLength < 6 {
+	if frame.ByteLength < 6 || !p.beginWrite() {
 		return
 	}
+	defer p.endWrite()
 	if p.VideoTrack == nil {
 		b0 := frame.GetByte(0)
 		// https://github.com/veovera/enhanced-rtmp/blob/main/enhanced-rtmp-v1.pdf
 		if isExtHeader := b0 & 0b1000_0000; isExtHeader != 0 {
-			fourCC := frame.GetUintN(1, 4)
-			if fourCC == codec.FourCC_H265_32 {
-				p.VideoTrack = track.NewH265(p.Stream, pool)
+			switch fourCC := frame.GetUintN(1, 4); fourCC {
+			case codec.FourCC_H265_32:
+				p.VideoTrack = track.NewH265(p.Stream, pool, p)
+				p.VideoTrack.WriteAVCC(ts, frame)
+			case codec.FourCC_AV1_32:
+				p.VideoTrack = track.NewAV1(p.Stream, pool, p)
+				p.VideoTrack.WriteAVCC(ts, frame)
+			case codec.FourCC_VP9_32:
+				p.VideoTrack = track.NewVP9(p.Stream, pool, p)
 				p.VideoTrack.WriteAVCC(ts, frame)
 			}
 		} else {
@@ -79,9 +184,9 @@ func (p *Publisher) WriteAVCCVideo(ts uint32, frame *util.BLL, pool util.BytesPo
 				ts = 0
 				switch codecID := codec.VideoCodecID(b0 & 0x0F); codecID {
 				case codec.CodecID_H264:
-					p.VideoTrack = track.NewH264(p.Stream, pool)
+					p.VideoTrack = track.NewH264(p.Stream, pool, p)
 				case codec.CodecID_H265:
-					p.VideoTrack = track.NewH265(p.Stream, pool)
+					p.VideoTrack = track.NewH265(p.Stream, pool, p)
 				default:
 					p.Stream.Error("video codecID not support", zap.Uint8("codeId", uint8(codecID)))
 					return
@@ -97,9 +202,10 @@ func (p *Publisher) WriteAVCCVideo(ts uint32, frame *util.BLL, pool util.BytesPo
 }
 
 func (p *Publisher) WriteAVCCAudio(ts uint32, frame *util.BLL, pool util.BytesPool) {
-	if frame.ByteLength < 4 {
+	if frame.ByteLength < 4 || !p.beginWrite() {
 		return
 	}
+	defer p.endWrite()
 	if p.AudioTrack == nil {
 		b0 := frame.GetByte(0)
 		switch codecID := codec.AudioCodecID(b0 >> 4); codecID {
@@ -107,7 +213,7 @@ func (p *Publisher) WriteAVCCAudio(ts uint32, frame *util.BLL, pool util.BytesPo
 			if frame.GetByte(1) != 0 {
 				return
 			}
-			a := track.NewAAC(p.Stream, pool)
+			a := track.NewAAC(p.Stream, pool, p)
 			p.AudioTrack = a
 			a.AVCCHead = []byte{frame.GetByte(0), 1}
 			a.WriteAVCC(0, frame)
@@ -117,7 +223,7 @@ func (p *Publisher) WriteAVCCAudio(ts uint32, frame *util.BLL, pool util.BytesPo
 			if codecID == codec.CodecID_PCMU {
 				alaw = false
 			}
-			a := track.NewG711(p.Stream, alaw, pool)
+			a := track.NewG711(p.Stream, alaw, pool, p)
 			p.AudioTrack = a
 			a.Audio.SampleRate = uint32(codec.SoundRate[(b0&0x0c)>>2])
 			if b0&0x02 == 0 {
@@ -126,6 +232,14 @@ func (p *Publisher) WriteAVCCAudio(ts uint32, frame *util.BLL, pool util.BytesPo
 			a.Channels = b0&0x01 + 1
 			a.AVCCHead = []byte{b0}
 			a.WriteAVCC(ts, frame)
+		case codec.CodecID_OPUS:
+			a := track.NewOpus(p.Stream, pool, p)
+			p.AudioTrack = a
+			a.WriteAVCC(ts, frame)
+		case codec.CodecID_MP3:
+			a := track.NewMP3(p.Stream, pool, p)
+			p.AudioTrack = a
+			a.WriteAVCC(ts, frame)
 		default:
 			p.Stream.Error("audio codec not support yet", zap.Uint8("codecId", uint8(codecID)))
 		}
@@ -134,6 +248,11 @@ func (p *Publisher) WriteAVCCAudio(ts uint32, frame *util.BLL, pool util.BytesPo
 	}
 }
 
+// PublisherLost 发布者断开，用于区分当前发布者和热备发布者
+type PublisherLost struct {
+	IPublisher
+}
+
 type IPuller interface {
 	IPublisher
 	Connect() error
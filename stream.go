@@ -5,6 +5,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -94,7 +95,7 @@ func (l StreamList) Len() int {
 }
 
 func (l StreamList) Less(i, j int) bool {
-	return l[i].Path < l[j].Path
+	return l[i].GetPath() < l[j].GetPath()
 }
 
 func (l StreamList) Swap(i, j int) {
@@ -164,6 +165,18 @@ func (tracks *Tracks) MarshalJSON() ([]byte, error) {
 	}))
 }
 
+// atomicPath 流路径的副本，流被移动时在流的协程中更新，供其他协程读取
+type atomicPath struct {
+	atomic.Pointer[string]
+}
+
+func (p *atomicPath) String() string {
+	if v := p.Load(); v != nil {
+		return *v
+	}
+	return ""
+}
+
 // Stream 流定义
 type Stream struct {
 	timeout    *time.Timer //当前状态的超时定时器
@@ -171,14 +184,22 @@ type Stream struct {
 	*log.Logger
 	StartTime time.Time //创建时间
 	StreamTimeoutConfig
-	Path        string
+	Path        string `yaml:"-"` // 只在流的协程中读写，其他协程使用GetPath
+	path        atomicPath
 	Publisher   IPublisher
+	Standbys    []IPublisher // 热备发布者，按优先级从高到低排列
 	State       StreamState
 	SEHistory   []StateEvent // 事件历史
 	Subscribers Subscribers  // 订阅者
-	Tracks      Tracks
-	AppName     string
-	StreamName  string
+	// 轨道参数变化的历史，只保留最近的 maxParamsHistory 条
+	ParamsHistory []TrackParamsChanged
+	Tracks        Tracks
+	derived       map[string]*derivedTrack // 按订阅者需要转换出的音频轨道
+	AppName       string
+	StreamName    string
+	// 生效的发布配置，来自当前发布者，叠加了 streams 中匹配的配置；订阅配置因插件而异，见各订阅者的Config
+	PublishConfig *config.Publish
+	counter       streamCounter // 已经累加到准入控制统计中的数值
 }
 type StreamSummay struct {
 	Path        string
@@ -190,6 +211,30 @@ type StreamSummay struct {
 	BPS         int
 }
 
+// GetPath 可以在任意协程中调用，流被移动后返回新的路径
+func (s *Stream) GetPath() string {
+	if p := s.path.Load(); p != nil {
+		return *p
+	}
+	return s.Path
+}
+
+func (s *Stream) MarshalJSON() ([]byte, error) {
+	type stream Stream
+	return json.Marshal(&struct {
+		*stream
+		Path string
+	}{(*stream)(s), s.GetPath()})
+}
+
+func (s *Stream) MarshalYAML() (any, error) {
+	type stream Stream
+	return &struct {
+		*stream `yaml:",inline"`
+		Path    string
+	}{(*stream)(s), s.GetPath()}, nil
+}
+
 func (s *Stream) GetType() string {
 	if s.Publisher == nil {
 		return ""
@@ -201,7 +246,11 @@ func (s *Stream) GetStartTime() time.Time {
 	return s.StartTime
 }
 
+// GetPublisherConfig 没有发布者时返回全局配置
 func (s *Stream) GetPublisherConfig() *config.Publish {
+	if s.Publisher == nil {
+		return &EngineConfig.Publish
+	}
 	return s.Publisher.GetPublisher().Config
 }
 
@@ -215,7 +264,7 @@ func (s *Stream) Summary() (r StreamSummay) {
 		r.BPS += b.BPS
 		return name
 	})
-	r.Path = s.Path
+	r.Path = s.GetPath()
 	r.State = s.State
 	r.Subscribers = s.Subscribers.Len()
 	r.StartTime = s.StartTime
@@ -246,8 +295,10 @@ func findOrCreateStream(streamPath string, waitTimeout time.Duration) (s *Stream
 			StartTime:  time.Now(),
 			timeout:    time.NewTimer(waitTimeout),
 		}
+		s.path.Store(&streamPath)
 		s.Subscribers.Init()
-		s.Logger = log.LocaleLogger.With(zap.String("stream", streamPath))
+		// 流被移动后，轨道、发布者、订阅者等派生出的日志都使用新的路径
+		s.Logger = log.LocaleLogger.WithDynamic("stream", &s.path)
 		s.Info("created")
 		Streams.Map[streamPath] = s
 		s.actionChan.Init(1)
@@ -258,6 +309,9 @@ func findOrCreateStream(streamPath string, waitTimeout time.Duration) (s *Stream
 }
 
 func (r *Stream) action(action StreamAction) (ok bool) {
+	if action == ACTION_PUBLISHLOST && r.State != STATE_WAITPUBLISH && r.switchStandby() {
+		return true
+	}
 	var event StateEvent
 	event.Target = r
 	event.Action = action
@@ -293,9 +347,9 @@ func (r *Stream) action(action StreamAction) (ok bool) {
 			r.Debug("wait publisher", zap.Duration("wait timeout", waitTime))
 		case STATE_PUBLISHING:
 			if len(r.SEHistory) > 1 {
-				stateEvent = SErepublish{event}
+				stateEvent = SErepublish{event, r.Publisher}
 			} else {
-				stateEvent = SEpublish{event}
+				stateEvent = SEpublish{event, r.Publisher}
 			}
 			r.Subscribers.Broadcast(stateEvent)
 			if r.IdleTimeout > 0 && r.Subscribers.Len() == 0 {
@@ -319,6 +373,9 @@ func (r *Stream) action(action StreamAction) (ok bool) {
 			r.Subscribers.Broadcast(stateEvent)
 			Streams.Delete(r.Path)
 			r.timeout.Stop()
+			for _, standby := range r.Standbys {
+				standby.OnEvent(stateEvent)
+			}
 		}
 		EventBus <- stateEvent
 		if r.Publisher != nil {
@@ -330,6 +387,74 @@ func (r *Stream) action(action StreamAction) (ok bool) {
 	return
 }
 
+func (s *Stream) setTimeoutConfig(conf *config.Publish) {
+	s.PublishConfig = conf
+	s.PublishTimeout = conf.PublishTimeout
+	s.DelayCloseTimeout = conf.DelayCloseTimeout
+	s.IdleTimeout = conf.IdleTimeout
+}
+
+// addStandby 按优先级插入热备发布者，同优先级先来的在前
+func (s *Stream) addStandby(pub IPublisher) {
+	priority := pub.GetPublisher().Priority
+	i := sort.Search(len(s.Standbys), func(i int) bool {
+		return s.Standbys[i].GetPublisher().Priority < priority
+	})
+	s.Standbys = append(s.Standbys, nil)
+	copy(s.Standbys[i+1:], s.Standbys[i:])
+	s.Standbys[i] = pub
+	s.Info("standby +1", zap.String("type", pub.GetPublisher().Type), zap.Int("priority", priority), zap.Int("standbys", len(s.Standbys)))
+}
+
+func (s *Stream) removeStandby(pub IPublisher) bool {
+	for i, standby := range s.Standbys {
+		if standby == pub {
+			s.Standbys = append(s.Standbys[:i], s.Standbys[i+1:]...)
+			s.Info("standby -1", zap.String("type", pub.GetPublisher().Type), zap.Int("standbys", len(s.Standbys)))
+			return true
+		}
+	}
+	return false
+}
+
+// isStandbyTrack track是否由热备发布者创建，这类track不加入流中
+// 根据创建track时传入的发布者判断，Standby只在流的协程中修改，track在构造函数中Attach时发布者还没有保存track也能识别
+func (s *Stream) isStandbyTrack(t Track) bool {
+	owner := t.GetBase().Owner
+	return owner != nil && owner.IsStandby()
+}
+
+// switchStandby 发布者失效时切换到优先级最高的热备发布者，不经过等待发布状态，订阅者无感知
+func (s *Stream) switchStandby() bool {
+	for len(s.Standbys) > 0 {
+		next := s.Standbys[0]
+		s.Standbys = s.Standbys[1:]
+		if next.IsClosed() {
+			continue
+		}
+		old := s.Publisher
+		puber := next.GetPublisher()
+		puber.Standby = false
+		s.Publisher = next
+		s.setTimeoutConfig(puber.Config)
+		// 与发布者断线重连一样，track下线后再次写入时会计算时间戳差值，保持时间戳连续
+		s.Tracks.Range(func(name string, t Track) {
+			t.SetStuff(TrackStateOffline)
+		})
+		event := SwitchPublisherEvent{StreamEvent{CreateEvent(s)}, old, next}
+		if old != nil && !old.IsClosed() {
+			old.OnEvent(SEKick{})
+		}
+		// 由热备发布者在自己的协程中下一次写入之前接替，等待前任停止写入后再写入前任的track
+		next.OnEvent(event)
+		s.Warn("switch to standby", zap.String("type", puber.Type), zap.Int("priority", puber.Priority), zap.Int("standbys", len(s.Standbys)))
+		s.timeout.Reset(s.PublishTimeout)
+		EventBus <- event
+		return true
+	}
+	return false
+}
+
 func (r *Stream) IsShutdown() bool {
 	switch l := len(r.SEHistory); l {
 	case 0:
@@ -370,6 +495,7 @@ func (s *Stream) onSuberClose(sub ISubscriber) {
 	if s.Publisher != nil {
 		s.Publisher.OnEvent(sub) // 通知Publisher有订阅者离开，在回调中可以去获取订阅者数量
 	}
+	s.releaseDerived()
 	if (s.DelayCloseTimeout > 0 || s.IdleTimeout > 0) && s.Subscribers.Len() == 0 {
 		s.action(ACTION_LASTLEAVE)
 	}
@@ -389,6 +515,7 @@ func (s *Stream) run() {
 	var timeOutInfo zap.Field
 	var timeStart time.Time
 	for pulseSuber := make(map[ISubscriber]struct{}); ; s.checkRunCost(timeStart, timeOutInfo) {
+		s.syncCounter(false)
 		select {
 		case <-pulseTicker.C:
 			timeStart = time.Now()
@@ -445,7 +572,26 @@ func (s *Stream) run() {
 					if s.IsClosed() {
 						v.Reject(ErrStreamIsClosed)
 					}
+					if puber := v.Value.GetPublisher(); puber.Standby {
+						if s.Publisher != nil && !s.Publisher.IsClosed() {
+							s.addStandby(v.Value)
+							v.Resolve()
+							break
+						}
+						// 原发布者已经离开，直接成为发布者，与接替一样在下一次写入之前接管原发布者的track
+						puber.Standby = false
+						s.setTimeoutConfig(puber.Config)
+						if s.Publisher != nil {
+							v.Value.OnEvent(SwitchPublisherEvent{StreamEvent{CreateEvent(s)}, s.Publisher, v.Value})
+						}
+					}
 					republish := s.Publisher == v.Value // 重复发布
+					if !republish {
+						if err := s.admitPublisher(); err != nil {
+							v.Reject(err)
+							break
+						}
+					}
 					if !republish {
 						s.Publisher = v.Value
 					}
@@ -462,6 +608,13 @@ func (s *Stream) run() {
 					suber := v.Value
 					io := suber.GetSubscriber()
 					sbConfig := io.Config
+					if !sbConfig.Internal {
+						if err := s.admitSubscriber(); err != nil {
+							io.Warn("subscribe rejected", zap.Error(err))
+							v.Reject(err)
+							break
+						}
+					}
 					waits := &waitTracks{
 						Promise: v,
 					}
@@ -496,6 +649,7 @@ func (s *Stream) run() {
 						if !pubConfig.PubVideo || s.Subscribers.waitAborted {
 							waits.video.StopWait()
 						}
+						s.deriveAudio(waits)
 					}
 					s.Subscribers.Add(suber, waits)
 					if s.Subscribers.Len() == 1 && s.State == STATE_WAITCLOSE {
@@ -511,6 +665,8 @@ func (s *Stream) run() {
 					if t, ok := s.Tracks.Delete(name); ok {
 						s.Info("track -1", zap.String("name", name))
 						s.Subscribers.Broadcast(t)
+						s.stopDVR(t)
+						s.removeDerived(t)
 						if s.Tracks.Len() == 0 {
 							s.action(ACTION_PUBLISHLOST)
 						}
@@ -520,13 +676,23 @@ func (s *Stream) run() {
 					}
 				case *util.Promise[Track]:
 					timeOutInfo = zap.String("action", "Track")
+					if s.isStandbyTrack(v.Value) {
+						// 热备发布者的track只做缓存，接替时由新发布者写入原track，没有对应的原track时再重新Attach
+						v.Value.GetBase().Attached.Store(false)
+						v.Resolve()
+						break
+					}
 					if s.State == STATE_WAITPUBLISH {
 						s.action(ACTION_PUBLISH)
 					}
 					name := v.Value.GetBase().Name
 					if s.Tracks.Add(name, v.Value) {
 						v.Resolve()
+						s.startDVR(v.Value)
 						s.Subscribers.OnTrack(v.Value)
+						for wait := range s.Subscribers.waits {
+							s.deriveAudio(wait)
+						}
 						if _, ok := v.Value.(*track.Video); ok && !s.GetPublisherConfig().PubAudio {
 							s.Subscribers.AbortWait()
 						}
@@ -538,6 +704,29 @@ func (s *Stream) run() {
 					} else {
 						v.Reject(ErrBadTrackName)
 					}
+				case PublisherLost:
+					timeOutInfo = zap.String("action", "PublisherLost")
+					if s.removeStandby(v.IPublisher) {
+						// 热备发布者离开，不影响当前发布者
+					} else if v.IPublisher == s.Publisher {
+						s.action(ACTION_PUBLISHLOST)
+					} else {
+						s.Debug("ignore lost of replaced publisher")
+					}
+				case *util.Promise[StreamMove]:
+					timeOutInfo = zap.String("action", "Move")
+					if err := s.move(v.Value.Path); err != nil {
+						v.Reject(err)
+					} else {
+						v.Resolve()
+					}
+				case *util.Promise[*ioQuery]:
+					timeOutInfo = zap.String("action", "FindIO")
+					v.Value.Result = s.findIO(v.Value.ID)
+					v.Resolve()
+				case track.ParamsChanged:
+					timeOutInfo = zap.String("action", "ParamsChanged")
+					s.onParamsChanged(v)
 				case NoMoreTrack:
 					s.Subscribers.AbortWait()
 				case StreamAction:
@@ -549,17 +738,145 @@ func (s *Stream) run() {
 				}
 			} else {
 				s.Subscribers.Dispose()
+				s.disposeDerived()
 				s.Tracks.Range(func(_ string, t Track) {
+					s.stopDVR(t)
 					if dt, ok := t.(*track.Data); ok {
 						dt.Dispose()
 					}
 				})
+				s.syncCounter(true)
 				return
 			}
 		}
 	}
 }
 
+const maxParamsHistory = 32
+
+// onParamsChanged 轨道已经被移除的通知直接忽略
+func (s *Stream) onParamsChanged(v track.ParamsChanged) {
+	name := v.Track.GetBase().Name
+	if s.Tracks.Get(name) != v.Track {
+		return
+	}
+	event := TrackParamsChanged{StreamEvent{CreateEvent(s)}, v.Track, name, v.Old, v.New, v.Sequence}
+	if s.ParamsHistory = append(s.ParamsHistory, event); len(s.ParamsHistory) > maxParamsHistory {
+		s.ParamsHistory = s.ParamsHistory[1:]
+	}
+	s.Subscribers.Broadcast(event)
+	EventBus <- event
+}
+
+type StreamMove struct {
+	Path string
+}
+
+// Move 将流移动到新的流路径，发布者和订阅者继续运行，拉流和推流配置中的旧路径也会同步修改
+func (s *Stream) Move(streamPath string) error {
+	streamPath = strings.Trim(streamPath, "/")
+	if len(strings.Split(streamPath, "/")) < 2 {
+		return ErrBadStreamPath
+	}
+	if promise := util.NewPromise(StreamMove{streamPath}); s.Receive(promise) {
+		return promise.Await()
+	}
+	return ErrStreamIsClosed
+}
+
+// move 在流的协程中执行，重新设置流在Streams中的键和日志字段
+func (s *Stream) move(streamPath string) error {
+	oldPath := s.Path
+	if streamPath == oldPath {
+		return nil
+	}
+	Streams.Lock()
+	if _, ok := Streams.Map[streamPath]; ok {
+		Streams.Unlock()
+		return ErrBadStreamName
+	}
+	delete(Streams.Map, oldPath)
+	s.syncCounter(true) // 从原App的统计中减去，下次循环时累加到新App
+	p := strings.Split(streamPath, "/")
+	s.Path, s.AppName, s.StreamName = streamPath, p[0], strings.Join(p[1:], "/")
+	s.path.Store(&streamPath)
+	// 持有Streams锁时移动分段文件，避免原路径上新建的流开始回看时删除这些文件
+	s.moveDVR()
+	Streams.Map[streamPath] = s
+	Streams.Unlock()
+	s.Info("moved", zap.String("from", oldPath))
+	var ios []*IO
+	for _, standby := range s.Standbys {
+		ios = append(ios, &standby.GetPublisher().IO)
+	}
+	if s.Publisher != nil {
+		ios = append(ios, &s.Publisher.GetPublisher().IO)
+	}
+	s.Subscribers.RangeAll(func(sub ISubscriber) {
+		ios = append(ios, &sub.GetSubscriber().IO)
+	})
+	for _, io := range ios {
+		// 拉流者和推流者记录的流路径
+		if client, ok := io.Spesific.(interface{ moveStreamPath(string, string) }); ok {
+			client.moveStreamPath(oldPath, streamPath)
+		}
+	}
+	for _, plugin := range Plugins {
+		plugin.moveStreamPath(oldPath, streamPath)
+	}
+	event := SEmove{StreamEvent{Event[*Stream]{Target: s, Time: time.Now()}}, oldPath}
+	s.Subscribers.Broadcast(event)
+	if s.Publisher != nil {
+		s.Publisher.OnEvent(event)
+	}
+	EventBus <- event
+	return nil
+}
+
+type ioQuery struct {
+	ID     string
+	Result IIO
+}
+
+func (s *Stream) findIO(id string) (result IIO) {
+	if s.Publisher != nil && s.Publisher.GetPublisher().ID == id {
+		return s.Publisher
+	}
+	for _, standby := range s.Standbys {
+		if standby.GetPublisher().ID == id {
+			return standby
+		}
+	}
+	s.Subscribers.RangeAll(func(sub ISubscriber) {
+		if result == nil && sub.GetSubscriber().ID == id {
+			result = sub
+		}
+	})
+	return
+}
+
+// FindIO 根据ID查找流的发布者（包括热备发布者）或者订阅者
+func (s *Stream) FindIO(id string) IIO {
+	if promise := util.NewPromise(&ioQuery{ID: id}); s.Receive(promise) && promise.Await() == nil {
+		return promise.Value.Result
+	}
+	return nil
+}
+
+// Kick 停止流中指定ID的发布者或者订阅者，停止前通过KickEvent通知该发布者或订阅者以及EventBus
+func (s *Stream) Kick(id string, reason string) bool {
+	io := s.FindIO(id)
+	if io == nil {
+		return false
+	}
+	s.Info("kick", zap.String("ID", id), zap.String("reason", reason))
+	event := KickEvent{CreateEvent(io), reason}
+	io.OnEvent(event)
+	EventBus <- event
+	io.Stop()
+	return true
+}
+
 func (s *Stream) AddTrack(t Track) (promise *util.Promise[Track]) {
 	promise = util.NewPromise(t)
 	s.Receive(promise)
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+
+	"m7s.live/engine/v4/config"
+)
+
+// streamOverrides 返回匹配流路径的 streams 配置，AppName 规则在前，通配符规则按字符串顺序在后，后面的覆盖前面的
+func streamOverrides(streamPath string) (r []config.Config) {
+	if len(EngineConfig.Streams) == 0 {
+		return
+	}
+	streamPath, _, _ = strings.Cut(streamPath, "?")
+	streamPath = resolveStreamPath(strings.Trim(streamPath, "/"))
+	appName, _, _ := strings.Cut(streamPath, "/")
+	var patterns []string
+	for pattern := range EngineConfig.Streams {
+		if pattern == appName {
+			r = append(r, EngineConfig.Streams[pattern])
+		} else if strings.ContainsAny(pattern, "/*?[") && MatchStreamPath(pattern, streamPath) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	sort.Strings(patterns)
+	for _, pattern := range patterns {
+		r = append(r, EngineConfig.Streams[pattern])
+	}
+	return
+}
+
+// applyStreamOverrides 将匹配流路径的 streams 配置中 name（publish 或 subscribe）部分覆盖到 target 上
+func applyStreamOverrides(streamPath string, name string, target any) {
+	for _, c := range streamOverrides(streamPath) {
+		if child, ok := c[name].(config.Config); ok {
+			child.Unmarshal(target)
+		}
+	}
+}
+
+// StreamPublishConfig 返回流使用的发布配置，在 conf 的基础上叠加 streams 中匹配的配置
+func StreamPublishConfig(streamPath string, conf config.Publish) *config.Publish {
+	applyStreamOverrides(streamPath, "publish", &conf)
+	return &conf
+}
+
+// StreamSubscribeConfig 返回流使用的订阅配置，在 conf 的基础上叠加 streams 中匹配的配置
+func StreamSubscribeConfig(streamPath string, conf config.Subscribe) *config.Subscribe {
+	applyStreamOverrides(streamPath, "subscribe", &conf)
+	return &conf
+}
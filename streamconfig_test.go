@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"m7s.live/engine/v4/config"
+)
+
+func TestStreamConfigOverrides(t *testing.T) {
+	defer func(conf *GlobalConfig) { EngineConfig = conf }(EngineConfig)
+	EngineConfig = &GlobalConfig{}
+	config.Config{
+		"streams": config.Config{
+			"vod": config.Config{
+				"publish": config.Config{"delayclosetimeout": "10s"},
+			},
+			"live/vip*": config.Config{
+				"publish":   config.Config{"publishtimeout": "30s"},
+				"subscribe": config.Config{"submode": 2},
+			},
+		},
+	}.Unmarshal(&EngineConfig.Engine)
+	if _, ok := EngineConfig.Streams["vod"]["publish"].(config.Config); !ok {
+		t.Fatalf("streams = %+v", EngineConfig.Streams)
+	}
+	base := config.Publish{PublishTimeout: 10 * time.Second}
+	if c := StreamPublishConfig("vod/movie", base); c.DelayCloseTimeout != 10*time.Second || c.PublishTimeout != 10*time.Second {
+		t.Errorf("vod/movie = %+v", c)
+	}
+	if c := StreamPublishConfig("live/vip1", base); c.PublishTimeout != 30*time.Second || c.DelayCloseTimeout != 0 {
+		t.Errorf("live/vip1 = %+v", c)
+	}
+	if c := StreamSubscribeConfig("live/vip1", config.Subscribe{}); c.SubMode != 2 {
+		t.Errorf("live/vip1 subscribe = %+v", c)
+	}
+	if c := StreamSubscribeConfig("live/test", config.Subscribe{SubMode: 1}); c.SubMode != 1 {
+		t.Errorf("live/test subscribe = %+v", c)
+	}
+}
+
+// 元素为结构体的map仍然按元素类型解析
+func TestUnmarshalStructMap(t *testing.T) {
+	var conf struct {
+		Apps map[string]config.Publish
+	}
+	config.Config{
+		"apps": config.Config{"live": config.Config{"pubaudio": true, "buffertime": "2s"}},
+	}.Unmarshal(&conf)
+	if app := conf.Apps["live"]; !app.PubAudio || app.BufferTime != 2*time.Second {
+		t.Errorf("apps = %+v", conf.Apps)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"go.uber.org/zap"
@@ -47,6 +48,12 @@ type VideoFrame struct {
 	PTS     uint32
 	DTS     uint32
 }
+
+// TimeshiftInfo 回看订阅读到第一帧后发给订阅者，Actual为实际回看的时长，受缓存长度和关键帧位置影响
+type TimeshiftInfo struct {
+	Requested time.Duration
+	Actual    time.Duration
+}
 type FLVFrame net.Buffers
 type AudioRTP RTPFrame
 type VideoRTP RTPFrame
@@ -110,6 +117,17 @@ type TrackPlayer struct {
 	AudioReader, VideoReader *track.AVRingReader
 	Audio                    *track.Audio
 	Video                    *track.Video
+	playControl              atomic.Pointer[playControl] // Control在其他协程中读取
+}
+
+// readers 已经订阅的音视频轨道的读取器
+func (p *TrackPlayer) readers() (readers []*track.AVRingReader) {
+	for _, r := range []*track.AVRingReader{p.VideoReader, p.AudioReader} {
+		if r != nil {
+			readers = append(readers, r)
+		}
+	}
+	return
 }
 
 // Subscriber 订阅者实体定义
@@ -117,6 +135,9 @@ type Subscriber struct {
 	IO
 	Config      *config.Subscribe
 	TrackPlayer `json:"-" yaml:"-"`
+
+	VideoDrops *track.DropStats `json:",omitempty" yaml:"-"` // 视频读取落后时的丢帧统计
+	AudioDrops *track.DropStats `json:",omitempty" yaml:"-"` // 音频只会在落后超过环形缓冲一半时跳帧
 }
 
 func (s *Subscriber) Subscribe(streamPath string, sub ISubscriber) error {
@@ -155,12 +176,15 @@ func (s *Subscriber) AddTrack(t Track) bool {
 			return false
 		}
 		s.VideoReader = s.CreateTrackReader(&v.Media)
+		s.VideoReader.DropNonRef, s.VideoReader.DropNonIDR = s.Config.DropNonRef, s.Config.DropNonIDR
+		s.VideoDrops = &s.VideoReader.Drops
 		s.Video = v
 	case *track.Audio:
 		if s.AudioReader != nil || !s.Config.SubAudio {
 			return false
 		}
 		s.AudioReader = s.CreateTrackReader(&v.Media)
+		s.AudioDrops = &s.AudioReader.Drops
 		s.Audio = v
 	case *track.Data:
 	default:
@@ -190,6 +214,19 @@ func (s *Subscriber) PlayRTP() {
 	s.PlayBlock(SUBTYPE_RTP)
 }
 
+// PlayWebVTT 阻塞式读取caption数据轨道，把channel频道的字幕转换为WebVTT的cue，时间与视频的GetPTS32一致，为空时使用第一个收到的频道
+func (s *Subscriber) PlayWebVTT(dt *track.Data, channel string, onCue func(cue string) error) error {
+	vtt := track.NewWebVTT(s.VideoReader, channel)
+	return dt.Play(s.IO.Context, func(data any) error {
+		if e, ok := data.(*track.CaptionEvent); ok {
+			if cue := vtt.Cue(e); cue != "" {
+				return onCue(cue)
+			}
+		}
+		return nil
+	})
+}
+
 // PlayBlock 阻塞式读取数据
 func (s *Subscriber) PlayBlock(subType byte) {
 	spesic := s.Spesific
@@ -210,11 +247,11 @@ func (s *Subscriber) PlayBlock(subType byte) {
 	sendVideoDecConf := func() {
 		// s.Debug("sendVideoDecConf")
 		spesic.OnEvent(s.Video.ParamaterSets)
-		spesic.OnEvent(VideoDeConf(s.VideoReader.Track.SequenceHead))
+		spesic.OnEvent(VideoDeConf(s.VideoReader.SequenceHead()))
 	}
 	sendAudioDecConf := func() {
 		// s.Debug("sendAudioDecConf")
-		spesic.OnEvent(AudioDeConf(s.AudioReader.Track.SequenceHead))
+		spesic.OnEvent(AudioDeConf(s.AudioReader.SequenceHead()))
 	}
 	var sendAudioFrame, sendVideoFrame func(*AVFrame)
 	switch subType {
@@ -269,10 +306,10 @@ func (s *Subscriber) PlayBlock(subType byte) {
 			spesic.OnEvent(append(result, util.PutBE(flvHeadCache[11:15], dataSize+11)))
 		}
 		sendVideoDecConf = func() {
-			sendFlvFrame(codec.FLV_TAG_TYPE_VIDEO, s.VideoReader.AbsTime, s.VideoReader.Track.SequenceHead)
+			sendFlvFrame(codec.FLV_TAG_TYPE_VIDEO, s.VideoReader.AbsTime, s.VideoReader.SequenceHead())
 		}
 		sendAudioDecConf = func() {
-			sendFlvFrame(codec.FLV_TAG_TYPE_AUDIO, s.AudioReader.AbsTime, s.AudioReader.Track.SequenceHead)
+			sendFlvFrame(codec.FLV_TAG_TYPE_AUDIO, s.AudioReader.AbsTime, s.AudioReader.SequenceHead())
 		}
 		sendVideoFrame = func(frame *AVFrame) {
 			// fmt.Println(frame.Sequence, s.VideoReader.AbsTime, s.VideoReader.Delay, frame.IFrame)
@@ -298,26 +335,48 @@ func (s *Subscriber) PlayBlock(subType byte) {
 	if s.Args.Has(conf.SubModeArgName) {
 		subMode, _ = strconv.Atoi(s.Args.Get(conf.SubModeArgName))
 	}
+	timeshift := s.timeshift(subType != SUBTYPE_RTP)
+	ctl := s.newPlayControl(subType == SUBTYPE_RTP, hasVideo)
+	s.playControl.Store(ctl)
+	reportTimeshift := func(r *track.AVRingReader) {
+		if timeshift > 0 {
+			s.Info("timeshift", zap.Duration("requested", timeshift), zap.Duration("actual", r.Offset))
+			spesic.OnEvent(TimeshiftInfo{timeshift, r.Offset})
+			timeshift = 0
+		}
+	}
+	// 倍速较大时只发送关键帧，倍速播放时控制发送速度
+	playVideoFrame := func(frame *AVFrame) {
+		if !conf.IFrameOnly && !ctl.iframeOnly(s) || frame.IFrame {
+			ctl.pace(ctx, s.VideoReader.AbsTime)
+			sendVideoFrame(frame)
+		}
+	}
 	var initState = 0
 	var videoFrame, audioFrame *AVFrame
 	for ctx.Err() == nil {
 		if hasVideo {
 			for ctx.Err() == nil {
+				if ctl.handle(ctx, s) {
+					// 跳转后与开始播放时相同，重新对齐音视频
+					videoFrame, audioFrame, initState = nil, nil, 0
+				}
 				s.VideoReader.Read(ctx, subMode)
 				videoFrame = s.VideoReader.Frame
 				if videoFrame == nil || ctx.Err() != nil {
 					return
 				}
+				reportTimeshift(s.VideoReader)
 				// fmt.Println("video", s.VideoReader.Track.PreFrame().Sequence-frame.Sequence)
 				if videoFrame.IFrame && s.VideoReader.DecConfChanged() {
-					s.VideoReader.ConfSeq = s.VideoReader.Track.SequenceHeadSeq
+					s.VideoReader.ConfSeq = s.VideoReader.SequenceHeadSeq()
 					sendVideoDecConf()
 				}
 				if hasAudio {
 					if audioFrame != nil {
 						if videoFrame.Timestamp > audioFrame.Timestamp {
 							// fmt.Println("switch audio", audioFrame.CanRead)
-							if audioFrame.CanRead {
+							if audioFrame.CanRead && ctl.speed == 1 {
 								sendAudioFrame(audioFrame)
 							}
 							audioFrame = nil
@@ -328,16 +387,18 @@ func (s *Subscriber) PlayBlock(subType byte) {
 					}
 				}
 
-				if !conf.IFrameOnly || videoFrame.IFrame {
-					sendVideoFrame(videoFrame)
-				} else {
-					// fmt.Println("skip video", frame.Sequence)
-				}
+				playVideoFrame(videoFrame)
 			}
 		}
 		// 正常模式下或者纯音频模式下，音频开始播放
 		if hasAudio {
 			for ctx.Err() == nil {
+				if ctl.handle(ctx, s) {
+					videoFrame, audioFrame, initState = nil, nil, 0
+					if hasVideo {
+						break
+					}
+				}
 				switch s.AudioReader.State {
 				case track.READSTATE_INIT:
 					if s.Video != nil {
@@ -347,6 +408,7 @@ func (s *Subscriber) PlayBlock(subType byte) {
 				case track.READSTATE_NORMAL:
 					if s.Video != nil {
 						s.AudioReader.SkipTs = s.VideoReader.SkipTs
+						s.AudioReader.Speed = s.VideoReader.Speed
 					}
 				}
 				s.AudioReader.Read(ctx, subMode)
@@ -354,22 +416,24 @@ func (s *Subscriber) PlayBlock(subType byte) {
 				if audioFrame == nil || ctx.Err() != nil {
 					return
 				}
+				reportTimeshift(s.AudioReader)
 				// fmt.Println("audio", s.AudioReader.Track.PreFrame().Sequence-frame.Sequence)
 				if s.AudioReader.DecConfChanged() {
-					s.AudioReader.ConfSeq = s.AudioReader.Track.SequenceHeadSeq
+					s.AudioReader.ConfSeq = s.AudioReader.SequenceHeadSeq()
 					sendAudioDecConf()
 				}
 				if hasVideo && videoFrame != nil {
 					if audioFrame.Timestamp > videoFrame.Timestamp {
 						// fmt.Println("switch video", videoFrame.CanRead)
 						if videoFrame.CanRead {
-							sendVideoFrame(videoFrame)
+							playVideoFrame(videoFrame)
 						}
 						videoFrame = nil
 						break
 					}
 				}
-				if audioFrame.Timestamp >= s.AudioReader.SkipTs {
+				// 倍速播放时不发送音频，跳转后丢弃早于视频起始帧的音频
+				if ctl.speed == 1 && audioFrame.Timestamp >= s.AudioReader.SkipTs && audioFrame.Timestamp >= s.AudioReader.FirstTs {
 					sendAudioFrame(audioFrame)
 				} else {
 					// fmt.Println("skip audio", frame.AbsTime, s.AudioReader.SkipTs)
@@ -379,7 +443,54 @@ func (s *Subscriber) PlayBlock(subType byte) {
 	}
 }
 
+// timeshift 解析回看参数，设置读取器并让轨道缓存足够的时长，返回回看时长，没有回看时返回0
+// 磁盘中的帧没有RTP格式，useDVR为false时只能在内存缓冲中回看
+func (s *Subscriber) timeshift(useDVR bool) (d time.Duration) {
+	conf := s.Config
+	arg := s.Args.Get(conf.TimeshiftArgName)
+	limit := conf.MaxTimeshift
+	for _, r := range s.readers() {
+		// 之后跳转播放时同样按此决定是否从磁盘读取
+		r.UseDVR = useDVR
+		if dvr := r.Track.GetDVR(); useDVR && dvr != nil && dvr.Window() > limit {
+			limit = dvr.Window()
+		}
+	}
+	if arg == "" || limit <= 0 {
+		return
+	}
+	d, err := time.ParseDuration(arg)
+	if err != nil {
+		s.Warn("invalid timeshift", zap.String("timeshift", arg), zap.Error(err))
+		return 0
+	}
+	// -30s 和 30s 都代表回看30秒
+	if d < 0 {
+		d = -d
+	}
+	if d > limit {
+		d = limit
+	}
+	// 内存缓冲最多增长到MaxTimeshift，更早的部分从磁盘读取
+	buffer := d
+	if buffer > conf.MaxTimeshift {
+		buffer = conf.MaxTimeshift
+	}
+	if s.VideoReader != nil {
+		s.Video.SetTimeshift(buffer)
+		s.VideoReader.Timeshift = d
+	}
+	if s.AudioReader != nil {
+		s.Audio.SetTimeshift(buffer)
+		s.AudioReader.Timeshift = d
+	}
+	return
+}
+
 func (s *Subscriber) onStop() {
+	for _, r := range s.readers() {
+		r.CloseDVR()
+	}
 	if !s.Stream.IsClosed() {
 		s.Info("stop")
 		if !s.Config.Internal {
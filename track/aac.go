@@ -1,9 +1,13 @@
 package track
 
 import (
+	"bytes"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aler9/gortsplib/v2/pkg/bits"
@@ -25,12 +29,12 @@ func NewAAC(stream IStream, stuff ...any) (aac *AAC) {
 	aac.CodecID = codec.CodecID_AAC
 	aac.Channels = 2
 	aac.SampleSize = 16
+	aac.AVCCHead = []byte{0xAF, 1}
 	aac.SetStuff("aac", stream, int(256+128), byte(97), aac, time.Millisecond*10)
 	aac.SetStuff(stuff...)
 	if aac.BytesPool == nil {
 		aac.BytesPool = make(util.BytesPool, 17)
 	}
-	aac.AVCCHead = []byte{0xAF, 1}
 	return
 }
 
@@ -39,6 +43,60 @@ type AAC struct {
 
 	Mode      int       // 1为lbr，2为hbr
 	fragments *util.BLL // 用于处理不完整的AU,缺少的字节数
+	LATM      bool      // RTP负载格式为MP4A-LATM（RFC 3016），否则为mpeg4-generic（RFC 3640）
+	CPresent  bool      // MP4A-LATM中StreamMuxConfig是否在每个AudioMuxElement中
+	MuxConfig *codec.StreamMuxConfig
+	latm      util.Buffer // 被分片的AudioMuxElement
+}
+
+// AACSDP SDP中rtpmap的编码名称和fmtp的参数，通过SetStuff传入来选择RTP负载格式
+type AACSDP struct {
+	EncodingName string            // MP4A-LATM 或 mpeg4-generic
+	Fmtp         map[string]string // 例如 config、cpresent、sizelength、indexlength、indexdeltalength
+}
+
+func (aac *AAC) SetStuff(stuff ...any) {
+	for _, s := range stuff {
+		if sdp, ok := s.(AACSDP); ok {
+			aac.setSDP(sdp)
+		} else {
+			aac.Audio.SetStuff(s)
+		}
+	}
+}
+
+func (aac *AAC) setSDP(sdp AACSDP) {
+	aac.LATM = strings.EqualFold(sdp.EncodingName, "MP4A-LATM")
+	for k, v := range sdp.Fmtp {
+		switch strings.ToLower(k) {
+		case "cpresent":
+			aac.CPresent = v != "0"
+		case "sizelength":
+			aac.SizeLength, _ = strconv.Atoi(v)
+		case "indexlength":
+			aac.IndexLength, _ = strconv.Atoi(v)
+		case "indexdeltalength":
+			aac.IndexDeltaLength, _ = strconv.Atoi(v)
+		}
+	}
+	// cpresent 默认为1
+	if _, ok := sdp.Fmtp["cpresent"]; !ok && aac.LATM {
+		aac.CPresent = true
+	}
+	config, err := hex.DecodeString(sdp.Fmtp["config"])
+	if err != nil || len(config) == 0 {
+		return
+	}
+	if aac.LATM {
+		if aac.MuxConfig, err = codec.ParseStreamMuxConfig(config); err != nil {
+			aac.Error("parse StreamMuxConfig failed", zap.Error(err))
+			return
+		}
+		config = aac.MuxConfig.AudioSpecificConfig
+	}
+	if len(config) >= 2 {
+		aac.WriteSequenceHead(append([]byte{0xAF, 0x00}, config...))
+	}
 }
 
 func (aac *AAC) WriteADTS(ts uint32, adts []byte) {
@@ -68,8 +126,46 @@ func (aac *AAC) WriteADTS(ts uint32, adts []byte) {
 	aac.Flush()
 }
 
+// writeLATM MP4A-LATM 解包，一个RTP包中可以有多个AudioMuxElement，一个AudioMuxElement也可以分片到多个RTP包中 https://datatracker.ietf.org/doc/html/rfc3016#section-4
+func (aac *AAC) writeLATM(frame *RTPFrame) {
+	aac.latm = append(aac.latm, frame.Payload...)
+	if !frame.Marker {
+		return
+	}
+	data := aac.latm
+	aac.latm = nil
+	for len(data) > 0 {
+		el, err := codec.ParseAudioMuxElement(data, aac.CPresent, aac.MuxConfig)
+		if err != nil {
+			aac.Error("parse AudioMuxElement failed", zap.Error(err), zap.Uint16("seq", frame.SequenceNumber))
+			break
+		}
+		if el.Config != nil {
+			aac.MuxConfig = el.Config
+			if sh := append([]byte{0xAF, 0x00}, el.Config.AudioSpecificConfig...); !bytes.Equal(sh, aac.SequenceHead) {
+				aac.WriteSequenceHead(sh)
+			}
+		}
+		for _, payload := range el.Payloads {
+			aac.AppendAuBytes(payload)
+		}
+		data = data[el.Size:]
+	}
+	// 收到StreamMuxConfig之前无法确定采样率
+	if aac.Value.AUList.Length > 0 && aac.SampleRate > 0 {
+		aac.generateTimestamp(uint32(uint64(frame.Timestamp) * 90000 / uint64(aac.SampleRate)))
+		aac.Flush()
+	} else {
+		aac.Value.AUList.Recycle()
+	}
+}
+
 // https://datatracker.ietf.org/doc/html/rfc3640#section-3.2.1
 func (aac *AAC) WriteRTPFrame(frame *RTPFrame) {
+	if aac.LATM {
+		aac.writeLATM(frame)
+		return
+	}
 	if len(frame.Payload) < 2 {
 		// aac.fragments = aac.fragments[:0]
 		return
@@ -191,7 +287,30 @@ func (aac *AAC) WriteAVCC(ts uint32, frame *util.BLL) error {
 	return nil
 }
 
+// LATMConfig 输出MP4A-LATM时SDP中config参数的值，cpresent为0
+func (aac *AAC) LATMConfig() string {
+	if len(aac.SequenceHead) < 4 {
+		return ""
+	}
+	config, err := codec.NewStreamMuxConfig(aac.SequenceHead[2:]).Bytes()
+	if err != nil {
+		aac.Error("build StreamMuxConfig failed", zap.Error(err))
+	}
+	return hex.EncodeToString(config)
+}
+
 func (aac *AAC) CompleteRTP(value *AVFrame) {
+	if aac.LATM {
+		// 每个AU为一个不带StreamMuxConfig的AudioMuxElement，超过MTU时分片
+		var buffers net.Buffers
+		value.AUList.Range(func(au *util.BLL) bool {
+			buffers = append(buffers, codec.LATMPayloadLengthInfo(au.ByteLength))
+			buffers = append(buffers, au.ToBuffers()...)
+			return true
+		})
+		aac.PacketizeRTP(util.SplitBuffers(buffers, RTPMTU)...)
+		return
+	}
 	l := value.AUList.ByteLength
 	//AU_HEADER_LENGTH,因为单位是bit, 除以8就是auHeader的字节长度；又因为单个auheader字节长度2字节，所以再除以2就是auheader的个数。
 	auHeaderLen := []byte{0x00, 0x10, (byte)((l & 0x1fe0) >> 5), (byte)((l & 0x1f) << 3)} // 3 = 16-13, 5 = 8-3
@@ -0,0 +1,160 @@
+package track
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/util"
+)
+
+var _ SpesificTrack = (*AC3)(nil)
+
+func NewAC3(stream IStream, eac3 bool, stuff ...any) (ac3 *AC3) {
+	ac3 = &AC3{}
+	if eac3 {
+		ac3.Name = "eac3"
+		ac3.CodecID = codec.CodecID_EAC3
+	} else {
+		ac3.Name = "ac3"
+		ac3.CodecID = codec.CodecID_AC3
+	}
+	ac3.SampleSize = 16
+	ac3.Channels = 2
+	ac3.AVCCHead = []byte{(byte(ac3.CodecID) << 4) | 0x0F}
+	ac3.SetStuff(stream, int(256), byte(97), uint32(48000), ac3, time.Millisecond*10)
+	ac3.SetStuff(stuff...)
+	if ac3.BytesPool == nil {
+		ac3.BytesPool = make(util.BytesPool, 17)
+	}
+	return
+}
+
+// AC3 AC-3 和 E-AC-3，每个同步帧为一个AVFrame
+type AC3 struct {
+	Audio
+	Header   codec.AC3Header // 最近一帧的帧头
+	fragment util.Buffer     // RTP中被分片的帧
+}
+
+// setHeader 根据帧头更新采样率和声道，第一次解析到帧头时Attach
+func (ac3 *AC3) setHeader(h codec.AC3Header) {
+	ac3.Header = h
+	if ac3.Attached.Load() && uint32(h.SampleRate) == ac3.SampleRate && h.Channels == ac3.Channels {
+		return
+	}
+	ac3.SampleRate = uint32(h.SampleRate)
+	ac3.Channels = h.Channels
+	ac3.Attach()
+}
+
+// writeFrames 写入连续的若干同步帧，pts依次递增
+func (ac3 *AC3) writeFrames(pts uint32, raw []byte) {
+	for len(raw) >= 8 {
+		h, err := codec.ParseAC3Header(raw)
+		if err != nil {
+			ac3.Warn("ac3 syncframe header error", zap.Error(err), zap.Int("len", len(raw)))
+			return
+		}
+		l := h.FrameLength
+		if l > len(raw) {
+			l = len(raw)
+		}
+		ac3.setHeader(h)
+		ac3.AppendAuBytes(raw[:l])
+		ac3.generateTimestamp(pts)
+		ac3.Flush()
+		pts += uint32(h.Samples * 90000 / h.SampleRate)
+		raw = raw[l:]
+	}
+}
+
+// WriteRaw 写入一个或多个完整的同步帧，例如TS中的一个PES
+func (ac3 *AC3) WriteRaw(pts uint32, raw []byte) {
+	ac3.Value.BytesIn += len(raw)
+	ac3.writeFrames(pts, raw)
+}
+
+// WriteAVCC FLV中没有定义AC-3，这里与G711一样使用一个字节的头
+func (ac3 *AC3) WriteAVCC(ts uint32, frame *util.BLL) error {
+	if l := frame.ByteLength; l < 9 {
+		ac3.Error("AVCC data too short", zap.Int("len", l))
+		return io.ErrShortWrite
+	}
+	head := make([]byte, 8)
+	for i := range head {
+		head[i] = frame.GetByte(i + 1)
+	}
+	h, err := codec.ParseAC3Header(head)
+	if err != nil {
+		ac3.Error("ac3 syncframe header error", zap.Error(err))
+		frame.Recycle()
+		return err
+	}
+	ac3.setHeader(h)
+	au := frame.ToBuffers()
+	au[0] = au[0][1:]
+	ac3.AppendAuBytes(au...)
+	ac3.Audio.WriteAVCC(ts, frame)
+	return nil
+}
+
+// isInitialFragment AC-3中FT为1或2表示第一个分片，E-AC-3中只有1
+func (ac3 *AC3) isInitialFragment(ft byte) bool {
+	return ft == 1 || (ft == 2 && ac3.CodecID == codec.CodecID_AC3)
+}
+
+// WriteRTPFrame AC-3 RTP 解包 https://datatracker.ietf.org/doc/html/rfc4184#section-4.1.1
+// E-AC-3 https://datatracker.ietf.org/doc/html/rfc4598#section-4.1.1
+func (ac3 *AC3) WriteRTPFrame(frame *RTPFrame) {
+	if len(frame.Payload) < 3 {
+		ac3.Warn("ac3 rtp payload too short", zap.Uint16("seq", frame.SequenceNumber))
+		return
+	}
+	// MBZ|FT NF
+	ft, payload := frame.Payload[0]&0b11, frame.Payload[2:]
+	pts := uint32(uint64(frame.Timestamp) * 90000 / uint64(ac3.SampleRate))
+	switch {
+	case ft == 0:
+		ac3.fragment = nil
+		ac3.writeFrames(pts, payload)
+		return
+	case ac3.isInitialFragment(ft):
+		ac3.fragment = append(util.Buffer(nil), payload...)
+	case ac3.fragment != nil:
+		ac3.fragment = append(ac3.fragment, payload...)
+	default:
+		// 第一个分片丢失
+		return
+	}
+	if frame.Marker {
+		ac3.writeFrames(pts, ac3.fragment)
+		ac3.fragment = nil
+	}
+}
+
+// CompleteRTP 两个字节的头，超过MTU的帧分片
+func (ac3 *AC3) CompleteRTP(value *AVFrame) {
+	l := value.AUList.ByteLength
+	chunks := util.SplitBuffers(value.AUList.ToBuffers(), RTPMTU-2)
+	var ft byte
+	if len(chunks) > 1 {
+		// AC-3 第一个分片不足帧的5/8时FT为2
+		if ft = 1; ac3.CodecID == codec.CodecID_AC3 && (RTPMTU-2)*8 < l*5 {
+			ft = 2
+		}
+	}
+	var packets [][][]byte
+	for i, chunk := range chunks {
+		if i == 1 {
+			if ft = 2; ac3.CodecID == codec.CodecID_AC3 {
+				ft = 3
+			}
+		}
+		packets = append(packets, append(net.Buffers{{ft, byte(len(chunks))}}, chunk...))
+	}
+	ac3.PacketizeRTP(packets...)
+}
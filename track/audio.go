@@ -17,6 +17,21 @@ type Audio struct {
 	IndexDeltaLength int
 	AVCCHead         []byte // 音频包在AVCC格式中，AAC会有两个字节，其他的只有一个字节
 	codec.AudioSpecificConfig
+	params paramsWatcher // 序列头变化时比较解码参数
+	Codecs string        // RFC 6381 编码字符串，序列化json时更新
+}
+
+// flvSoundRate FLV 中的采样率只有4种，取不超过sampleRate的一种
+func flvSoundRate(sampleRate int) byte {
+	switch {
+	case sampleRate < 11025:
+		return 0
+	case sampleRate < 22050:
+		return 1
+	case sampleRate < 44100:
+		return 2
+	}
+	return 3
 }
 
 func (a *Audio) Attach() {
@@ -35,6 +50,16 @@ func (a *Audio) Detach() {
 	}
 }
 
+// CodecString RFC 6381 编码字符串，例如mp4a.40.2
+func (a *Audio) CodecString() string {
+	return codec.AudioCodecString(a.CodecID, a.AudioObjectType)
+}
+
+func (a *Audio) SnapForJson() {
+	a.Codecs = a.CodecString()
+	a.Media.SnapForJson()
+}
+
 func (a *Audio) GetName() string {
 	if a.Name == "" {
 		return a.CodecID.String()
@@ -52,6 +77,7 @@ func (av *Audio) Flush() {
 		av.ToADTS(av.Value.AUList.ByteLength, item.Value)
 		av.Value.ADTS = item
 	}
+	av.checkParams()
 	av.Media.Flush()
 }
 
@@ -0,0 +1,351 @@
+package track
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/util"
+)
+
+var _ SpesificTrack = (*AV1)(nil)
+
+// AV1 AUList中每个AU为一个不带长度字段的OBU，时间单元分隔符和填充OBU会被丢弃
+type AV1 struct {
+	Video
+	SeqHeader codec.AV1SequenceHeader
+	seqOBU    []byte      // 不带长度字段的序列头OBU
+	fragment  net.Buffers // RTP中跨包的OBU分片
+}
+
+func NewAV1(stream IStream, stuff ...any) (vt *AV1) {
+	vt = &AV1{}
+	vt.Video.CodecID = codec.CodecID_AV1
+	vt.SetStuff("av1", int(256), byte(96), uint32(90000), stream, vt, time.Millisecond*10)
+	vt.SetStuff(stuff...)
+	if vt.BytesPool == nil {
+		vt.BytesPool = make(util.BytesPool, 17)
+	}
+	vt.dtsEst = NewDTSEstimator()
+	return
+}
+
+// WriteSliceBytes 写入一个或多个OBU（低开销格式），最后一个OBU可以不带长度字段
+func (vt *AV1) WriteSliceBytes(slice []byte) {
+	for len(slice) > 0 {
+		header, payload, n, err := codec.ParseAV1OBU(slice)
+		if err != nil {
+			vt.Warn("av1 obu parse error", zap.Int("len", len(slice)))
+			return
+		}
+		vt.writeOBU(header, net.Buffers{payload})
+		slice = slice[n:]
+	}
+}
+
+func (vt *AV1) writeOBU(header []byte, payload net.Buffers) {
+	rv := &vt.Value
+	if rv.AUList.Length == 0 {
+		// 新的时间单元
+		rv.IFrame = false
+	}
+	switch codec.ParseAV1OBUType(header[0]) {
+	case codec.AV1_OBU_TEMPORAL_DELIMITER, codec.AV1_OBU_TILE_LIST, codec.AV1_OBU_PADDING:
+		return
+	case codec.AV1_OBU_SEQUENCE_HEADER:
+		rv.IFrame = true
+		obu := util.ConcatBuffers(append(net.Buffers{header}, payload...))
+		obu[0] &^= codec.AV1_OBU_HAS_SIZE_FIELD
+		if !bytes.Equal(obu, vt.seqOBU) {
+			seq, err := codec.ParseAV1SequenceHeader(obu[len(header):])
+			if err != nil {
+				vt.Error("AV1 ParseSequenceHeader", zap.Error(err))
+				return
+			}
+			vt.setSequenceHeader(&seq, obu)
+			vt.WriteSequenceHead(append([]byte{0x90 | codec.PacketTypeSequenceStart, 'a', 'v', '0', '1'}, codec.BuildAV1C(&seq, obu)...))
+		}
+	case codec.AV1_OBU_FRAME_HEADER, codec.AV1_OBU_FRAME:
+		if len(payload) > 0 && len(payload[0]) > 0 && codec.IsAV1KeyFrame(&vt.SeqHeader, payload[0][0]) {
+			rv.IFrame = true
+		}
+	}
+	var au util.BLL
+	mem := vt.BytesPool.Get(len(header))
+	copy(mem.Value, header)
+	mem.Value[0] &^= codec.AV1_OBU_HAS_SIZE_FIELD
+	au.Push(mem)
+	for _, p := range payload {
+		if len(p) > 0 {
+			au.Push(vt.BytesPool.GetShell(p))
+		}
+	}
+	rv.AUList.PushValue(&au)
+}
+
+func (vt *AV1) setSequenceHeader(seq *codec.AV1SequenceHeader, obu []byte) {
+	if seq.Width != vt.SeqHeader.Width || seq.Height != vt.SeqHeader.Height {
+		vt.Debug("AV1 sequence header", zap.Any("seq", seq))
+	}
+	vt.SeqHeader = *seq
+	vt.seqOBU = obu
+	vt.SPSInfo = codec.SPSInfo{
+		ProfileIdc: uint(seq.SeqProfile),
+		LevelIdc:   uint(seq.SeqLevelIdx0),
+		Width:      seq.Width,
+		Height:     seq.Height,
+		TierFlag:   uint(seq.SeqTier0),
+		// AV1中亮度和色度的位深相同
+		BitDepthLuma:   uint(seq.BitDepth),
+		BitDepthChroma: uint(seq.BitDepth),
+	}
+}
+
+// writeSequenceHead head为扩展头格式的视频Tag：1字节头+av01+av1C
+func (vt *AV1) writeSequenceHead(head []byte) (err error) {
+	if len(head) < 9 {
+		return io.ErrShortBuffer
+	}
+	seq, obu, err := codec.ParseAV1C(head[5:])
+	if err != nil {
+		vt.Error("AV1 ParseAV1C", zap.Error(err))
+		return
+	}
+	vt.WriteSequenceHead(head)
+	if obu == nil {
+		vt.SeqHeader = seq
+		return
+	}
+	header, payload, _, _ := codec.ParseAV1OBU(obu)
+	obu = append(append([]byte{header[0] &^ codec.AV1_OBU_HAS_SIZE_FIELD}, header[1:]...), payload...)
+	vt.setSequenceHeader(&seq, obu)
+	return
+}
+
+// WriteAVCC 写入 Enhanced RTMP 格式的AV1数据，av01没有CTS字段
+func (vt *AV1) WriteAVCC(ts uint32, frame *util.BLL) (err error) {
+	if l := frame.ByteLength; l < 6 {
+		vt.Error("AVCC data too short", zap.Int("len", l))
+		return io.ErrShortWrite
+	}
+	b0 := frame.GetByte(0)
+	if isExtHeader := b0 & 0b1000_0000; isExtHeader == 0 {
+		vt.Error("AV1 needs enhanced rtmp header")
+		frame.Recycle()
+		return codec.ErrAV1
+	}
+	switch packetType := b0 & 0b1111; packetType {
+	case codec.PacketTypeSequenceStart:
+		err = vt.writeSequenceHead(frame.ToBytes())
+		frame.Recycle()
+		return
+	case codec.PacketTypeCodedFrames, codec.PacketTypeCodedFramesX:
+		r := frame.NewReader()
+		r.Skip(5)
+		if err = vt.readOBUs(r, frame.ByteLength-5); err != nil {
+			vt.Error("AV1 read obu error", zap.Error(err))
+			frame.Recycle()
+			vt.Value.Reset()
+			return
+		}
+		if frameType := (b0 >> 4) & 0b0111; frameType == 1 {
+			vt.Value.IFrame = true
+		}
+		vt.Value.PTS = time.Duration(ts) * 90
+		vt.Value.DTS = vt.Value.PTS
+		vt.Value.WriteAVCC(ts, frame)
+		vt.Flush()
+	default:
+		frame.Recycle()
+	}
+	return
+}
+
+// readOBUs 读取低开销格式的OBU序列，remain为剩余字节数
+func (vt *AV1) readOBUs(r *util.BLLReader, remain int) error {
+	var hb [2]byte
+	for remain > 0 {
+		b0, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		header := hb[:codec.AV1OBUHeaderSize(b0)]
+		header[0] = b0
+		if len(header) == 2 {
+			if header[1], err = r.ReadByte(); err != nil {
+				return err
+			}
+		}
+		remain -= len(header)
+		size := remain
+		if b0&codec.AV1_OBU_HAS_SIZE_FIELD != 0 {
+			var v uint64
+			for i := 0; ; i++ {
+				b, err := r.ReadByte()
+				if err != nil || i == 8 {
+					return codec.ErrAV1
+				}
+				remain--
+				v |= uint64(b&0x7f) << (i * 7)
+				if b&0x80 == 0 {
+					break
+				}
+			}
+			if v > uint64(remain) {
+				return codec.ErrAV1
+			}
+			size = int(v)
+		}
+		vt.writeOBU(header, r.ReadN(size))
+		remain -= size
+	}
+	return nil
+}
+
+// WriteRTPFrame AV1 RTP 解包 https://aomediacodec.github.io/av1-rtp-spec/#44-av1-aggregation-header
+func (vt *AV1) WriteRTPFrame(frame *RTPFrame) {
+	if len(frame.Payload) > 1 {
+		// Z|Y|W W|N|- - -
+		agg := frame.Payload[0]
+		z, y, w := util.Bit1(agg, 0), util.Bit1(agg, 1), int(agg>>4)&0b11
+		if !z && vt.fragment != nil {
+			// 丢失了分片的结尾
+			vt.fragment = nil
+		}
+		buffer := util.Buffer(frame.Payload[1:])
+		for i := 1; buffer.CanRead(); i++ {
+			var element []byte
+			if w == 0 || i < w {
+				size, n, err := codec.ReadLEB128(buffer)
+				if err != nil || !buffer.CanReadN(n+int(size)) {
+					vt.Warn("av1 rtp element length error", zap.Uint16("seq", frame.SequenceNumber))
+					vt.fragment = nil
+					break
+				}
+				buffer.ReadN(n)
+				element = buffer.ReadN(int(size))
+			} else {
+				element = buffer.ReadN(buffer.Len())
+			}
+			first, last := i == 1, !buffer.CanRead()
+			switch {
+			case first && z:
+				if vt.fragment == nil {
+					// 分片的开头丢失了，丢弃剩余分片
+					continue
+				}
+				vt.fragment = append(vt.fragment, element)
+				if !(last && y) {
+					vt.writeFragment()
+				}
+			case last && y:
+				vt.fragment = net.Buffers{element}
+			default:
+				vt.WriteSliceBytes(element)
+			}
+		}
+	}
+	if frame.Marker {
+		vt.fragment = nil
+		vt.generateTimestamp(frame.Timestamp)
+		vt.Flush()
+	}
+}
+
+func (vt *AV1) writeFragment() {
+	fragment := vt.fragment
+	vt.fragment = nil
+	header, _, n, err := codec.ParseAV1OBUHeader(fragment[0])
+	if err != nil {
+		// 第一个分片太短，合并后再解析
+		vt.WriteSliceBytes(util.ConcatBuffers(fragment))
+		return
+	}
+	vt.writeOBU(header, append(net.Buffers{fragment[0][n:]}, fragment[1:]...))
+}
+
+// RTP格式补完，每个OBU都带有长度字段（W=0），超过MTU的OBU分片
+func (vt *AV1) CompleteRTP(value *AVFrame) {
+	var obus []net.Buffers
+	if value.IFrame && vt.seqOBU != nil {
+		if value.AUList.Length == 0 || codec.ParseAV1OBUType(value.AUList.Next.Value.GetByte(0)) != codec.AV1_OBU_SEQUENCE_HEADER {
+			obus = append(obus, net.Buffers{vt.seqOBU})
+		}
+	}
+	value.AUList.Range(func(au *util.BLL) bool {
+		obus = append(obus, au.ToBuffers())
+		return true
+	})
+	var out [][][]byte
+	var packet [][]byte
+	size := 0
+	newPacket := func(agg byte) {
+		packet = [][]byte{{agg}}
+		size = 1
+	}
+	appendElement := func(element net.Buffers, l int) {
+		packet = append(packet, codec.AppendLEB128(nil, uint64(l)))
+		packet = append(packet, element...)
+		size += codec.SizeOfLEB128(uint64(l)) + l
+	}
+	if value.IFrame {
+		newPacket(0b0000_1000) // N 新的编码视频序列
+	} else {
+		newPacket(0)
+	}
+	for _, obu := range obus {
+		l := util.SizeOfBuffers(obu)
+		if size+codec.SizeOfLEB128(uint64(l))+l <= RTPMTU {
+			appendElement(obu, l)
+			continue
+		}
+		if size > 1 {
+			out = append(out, packet)
+			newPacket(0)
+		}
+		chunks := util.SplitBuffers(obu, RTPMTU-4)
+		for i, chunk := range chunks {
+			if i > 0 {
+				out = append(out, packet)
+				newPacket(0b1000_0000) // Z 第一个元素是上一个包中OBU的后续
+			}
+			appendElement(chunk, util.SizeOfBuffers(chunk))
+			if i < len(chunks)-1 {
+				packet[0][0] |= 0b0100_0000 // Y 最后一个元素在下一个包中继续
+			}
+		}
+	}
+	out = append(out, packet)
+	vt.PacketizeRTP(out...)
+}
+
+// CompleteAVCC 补完 Enhanced RTMP 格式，OBU需要带上长度字段
+func (vt *AV1) CompleteAVCC(rv *AVFrame) {
+	mem := vt.BytesPool.Get(5)
+	b := mem.Value
+	if rv.IFrame {
+		b[0] = 0b1001_0000 | codec.PacketTypeCodedFrames
+	} else {
+		b[0] = 0b1010_0000 | codec.PacketTypeCodedFrames
+	}
+	copy(b[1:], codec.FourCC_AV1)
+	rv.AVCC.Push(mem)
+	rv.AUList.Range(func(au *util.BLL) bool {
+		header := au.Next.Value
+		size := uint64(au.ByteLength - header.Len())
+		mem = vt.BytesPool.Get(header.Len() + codec.SizeOfLEB128(size))
+		copy(mem.Value, header)
+		mem.Value[0] |= codec.AV1_OBU_HAS_SIZE_FIELD
+		codec.AppendLEB128(mem.Value[:header.Len()], size)
+		rv.AVCC.Push(mem)
+		au.Next.Next.Range(func(slice util.Buffer) bool {
+			rv.AVCC.Push(vt.BytesPool.GetShell(slice))
+			return true
+		})
+		return true
+	})
+}
@@ -1,6 +1,7 @@
 package track
 
 import (
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -96,7 +97,11 @@ type Media struct {
 	SpesificTrack `json:"-" yaml:"-"`
 	deltaTs       time.Duration //用于接续发布后时间戳连续
 	deltaDTSRange time.Duration //DTS差的范围
+	timeshift     atomic.Int64  //订阅者请求过的最长回看时长
 	流速控制
+
+	dvr    atomic.Pointer[DVR] //磁盘回看，没有开启时为nil
+	signal util.Signal         //每写入一帧广播一次，唤醒等待的AVRingReader
 }
 
 func (av *Media) GetRBSize() int {
@@ -187,6 +192,10 @@ func (av *Media) WriteSequenceHead(sh []byte) {
 	av.SequenceHead = sh
 	av.SequenceHeadSeq++
 }
+func (av *Media) GetSequenceHead() []byte {
+	return av.SequenceHead
+}
+
 func (av *Media) AppendAuBytes(b ...[]byte) {
 	var au util.BLL
 	for _, bb := range b {
@@ -205,8 +214,56 @@ func (av *Media) narrow(gop int) {
 	}
 }
 
+// SetTimeshift 订阅者请求回看时调用，缓存时长只增加不减少
+func (av *Media) SetTimeshift(d time.Duration) {
+	for old := av.timeshift.Load(); int64(d) > old; old = av.timeshift.Load() {
+		if av.timeshift.CompareAndSwap(old, int64(d)) {
+			av.Info("timeshift window", zap.Duration("window", d))
+			return
+		}
+	}
+}
+
+// bufferTime 发布者配置的缓冲长度和订阅者请求的回看时长中较大的一个
+func (av *Media) bufferTime() time.Duration {
+	bufferTime := av.Stream.GetPublisherConfig().BufferTime
+	if timeshift := time.Duration(av.timeshift.Load()); timeshift > bufferTime {
+		return timeshift
+	}
+	return bufferTime
+}
+
+// TimeshiftRing 在缓存的关键帧中查找时间戳不超过ts的最后一个，都超过时返回最早的关键帧，没有缓存时返回nil
+func (av *Media) TimeshiftRing(ts time.Duration) (ring *util.Ring[AVFrame]) {
+	av.IDRingList.Range(func(r *util.Ring[AVFrame]) bool {
+		if ring == nil || r.Value.Timestamp <= ts {
+			ring = r
+			return true
+		}
+		return false
+	})
+	return
+}
+
+// ringFrom 磁盘回看读到内存缓冲范围内时，返回序号为seq的帧所在位置，缓冲中没有该帧时返回nil
+func (av *Media) ringFrom(seq uint32) *util.Ring[AVFrame] {
+	oldest := av.HistoryRing
+	if oldest == nil {
+		oldest = av.IDRing
+	}
+	if oldest == nil || oldest.Value.Sequence > seq {
+		return nil
+	}
+	for r := oldest; r != av.Ring; r = r.Next() {
+		if r.Value.Sequence >= seq {
+			return r
+		}
+	}
+	return av.Ring
+}
+
 func (av *Media) AddIDR() {
-	if av.Stream.GetPublisherConfig().BufferTime > 0 {
+	if av.bufferTime() > 0 {
 		av.IDRingList.AddIDR(av.Ring)
 		if av.HistoryRing == nil {
 			av.HistoryRing = av.IDRing
@@ -264,7 +321,7 @@ func (av *Media) Flush() {
 		curValue.DeltaTime = uint32((curValue.Timestamp - preValue.Timestamp) / time.Millisecond)
 	}
 	av.Trace("write", zap.Uint32("seq", curValue.Sequence), zap.Duration("dts", curValue.DTS), zap.Duration("dts delta", curValue.DTS-preValue.DTS), zap.Uint32("delta", curValue.DeltaTime), zap.Duration("timestamp", curValue.Timestamp))
-	bufferTime := av.Stream.GetPublisherConfig().BufferTime
+	bufferTime := av.bufferTime()
 	if bufferTime > 0 && av.IDRingList.Length > 1 && curValue.Timestamp-av.IDRingList.Next.Next.Value.Value.Timestamp > bufferTime {
 		av.ShiftIDR()
 		av.narrow(int(curValue.Sequence - av.HistoryRing.Value.Sequence))
@@ -299,4 +356,5 @@ func (av *Media) Flush() {
 	curValue.Reset()
 	curValue.Sequence = av.MoveCount
 	preValue.CanRead = true
+	av.signal.Broadcast()
 }
@@ -0,0 +1,137 @@
+package track
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"m7s.live/engine/v4/codec"
+)
+
+// CaptionEvent 发布到caption数据轨道上的字幕事件，表示从PTS开始该频道显示的文本
+type CaptionEvent struct {
+	PTS     time.Duration
+	Channel string // CC1~CC4 或 SERVICE1~SERVICE63
+	Text    string // 为空表示清屏
+}
+
+type captionPending struct {
+	pts time.Duration
+	ccs []codec.CCData
+}
+
+// captionExtractor cc_data 按照解码顺序出现，需要按PTS排序后再送入解码器
+type captionExtractor struct {
+	codec.CaptionDecoder
+	track   *Data
+	pending []captionPending
+	pts     time.Duration // 正在解码的cc_data的PTS
+}
+
+// captionMaxPending DTS异常时最多缓存的帧数
+const captionMaxPending = 32
+
+func (vt *Video) newCaptionExtractor() *captionExtractor {
+	ce := &captionExtractor{}
+	ce.OnCaption = func(channel, text string) {
+		if ce.track == nil {
+			ce.track = &Data{}
+			ce.track.Init(10)
+			ce.track.SetStuff("caption", vt.Stream)
+			ce.track.Attach()
+		}
+		ce.track.Push(&CaptionEvent{ce.pts, channel, text})
+	}
+	return ce
+}
+
+// pushCaption 取出刚写入的帧中的ATSC A/53 cc_data，PTS不大于当前DTS的cc_data不会再有更早的帧，可以解码
+func (vt *Video) pushCaption(seis []*SEI) {
+	for _, sei := range seis {
+		r, ok := sei.Value.(codec.SEIUserDataRegistered)
+		if !ok {
+			continue
+		}
+		// 其他的T.35数据例如HDR10+，直接忽略
+		ccs, err := codec.ParseA53CCData(r)
+		if err != nil || len(ccs) == 0 {
+			continue
+		}
+		if vt.caption == nil {
+			vt.caption = vt.newCaptionExtractor()
+		}
+		pts := vt.LastValue.PTS
+		i := sort.Search(len(vt.caption.pending), func(i int) bool {
+			return vt.caption.pending[i].pts > pts
+		})
+		vt.caption.pending = append(vt.caption.pending, captionPending{})
+		copy(vt.caption.pending[i+1:], vt.caption.pending[i:])
+		vt.caption.pending[i] = captionPending{pts, ccs}
+	}
+	if ce := vt.caption; ce != nil {
+		for len(ce.pending) > 0 && (ce.pending[0].pts <= vt.LastValue.DTS || len(ce.pending) > captionMaxPending) {
+			ce.pts = ce.pending[0].pts
+			ce.Decode(ce.pending[0].ccs)
+			ce.pending = ce.pending[1:]
+		}
+	}
+}
+
+// WebVTT 把caption数据轨道上的字幕事件转换为WebVTT的cue，时间与Reader.GetPTS32一致，相对于Reader.SkipTs
+type WebVTT struct {
+	Channel string        // 只输出该频道，为空时输出收到的第一个频道
+	Reader  *AVRingReader // 订阅者的视频Reader，为空时使用原始的PTS
+	current *CaptionEvent // 正在显示的字幕
+}
+
+func NewWebVTT(reader *AVRingReader, channel string) *WebVTT {
+	return &WebVTT{Channel: channel, Reader: reader}
+}
+
+// Header WebVTT文件头
+func (w *WebVTT) Header() string {
+	return "WEBVTT\n\n"
+}
+
+// Time 把PTS转换为相对于SkipTs的时间
+func (w *WebVTT) Time(pts time.Duration) time.Duration {
+	if w.Reader != nil {
+		pts -= w.Reader.SkipTs * 90 / time.Millisecond
+	}
+	if pts < 0 {
+		return 0
+	}
+	return pts * time.Millisecond / 90
+}
+
+// Cue 输入一个字幕事件，返回因此结束的上一条cue，没有时返回空字符串
+func (w *WebVTT) Cue(e *CaptionEvent) (cue string) {
+	if w.Channel == "" {
+		w.Channel = e.Channel
+	}
+	if e.Channel != w.Channel {
+		return
+	}
+	cue = w.End(e.PTS)
+	if e.Text != "" {
+		w.current = e
+	}
+	return
+}
+
+// End 在pts时结束正在显示的字幕，返回对应的cue
+func (w *WebVTT) End(pts time.Duration) (cue string) {
+	if c := w.current; c != nil {
+		w.current = nil
+		if start, end := w.Time(c.PTS), w.Time(pts); end > start {
+			cue = fmt.Sprintf("%s --> %s\n%s\n\n", formatVTTTime(start), formatVTTTime(end), strings.ReplaceAll(c.Text, "-->", "->"))
+		}
+	}
+	return
+}
+
+func formatVTTTime(t time.Duration) string {
+	ms := t.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,452 @@
+package track
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/config"
+	"m7s.live/engine/v4/util"
+)
+
+var ErrDVRCorrupt = errors.New("dvr segment corrupt")
+
+// dvrUsage 所有轨道的分段文件总字节数，用于限制磁盘占用
+var dvrUsage atomic.Int64
+
+// 分段文件由两种记录组成
+// 序列头记录：'S' SequenceHeadSeq(4) 长度(4) 序列头
+// 帧记录：'F' 标志(1，第0位IFrame，第1位Droppable) Sequence(4) PTS(8) DTS(8) Timestamp(8) 长度(4) AVCC
+const (
+	dvrRecordSequenceHead = 'S'
+	dvrRecordFrame        = 'F'
+	dvrFrameHeadSize      = 1 + 4 + 8 + 8 + 8 + 4
+)
+
+// DVRRange 一段连续可回看的时间，Start、End为帧的绝对时间戳，StartTime、EndTime为帧的写入时间
+type DVRRange struct {
+	Start     time.Duration
+	End       time.Duration
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// dvrIndex 分段内可以开始读取的位置，视频为关键帧，音频大约每秒一个
+type dvrIndex struct {
+	ts     time.Duration
+	offset int64
+	sh     []byte
+	shSeq  int
+}
+
+type dvrSegment struct {
+	DVRRange
+	name  string // 文件名，目录随流路径改变，见DVR.Move
+	size  int64  // 已经写入文件的完整记录的长度
+	index []dvrIndex
+}
+
+// DVR 磁盘回看，把轨道中的帧按SegmentDuration写入分段文件，保留window时长，订阅者从内存缓冲以外的位置回看时从这里读取
+type DVR struct {
+	media    *Media
+	dir      string // 由mu保护
+	window   time.Duration
+	conf     *config.DVR
+	keyframe bool                  // 视频分段从关键帧开始
+	split    func([]byte) [][]byte // 从AVCC中取出AU，用于还原AUList
+	mu       sync.RWMutex
+	segments []*dvrSegment
+	closed   bool
+	context.CancelFunc
+}
+
+// startDVR 清空dir后开始写入，写入在单独的协程中通过AVRingReader读取轨道，不影响发布者
+func (av *Media) startDVR(dir string, window time.Duration, conf *config.DVR, keyframe bool, split func([]byte) [][]byte) (*DVR, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0766); err != nil {
+		return nil, err
+	}
+	d := &DVR{media: av, dir: dir, window: window, conf: conf, keyframe: keyframe, split: split}
+	ctx, cancel := context.WithCancel(context.Background())
+	d.CancelFunc = cancel
+	av.dvr.Store(d)
+	av.Info("dvr start", zap.String("dir", dir), zap.Duration("window", window))
+	go d.run(ctx)
+	return d, nil
+}
+
+// StartDVR 开始将视频轨道写入磁盘，H264、H265按NALU还原AUList，其他编码整帧作为一个AU
+func (vt *Video) StartDVR(dir string, window time.Duration, conf *config.DVR) (*DVR, error) {
+	split := func(avcc []byte) [][]byte {
+		return [][]byte{avcc[5:]}
+	}
+	if vt.CodecID == codec.CodecID_H264 || vt.CodecID == codec.CodecID_H265 {
+		split = func(avcc []byte) (aus [][]byte) {
+			for b := avcc[5:]; len(b) > vt.nalulenSize; {
+				nalulen := util.ReadBE[int](b[:vt.nalulenSize])
+				if b = b[vt.nalulenSize:]; nalulen > len(b) {
+					break
+				}
+				aus = append(aus, b[:nalulen])
+				b = b[nalulen:]
+			}
+			return
+		}
+	}
+	return vt.startDVR(dir, window, conf, true, split)
+}
+
+// StartDVR 开始将音频轨道写入磁盘
+func (a *Audio) StartDVR(dir string, window time.Duration, conf *config.DVR) (*DVR, error) {
+	head := len(a.AVCCHead)
+	return a.startDVR(dir, window, conf, false, func(avcc []byte) [][]byte {
+		return [][]byte{avcc[head:]}
+	})
+}
+
+// GetDVR 没有开启磁盘回看时返回nil
+func (av *Media) GetDVR() *DVR {
+	return av.dvr.Load()
+}
+
+func (d *DVR) Window() time.Duration {
+	return d.window
+}
+
+func (d *DVR) run(ctx context.Context) {
+	var file *os.File
+	var w *bufio.Writer
+	var seg *dvrSegment
+	var shSeq int
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+		d.dispose()
+	}()
+	reader := NewAVRingReader(d.media, config.Global.Poll)
+	reader.Logger = d.media.With(zap.String("reader", "dvr"))
+	head := make([]byte, dvrFrameHeadSize)
+	var offset int64
+	var lastIndex time.Duration
+	for ctx.Err() == nil {
+		if err := reader.Read(ctx, 1); err != nil || ctx.Err() != nil {
+			return
+		}
+		frame := reader.Frame
+		if frame.AVCC.ByteLength == 0 {
+			continue
+		}
+		key := !d.keyframe || frame.IFrame
+		if seg != nil && key && frame.Timestamp-seg.Start >= d.conf.SegmentDuration {
+			file.Close()
+			file, seg = nil, nil
+		}
+		if seg == nil {
+			if !key {
+				continue
+			}
+			seg = &dvrSegment{name: fmt.Sprintf("%d.dvr", frame.Timestamp.Milliseconds())}
+			seg.Start, seg.StartTime = frame.Timestamp, frame.WriteTime
+			var err error
+			d.mu.RLock()
+			file, err = os.Create(filepath.Join(d.dir, seg.name))
+			d.mu.RUnlock()
+			if err != nil {
+				d.media.Error("dvr create segment", zap.Error(err))
+				return
+			}
+			if w == nil {
+				w = bufio.NewWriter(file)
+			} else {
+				w.Reset(file)
+			}
+			offset, shSeq = 0, 0
+		}
+		sh, seq := d.media.SequenceHead, d.media.SequenceHeadSeq
+		if seq != shSeq {
+			shSeq = seq
+			w.WriteByte(dvrRecordSequenceHead)
+			w.Write(util.PutBE(head[:4], uint32(seq)))
+			w.Write(util.PutBE(head[:4], uint32(len(sh))))
+			w.Write(sh)
+			offset += int64(9 + len(sh))
+		}
+		index := seg.size == 0 || (key && (d.keyframe || frame.Timestamp-lastIndex >= time.Second))
+		if index {
+			lastIndex = frame.Timestamp
+		}
+		frameOffset := offset
+		head[0] = 0
+		if frame.IFrame {
+			head[0] |= 1
+		}
+		if frame.Droppable {
+			head[0] |= 2
+		}
+		util.PutBE(head[1:5], frame.Sequence)
+		util.PutBE(head[5:13], int64(frame.PTS))
+		util.PutBE(head[13:21], int64(frame.DTS))
+		util.PutBE(head[21:29], int64(frame.Timestamp))
+		util.PutBE(head[29:33], uint32(frame.AVCC.ByteLength))
+		w.WriteByte(dvrRecordFrame)
+		w.Write(head)
+		frame.AVCC.Range(func(b util.Buffer) bool {
+			w.Write(b)
+			return true
+		})
+		offset += int64(1 + dvrFrameHeadSize + frame.AVCC.ByteLength)
+		if err := w.Flush(); err != nil {
+			d.media.Error("dvr write", zap.Error(err))
+			return
+		}
+		d.mu.Lock()
+		if index {
+			seg.index = append(seg.index, dvrIndex{frame.Timestamp, frameOffset, sh, seq})
+		}
+		dvrUsage.Add(offset - seg.size)
+		if seg.size == 0 {
+			d.segments = append(d.segments, seg)
+		}
+		seg.size, seg.End, seg.EndTime = offset, frame.Timestamp, frame.WriteTime
+		if index && len(seg.index) == 1 {
+			d.trim(frame.Timestamp)
+		}
+		d.mu.Unlock()
+	}
+}
+
+// trim 新分段开始时删除超出回看窗口的分段，总大小超过上限时继续删除最早的分段，当前分段不会被删除
+func (d *DVR) trim(now time.Duration) {
+	maxSize := d.conf.MaxSize << 20
+	for len(d.segments) > 1 {
+		seg := d.segments[0]
+		if seg.End >= now-d.window && (maxSize <= 0 || dvrUsage.Load() <= maxSize) {
+			return
+		}
+		// 正在读取该分段的订阅者持有文件句柄，删除后仍可以读完
+		if err := os.Remove(filepath.Join(d.dir, seg.name)); err != nil {
+			d.media.Warn("dvr remove segment", zap.Error(err))
+		}
+		dvrUsage.Add(-seg.size)
+		d.segments = d.segments[1:]
+	}
+}
+
+func (d *DVR) dispose() {
+	d.media.dvr.CompareAndSwap(d, nil)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.closed = true
+	for _, seg := range d.segments {
+		dvrUsage.Add(-seg.size)
+	}
+	d.segments = nil
+	if err := os.RemoveAll(d.dir); err != nil {
+		d.media.Warn("dvr remove dir", zap.Error(err))
+	}
+	d.media.Info("dvr stop", zap.String("dir", d.dir))
+}
+
+// Move 流被移动后把整个目录改名为dir，正在写入以及正在读取的分段文件句柄不受影响
+func (d *DVR) Move(dir string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed || dir == d.dir {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0766); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Rename(d.dir, dir); err != nil {
+		return err
+	}
+	d.media.Info("dvr move", zap.String("from", d.dir), zap.String("dir", dir))
+	d.dir = dir
+	return nil
+}
+
+// Ranges 合并相邻的分段，间隔超过1秒（例如发布者断开过）时分为多段
+func (d *DVR) Ranges() (ranges []DVRRange) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, seg := range d.segments {
+		if l := len(ranges); l > 0 && seg.Start-ranges[l-1].End <= time.Second {
+			ranges[l-1].End, ranges[l-1].EndTime = seg.End, seg.EndTime
+		} else {
+			ranges = append(ranges, seg.DVRRange)
+		}
+	}
+	return
+}
+
+// Seek 从时间戳不超过ts的最后一个索引位置开始读取，都超过时从最早的位置开始，没有数据时返回nil
+func (d *DVR) Seek(ts time.Duration) *DVRCursor {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed || len(d.segments) == 0 {
+		return nil
+	}
+	seg := d.segments[0]
+	for _, s := range d.segments[1:] {
+		if s.Start > ts {
+			break
+		}
+		seg = s
+	}
+	index := seg.index[0]
+	for _, i := range seg.index[1:] {
+		if i.ts > ts {
+			break
+		}
+		index = i
+	}
+	c := &DVRCursor{dvr: d, SequenceHead: index.sh, SequenceHeadSeq: index.shSeq, Timestamp: index.ts}
+	if err := c.open(seg, index.offset); err != nil {
+		d.media.Warn("dvr seek", zap.Error(err))
+		return nil
+	}
+	return c
+}
+
+// DVRCursor 按顺序读取分段文件中的帧，读到正在写入的位置时返回io.EOF
+type DVRCursor struct {
+	dvr             *DVR
+	seg             *dvrSegment
+	file            *os.File
+	reader          *bufio.Reader
+	offset          int64
+	head            [dvrFrameHeadSize]byte
+	Frame           AVFrame
+	SequenceHead    []byte
+	SequenceHeadSeq int
+	Timestamp       time.Duration // Seek到的位置的时间戳
+}
+
+// open 调用时需要持有dvr.mu的读锁
+func (c *DVRCursor) open(seg *dvrSegment, offset int64) (err error) {
+	if c.file != nil {
+		c.file.Close()
+	}
+	if c.file, err = os.Open(filepath.Join(c.dvr.dir, seg.name)); err != nil {
+		return
+	}
+	if _, err = c.file.Seek(offset, io.SeekStart); err != nil {
+		return
+	}
+	if c.reader == nil {
+		c.reader = bufio.NewReader(c.file)
+	} else {
+		c.reader.Reset(c.file)
+	}
+	c.seg, c.offset = seg, offset
+	return
+}
+
+// nextSegment 当前分段读完后打开下一个，当前分段已经被删除时从其后第一个分段继续
+func (c *DVRCursor) nextSegment() error {
+	d := c.dvr
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, seg := range d.segments {
+		if seg.Start > c.seg.Start {
+			return c.open(seg, 0)
+		}
+	}
+	return io.EOF
+}
+
+func (c *DVRCursor) Next() (*AVFrame, error) {
+	for {
+		d := c.dvr
+		d.mu.RLock()
+		size, closed := c.seg.size, d.closed
+		d.mu.RUnlock()
+		if closed {
+			return nil, io.EOF
+		}
+		if c.offset >= size {
+			if err := c.nextSegment(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		t, err := c.reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case dvrRecordSequenceHead:
+			if _, err = io.ReadFull(c.reader, c.head[:8]); err != nil {
+				return nil, err
+			}
+			sh := make([]byte, util.ReadBE[int](c.head[4:8]))
+			if _, err = io.ReadFull(c.reader, sh); err != nil {
+				return nil, err
+			}
+			c.SequenceHead, c.SequenceHeadSeq = sh, util.ReadBE[int](c.head[:4])
+			c.offset += int64(9 + len(sh))
+		case dvrRecordFrame:
+			return c.readFrame()
+		default:
+			return nil, ErrDVRCorrupt
+		}
+	}
+}
+
+func (c *DVRCursor) readFrame() (*AVFrame, error) {
+	head := c.head[:]
+	if _, err := io.ReadFull(c.reader, head); err != nil {
+		return nil, err
+	}
+	avcc := make([]byte, util.ReadBE[int](head[29:33]))
+	if _, err := io.ReadFull(c.reader, avcc); err != nil {
+		return nil, err
+	}
+	c.offset += int64(1 + dvrFrameHeadSize + len(avcc))
+	// 磁盘中的帧没有RTP格式，每次读取复用同一个AVFrame，数据不放回内存池
+	var pool util.BytesPool
+	frame := &c.Frame
+	preTs := frame.Timestamp
+	frame.Reset()
+	frame.IFrame = head[0]&1 == 1
+	frame.Droppable = head[0]&2 == 2
+	frame.Sequence = util.ReadBE[uint32](head[1:5])
+	frame.PTS = time.Duration(util.ReadBE[int64](head[5:13]))
+	frame.DTS = time.Duration(util.ReadBE[int64](head[13:21]))
+	frame.Timestamp = time.Duration(util.ReadBE[int64](head[21:29]))
+	if preTs > 0 && frame.Timestamp > preTs {
+		frame.DeltaTime = uint32((frame.Timestamp - preTs) / time.Millisecond)
+	}
+	frame.BytesIn = len(avcc)
+	frame.AVCC.Push(pool.GetShell(avcc))
+	for _, au := range c.dvr.split(avcc) {
+		var bll util.BLL
+		bll.Push(pool.GetShell(au))
+		frame.AUList.PushValue(&bll)
+	}
+	frame.CanRead = true
+	return frame, nil
+}
+
+func (c *DVRCursor) Close() {
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+}
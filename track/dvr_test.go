@@ -0,0 +1,53 @@
+package track
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/log"
+	"m7s.live/engine/v4/util"
+)
+
+func TestDVRMove(t *testing.T) {
+	root := t.TempDir()
+	dir, newDir := filepath.Join(root, "live", "a", "h264"), filepath.Join(root, "live", "b", "h264")
+	if err := os.MkdirAll(dir, 0766); err != nil {
+		t.Fatal(err)
+	}
+	// 新目录中残留的文件会被清除
+	if err := os.MkdirAll(newDir, 0766); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(newDir, "1000.dvr"), nil, 0666)
+	record := make([]byte, 1+dvrFrameHeadSize)
+	record[0], record[1] = dvrRecordFrame, 1
+	util.PutBE(record[30:34], uint32(3))
+	record = append(record, "abc"...)
+	if err := os.WriteFile(filepath.Join(dir, "0.dvr"), record, 0666); err != nil {
+		t.Fatal(err)
+	}
+	var media Media
+	media.Zap = &log.Logger{Logger: zap.NewNop()}
+	d := &DVR{media: &media, dir: dir, split: func(avcc []byte) [][]byte { return [][]byte{avcc} }}
+	d.segments = []*dvrSegment{{name: "0.dvr", size: int64(len(record)), index: []dvrIndex{{}}}}
+	if err := d.Move(newDir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("old dir still exists: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(newDir, "1000.dvr")); !os.IsNotExist(err) {
+		t.Errorf("stale segment in new dir: %v", err)
+	}
+	c := d.Seek(0)
+	if c == nil {
+		t.Fatal("Seek after Move = nil")
+	}
+	defer c.Close()
+	frame, err := c.Next()
+	if err != nil || !frame.IFrame || string(frame.AVCC.ToBytes()) != "abc" {
+		t.Fatalf("Next = %v, %v", frame, err)
+	}
+}
@@ -70,7 +70,7 @@ func (vt *H265) WriteSliceBytes(slice []byte) {
 	case 0, 1, 2, 3, 4, 5, 6, 7, 8, 9:
 		vt.Value.IFrame = false
 		vt.AppendAuBytes(slice)
-	case codec.NAL_UNIT_SEI:
+	case codec.NAL_UNIT_SEI, codec.NAL_UNIT_SEI_SUFFIX:
 		vt.AppendAuBytes(slice)
 	default:
 		vt.Warn("h265 slice type not supported", zap.Uint("type", uint(t)))
@@ -79,7 +79,7 @@ func (vt *H265) WriteSliceBytes(slice []byte) {
 func (vt *H265) writeSequenceHead(head []byte) (err error) {
 	vt.WriteSequenceHead(head)
 	if vt.VPS, vt.SPS, vt.PPS, err = codec.ParseVpsSpsPpsFromSeqHeaderWithoutMalloc(vt.SequenceHead); err == nil {
-		vt.SPSInfo, _ = codec.ParseHevcSPS(vt.SequenceHead)
+		vt.SPSInfo, _ = codec.ParseHevcSPS(vt.SPS)
 		vt.nalulenSize = (int(vt.SequenceHead[26]) & 0x03) + 1
 	} else {
 		vt.Error("H265 ParseVpsSpsPps Error")
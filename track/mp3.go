@@ -0,0 +1,139 @@
+package track
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/util"
+)
+
+var _ SpesificTrack = (*MP3)(nil)
+
+func NewMP3(stream IStream, stuff ...any) (mp3 *MP3) {
+	mp3 = &MP3{}
+	mp3.CodecID = codec.CodecID_MP3
+	mp3.SampleSize = 16
+	mp3.Channels = 2
+	mp3.AVCCHead = []byte{(byte(mp3.CodecID) << 4) | 0x0F}
+	mp3.SetStuff("mp3", stream, int(256), byte(14), uint32(44100), mp3, time.Millisecond*10)
+	mp3.SetStuff(stuff...)
+	if mp3.BytesPool == nil {
+		mp3.BytesPool = make(util.BytesPool, 17)
+	}
+	return
+}
+
+type MP3 struct {
+	Audio
+	Header   codec.MPEGAudioHeader // 最近一帧的帧头
+	fragment util.Buffer           // RTP中被分片的帧
+}
+
+// setHeader 根据帧头更新采样率和声道，第一次解析到帧头时Attach
+func (mp3 *MP3) setHeader(h codec.MPEGAudioHeader) {
+	mp3.Header = h
+	if mp3.Attached.Load() && uint32(h.SampleRate) == mp3.SampleRate && h.Channels == mp3.Channels {
+		return
+	}
+	mp3.SampleRate = uint32(h.SampleRate)
+	mp3.Channels = h.Channels
+	mp3.AVCCHead = []byte{(byte(mp3.CodecID) << 4) | flvSoundRate(h.SampleRate)<<2 | 1<<1 | (h.Channels - 1)}
+	mp3.Attach()
+}
+
+// writeFrames 写入连续的若干帧，每一帧单独作为一个AVFrame，pts依次递增
+func (mp3 *MP3) writeFrames(pts uint32, raw []byte) {
+	for len(raw) >= 4 {
+		h, err := codec.ParseMPEGAudioHeader(raw)
+		if err != nil {
+			mp3.Warn("mp3 frame header error", zap.Error(err), zap.Int("len", len(raw)))
+			return
+		}
+		l := h.FrameLength
+		if l == 0 || l > len(raw) {
+			// free 格式或者帧不完整时，剩余的数据都作为一帧
+			l = len(raw)
+		}
+		mp3.setHeader(h)
+		mp3.AppendAuBytes(raw[:l])
+		mp3.generateTimestamp(pts)
+		mp3.Flush()
+		pts += uint32(h.Samples * 90000 / h.SampleRate)
+		raw = raw[l:]
+	}
+}
+
+// WriteRaw 写入一个或多个完整的帧，例如TS中的一个PES
+func (mp3 *MP3) WriteRaw(pts uint32, raw []byte) {
+	mp3.Value.BytesIn += len(raw)
+	mp3.writeFrames(pts, raw)
+}
+
+func (mp3 *MP3) WriteAVCC(ts uint32, frame *util.BLL) error {
+	if l := frame.ByteLength; l < 5 {
+		mp3.Error("AVCC data too short", zap.Int("len", l))
+		return io.ErrShortWrite
+	}
+	h, err := codec.ParseMPEGAudioHeader([]byte{frame.GetByte(1), frame.GetByte(2), frame.GetByte(3), frame.GetByte(4)})
+	if err != nil {
+		mp3.Error("mp3 frame header error", zap.Error(err))
+		frame.Recycle()
+		return err
+	}
+	mp3.setHeader(h)
+	au := frame.ToBuffers()
+	au[0] = au[0][1:]
+	mp3.AppendAuBytes(au...)
+	mp3.Audio.WriteAVCC(ts, frame)
+	return nil
+}
+
+// WriteRTPFrame MPEG Audio RTP 解包，时钟频率固定为90000 https://datatracker.ietf.org/doc/html/rfc2250#section-3.5
+func (mp3 *MP3) WriteRTPFrame(frame *RTPFrame) {
+	if len(frame.Payload) < 5 {
+		mp3.Warn("mp3 rtp payload too short", zap.Uint16("seq", frame.SequenceNumber))
+		return
+	}
+	// MBZ|Frag_offset
+	offset := int(util.ReadBE[uint16](frame.Payload[2:4]))
+	payload := frame.Payload[4:]
+	if offset == 0 {
+		mp3.fragment = nil
+		if h, err := codec.ParseMPEGAudioHeader(payload); err == nil && h.FrameLength > len(payload) {
+			mp3.fragment = append(mp3.fragment, payload...)
+			return
+		}
+		mp3.writeFrames(frame.Timestamp, payload)
+		return
+	}
+	if mp3.fragment == nil || mp3.fragment.Len() != offset {
+		// 分片丢失
+		mp3.fragment = nil
+		return
+	}
+	mp3.fragment = append(mp3.fragment, payload...)
+	if h, _ := codec.ParseMPEGAudioHeader(mp3.fragment); mp3.fragment.Len() >= h.FrameLength {
+		mp3.writeFrames(frame.Timestamp, mp3.fragment)
+		mp3.fragment = nil
+	}
+}
+
+// CompleteRTP 每个包带4字节的头，超过MTU的帧分片并带上偏移
+func (mp3 *MP3) CompleteRTP(value *AVFrame) {
+	var packets [][][]byte
+	offset := 0
+	for _, chunk := range util.SplitBuffers(value.AUList.ToBuffers(), RTPMTU-4) {
+		packets = append(packets, append(net.Buffers{{0, 0, byte(offset >> 8), byte(offset)}}, chunk...))
+		offset += util.SizeOfBuffers(chunk)
+	}
+	mp3.PacketizeRTP(packets...)
+	// RTP 时钟频率为90000，与采样率无关
+	value.RTP.Range(func(frame RTPFrame) bool {
+		frame.Timestamp = uint32(value.PTS)
+		return true
+	})
+}
@@ -0,0 +1,93 @@
+package track
+
+import (
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/util"
+)
+
+var _ SpesificTrack = (*Opus)(nil)
+
+func NewOpus(stream IStream, stuff ...any) (opus *Opus) {
+	opus = &Opus{}
+	opus.CodecID = codec.CodecID_OPUS
+	opus.SampleSize = 16
+	opus.Channels = 2
+	// AVCC格式中与AAC一样使用两个字节，第二个字节为0表示SequenceHead（OpusHead）
+	opus.AVCCHead = []byte{(byte(opus.CodecID) << 4) | 0x0F, 1}
+	opus.SetStuff("opus", stream, int(256), byte(111), uint32(48000), opus, time.Millisecond*10)
+	opus.SetStuff(stuff...)
+	if opus.BytesPool == nil {
+		opus.BytesPool = make(util.BytesPool, 17)
+	}
+	opus.Head = codec.NewOpusHead(opus.Channels)
+	opus.Media.WriteSequenceHead(append([]byte{opus.AVCCHead[0], 0}, opus.Head.Marshal()...))
+	opus.Attach()
+	return
+}
+
+type Opus struct {
+	Audio
+	Head          codec.OpusHead
+	FrameDuration time.Duration // 最近一个Opus包的时长，由TOC计算
+}
+
+// parseTOC 根据TOC计算包的时长
+func (opus *Opus) parseTOC(packet []byte) {
+	if d, err := codec.OpusPacketDuration(packet); err == nil {
+		opus.FrameDuration = d
+	} else {
+		opus.Warn("opus toc error", zap.Int("len", len(packet)))
+	}
+}
+
+// WriteSequenceHead sh为AVCC格式，两个字节头+OpusHead
+func (opus *Opus) WriteSequenceHead(sh []byte) {
+	var head codec.OpusHead
+	if len(sh) < 2 || head.Parse(sh[2:]) != nil {
+		opus.Error("OpusHead parse error", zap.Int("len", len(sh)))
+		return
+	}
+	opus.Media.WriteSequenceHead(sh)
+	opus.Head = head
+	opus.Channels = head.Channels
+}
+
+func (opus *Opus) WriteAVCC(ts uint32, frame *util.BLL) error {
+	if l := frame.ByteLength; l < 3 {
+		opus.Error("AVCC data too short", zap.Int("len", l))
+		return io.ErrShortWrite
+	}
+	if frame.GetByte(1) == 0 {
+		opus.WriteSequenceHead(frame.ToBytes())
+		frame.Recycle()
+	} else {
+		au := frame.ToBuffers()
+		au[0] = au[0][2:]
+		opus.parseTOC([]byte{frame.GetByte(2), frame.GetByte(3)})
+		opus.AppendAuBytes(au...)
+		opus.Audio.WriteAVCC(ts, frame)
+	}
+	return nil
+}
+
+// WriteRaw 写入一个Opus包，pts为0时根据上一个包的时长生成时间戳
+func (opus *Opus) WriteRaw(pts uint32, raw []byte) {
+	if pts == 0 && opus.FrameDuration > 0 {
+		pts = uint32(opus.LastValue.PTS + opus.FrameDuration*90/time.Millisecond)
+	}
+	opus.parseTOC(raw)
+	opus.Audio.WriteRaw(pts, raw)
+}
+
+// WriteRTPFrame 每个RTP包含有一个完整的Opus包 https://datatracker.ietf.org/doc/html/rfc7587
+func (opus *Opus) WriteRTPFrame(frame *RTPFrame) {
+	opus.generateTimestamp(uint32(uint64(frame.Timestamp) * 90000 / uint64(opus.SampleRate)))
+	opus.parseTOC(frame.Payload)
+	opus.AppendAuBytes(frame.Payload)
+	opus.Flush()
+}
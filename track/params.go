@@ -0,0 +1,87 @@
+package track
+
+import (
+	"bytes"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+)
+
+// TrackParams 轨道的解码参数，视频使用前五个字段，音频使用后三个字段
+type TrackParams struct {
+	Width     uint    `json:",omitempty"`
+	Height    uint    `json:",omitempty"`
+	Profile   uint    `json:",omitempty"`
+	Level     uint    `json:",omitempty"`
+	FrameRate float64 `json:",omitempty"`
+
+	SampleRate          uint32 `json:",omitempty"`
+	Channels            byte   `json:",omitempty"`
+	AudioSpecificConfig []byte `json:",omitempty"`
+}
+
+func (p *TrackParams) Equal(o *TrackParams) bool {
+	return p.Width == o.Width && p.Height == o.Height && p.Profile == o.Profile && p.Level == o.Level && p.FrameRate == o.FrameRate &&
+		p.SampleRate == o.SampleRate && p.Channels == o.Channels && bytes.Equal(p.AudioSpecificConfig, o.AudioSpecificConfig)
+}
+
+// ParamsChanged 序列头更新后解码参数发生了变化，由轨道发送给所在的流
+type ParamsChanged struct {
+	Track    Track
+	Old, New TrackParams
+	Sequence uint32 // 第一个使用新参数的帧的序号，即AVFrame.Sequence
+}
+
+// paramsWatcher 记录上一次序列头对应的参数，序列头重复发送（例如每个I帧前都有SPS、PPS）时不会产生通知
+type paramsWatcher struct {
+	params          TrackParams
+	sequenceHeadSeq int
+}
+
+func (w *paramsWatcher) pending(media *Media) bool {
+	return media.SequenceHeadSeq != w.sequenceHeadSeq
+}
+
+// check 在Flush之前调用，此时Value为第一个使用新序列头的帧
+func (w *paramsWatcher) check(t Track, media *Media, params TrackParams) {
+	first := w.sequenceHeadSeq == 0
+	w.sequenceHeadSeq = media.SequenceHeadSeq
+	if first {
+		w.params = params
+		return
+	}
+	if w.params.Equal(&params) {
+		return
+	}
+	media.Info("params changed", zap.Any("old", w.params), zap.Any("new", params))
+	media.Stream.Receive(ParamsChanged{t, w.params, params, media.Value.Sequence})
+	w.params = params
+}
+
+func (vt *Video) checkParams() {
+	if !vt.params.pending(&vt.Media) {
+		return
+	}
+	vt.params.check(vt, &vt.Media, TrackParams{
+		Width:     vt.Width,
+		Height:    vt.Height,
+		Profile:   vt.ProfileIdc,
+		Level:     vt.LevelIdc,
+		FrameRate: vt.FrameRate,
+	})
+}
+
+func (av *Audio) checkParams() {
+	if !av.params.pending(&av.Media) {
+		return
+	}
+	params := TrackParams{
+		SampleRate: av.SampleRate,
+		Channels:   av.Channels,
+	}
+	if av.CodecID == codec.CodecID_AAC && len(av.SequenceHead) > 2 {
+		params.AudioSpecificConfig = append([]byte(nil), av.SequenceHead[2:]...)
+	}
+	av.params.check(av, &av.Media, params)
+}
@@ -0,0 +1,78 @@
+package track
+
+import (
+	"io"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/util"
+)
+
+var _ SpesificTrack = (*PCM)(nil)
+
+// NewPCM 16位小端的线性PCM，需要调用者通过SetFormat设置好采样率和声道后Attach
+func NewPCM(stream IStream, stuff ...any) (pcm *PCM) {
+	pcm = &PCM{}
+	pcm.Name = "pcm"
+	pcm.CodecID = codec.CodecID_LPCM
+	pcm.SampleSize = 16
+	pcm.Channels = 1
+	pcm.AVCCHead = []byte{(byte(pcm.CodecID) << 4) | (1 << 1)}
+	pcm.SetStuff(stream, int(32), byte(98), uint32(8000), pcm, time.Millisecond*10)
+	pcm.SetStuff(stuff...)
+	if pcm.BytesPool == nil {
+		pcm.BytesPool = make(util.BytesPool, 17)
+	}
+	return
+}
+
+type PCM struct {
+	Audio
+}
+
+// SetFormat 设置采样率和声道数，并更新AVCCHead中对应的标志，在Attach之前调用
+func (pcm *PCM) SetFormat(sampleRate uint32, channels byte) {
+	pcm.SampleRate, pcm.Channels = sampleRate, channels
+	pcm.AVCCHead = []byte{(byte(pcm.CodecID) << 4) | flvSoundRate(int(sampleRate))<<2 | 1<<1 | (channels - 1)}
+}
+
+// swapL16 RTP中的L16为大端 https://datatracker.ietf.org/doc/html/rfc3551#section-4.5.11
+func swapL16(b []byte) []byte {
+	swapped := make([]byte, len(b)&^1)
+	for i := 0; i+1 < len(b); i += 2 {
+		swapped[i], swapped[i+1] = b[i+1], b[i]
+	}
+	return swapped
+}
+
+func (pcm *PCM) WriteAVCC(ts uint32, frame *util.BLL) error {
+	if l := frame.ByteLength; l < 3 {
+		pcm.Error("AVCC data too short", zap.Int("len", l))
+		return io.ErrShortWrite
+	}
+	au := frame.ToBuffers()
+	au[0] = au[0][1:]
+	pcm.AppendAuBytes(au...)
+	pcm.Audio.WriteAVCC(ts, frame)
+	return nil
+}
+
+func (pcm *PCM) WriteRTPFrame(frame *RTPFrame) {
+	pcm.generateTimestamp(uint32(uint64(frame.Timestamp) * 90000 / uint64(pcm.SampleRate)))
+	pcm.AppendAuBytes(swapL16(frame.Payload))
+	pcm.Flush()
+}
+
+func (pcm *PCM) CompleteRTP(value *AVFrame) {
+	var packets [][][]byte
+	for _, chunk := range util.SplitBuffers(value.AUList.ToBuffers(), RTPMTU&^1) {
+		var payload []byte
+		for _, b := range chunk {
+			payload = append(payload, b...)
+		}
+		packets = append(packets, [][]byte{swapL16(payload)})
+	}
+	pcm.PacketizeRTP(packets...)
+}
@@ -0,0 +1,28 @@
+package track
+
+import (
+	"testing"
+
+	"m7s.live/engine/v4/config"
+)
+
+func TestPCMSetFormat(t *testing.T) {
+	defer func(g *config.Engine) { config.Global = g }(config.Global)
+	config.Global = &config.Engine{}
+	for _, c := range []struct {
+		sampleRate uint32
+		channels   byte
+		head       byte
+	}{
+		{8000, 1, 0x32},
+		{16000, 1, 0x36},
+		{22050, 2, 0x3b},
+		{48000, 2, 0x3f},
+	} {
+		pcm := NewPCM(nil)
+		pcm.SetFormat(c.sampleRate, c.channels)
+		if pcm.SampleRate != c.sampleRate || pcm.Channels != c.channels || len(pcm.AVCCHead) != 1 || pcm.AVCCHead[0] != c.head {
+			t.Errorf("SetFormat(%d, %d): %d %d %x, want head %x", c.sampleRate, c.channels, pcm.SampleRate, pcm.Channels, pcm.AVCCHead, c.head)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package track
 
 import (
 	"context"
-	"runtime"
 	"time"
 
 	"go.uber.org/zap"
@@ -17,11 +16,26 @@ const (
 	READSTATE_NORMAL
 )
 
+// 实时模式下视频读取落后时的丢帧级别
+const (
+	DROPLEVEL_NONE   = iota
+	DROPLEVEL_NONREF // 丢弃非参考帧
+	DROPLEVEL_NONIDR // 只读取关键帧
+)
+
+// DropStats 读取落后时丢帧的统计
+type DropStats struct {
+	Level      byte // 当前的丢帧级别
+	NonRef     int  // 丢弃的非参考帧数量
+	NonIDR     int  // 只读取关键帧时丢弃的帧数量
+	Jump       int  // 跳到最新关键帧（回看时为缓存的最早关键帧）的次数
+	JumpFrames int  // 跳过的帧数量
+}
+
 type AVRingReader struct {
 	ctx   context.Context
 	Track *Media
 	*util.Ring[common.AVFrame]
-	wait       func()
 	State      byte
 	FirstSeq   uint32
 	FirstTs    time.Duration
@@ -32,39 +46,172 @@ type AVRingReader struct {
 	Frame      *common.AVFrame
 	AbsTime    uint32
 	Delay      uint32
+	Timeshift  time.Duration // 回看时长，在READSTATE_INIT之前设置
+	Offset     time.Duration // 实际回看的时长，受缓存长度和关键帧位置影响
+	UseDVR     bool          // 回看的位置超出内存缓冲时是否从磁盘读取，为false时从缓存的最早关键帧开始
+	dvr        *DVRCursor    // 从磁盘回看时不为nil，读到内存缓冲范围内后切换回Ring
+	Speed      float64       // 播放倍速，AbsTime、PTS、DTS按倍速缩放
+	DropNonRef float64       // 落后的帧数超过环形缓冲大小的该比例时丢弃非参考帧，0为不启用
+	DropNonIDR float64       // 落后的帧数超过环形缓冲大小的该比例时只读取关键帧，0为不启用
+	Drops      DropStats
+	mode       int
 	*log.Logger
 }
 
 func (r *AVRingReader) DecConfChanged() bool {
-	return r.ConfSeq != r.Track.SequenceHeadSeq
+	return r.ConfSeq != r.SequenceHeadSeq()
+}
+
+// SequenceHead 从磁盘读取时为当前帧对应的序列头
+func (r *AVRingReader) SequenceHead() []byte {
+	if r.dvr != nil {
+		return r.dvr.SequenceHead
+	}
+	return r.Track.SequenceHead
+}
+
+func (r *AVRingReader) SequenceHeadSeq() int {
+	if r.dvr != nil {
+		return r.dvr.SequenceHeadSeq
+	}
+	return r.Track.SequenceHeadSeq
+}
+
+// CloseDVR 订阅者停止时关闭正在读取的分段文件
+func (r *AVRingReader) CloseDVR() {
+	if r.dvr != nil {
+		r.dvr.Close()
+		r.dvr = nil
+	}
+}
+
+// readDVR 读取磁盘中的下一帧，内存缓冲中已经有下一帧时切换到Ring继续读取
+func (r *AVRingReader) readDVR() *common.AVFrame {
+	if r.Frame != nil {
+		if ring := r.Track.ringFrom(r.Frame.Sequence + 1); ring != nil {
+			r.Info("dvr to ring", zap.Uint32("seq", r.Frame.Sequence+1))
+			r.CloseDVR()
+			r.Ring = ring
+			return r.ReadFrame()
+		}
+	}
+	frame, err := r.dvr.Next()
+	if err != nil {
+		// 读到正在写入的位置或者分段已经失效，从内存缓冲中最接近的位置继续
+		ring := r.Track.HistoryRing
+		if r.Frame != nil {
+			if next := r.Track.ringFrom(r.Frame.Sequence + 1); next != nil {
+				ring = next
+			}
+		}
+		if ring == nil {
+			ring = r.Track.IDRing
+		}
+		if ring == nil {
+			ring = r.Track.Ring
+		}
+		r.Warn("dvr read end", zap.Error(err))
+		r.CloseDVR()
+		r.Ring = ring
+		return r.ReadFrame()
+	}
+	r.Frame = frame
+	return frame
 }
 
+// NewAVRingReader poll已不再使用，读取时等待轨道写入的通知，保留参数以兼容原有的调用
 func NewAVRingReader(t *Media, poll time.Duration) *AVRingReader {
-	r := &AVRingReader{
+	return &AVRingReader{
 		Track: t,
+		Speed: 1,
 	}
-	if poll == 0 {
-		r.wait = runtime.Gosched
-	} else {
-		r.wait = func() {
-			time.Sleep(poll)
-		}
+}
+
+// wait 阻塞到轨道写入下一帧或者ctx结束，先取得通知再检查CanRead，在两者之间写入时不会错过
+func (r *AVRingReader) wait() {
+	signal := r.Track.signal.Wait()
+	if r.Frame.CanRead {
+		return
+	}
+	select {
+	case <-signal:
+	case <-r.ctx.Done():
 	}
-	return r
 }
 
 func (r *AVRingReader) ReadFrame() *common.AVFrame {
 	for r.Frame = &r.Value; r.ctx.Err() == nil && !r.Frame.CanRead; r.wait() {
 	}
+	// 回看时落后于缓存的最早关键帧才需要丢帧，从最早的关键帧继续
+	if r.State == READSTATE_NORMAL && r.Timeshift > 0 {
+		if history := r.Track.HistoryRing; history != nil && history.Value.Sequence > r.Frame.Sequence {
+			r.Warn("timeshift reader too slow", zap.Uint32("historySeq", history.Value.Sequence), zap.Uint32("seq", r.Frame.Sequence))
+			r.jumped(history)
+			r.Ring = history
+			return r.ReadFrame()
+		}
+		return r.Frame
+	}
 	// 超过一半的缓冲区大小，说明Reader太慢，需要丢帧
 	if r.State == READSTATE_NORMAL && r.Track.LastValue.Sequence-r.Frame.Sequence > uint32(r.Track.Size/2) && r.Track.IDRing.Value.Sequence > r.Frame.Sequence {
 		r.Warn("reader too slow", zap.Uint32("lastSeq", r.Track.LastValue.Sequence), zap.Uint32("seq", r.Frame.Sequence))
+		r.jumped(r.Track.IDRing)
 		r.Ring = r.Track.IDRing
 		return r.ReadFrame()
 	}
 	return r.Frame
 }
 
+func (r *AVRingReader) jumped(to *util.Ring[common.AVFrame]) {
+	r.Drops.Jump++
+	r.Drops.JumpFrames += int(to.Value.Sequence - r.Frame.Sequence)
+	r.Drops.Level = DROPLEVEL_NONE
+}
+
+// skip 只用于实时模式（mode为0），先丢弃非参考帧，更落后时只读取关键帧，追上后逐级恢复，只读取关键帧之后必须从关键帧恢复
+// 不追赶的模式（例如录制）和回看不丢帧，落后超过环形缓冲的一半时仍然直接跳帧
+func (r *AVRingReader) skip() bool {
+	frame := r.Frame
+	if r.DropNonRef <= 0 && r.DropNonIDR <= 0 || r.mode != 0 || r.Timeshift > 0 || !frame.CanRead {
+		return false
+	}
+	lag := float64(r.Track.LastValue.Sequence-frame.Sequence) / float64(r.Track.Size)
+	low := r.DropNonRef
+	if low <= 0 {
+		low = r.DropNonIDR
+	}
+	level := r.Drops.Level
+	switch {
+	case r.DropNonIDR > 0 && lag > r.DropNonIDR:
+		level = DROPLEVEL_NONIDR
+	case level == DROPLEVEL_NONIDR && !frame.IFrame:
+	case r.DropNonRef > 0 && lag > r.DropNonRef:
+		level = DROPLEVEL_NONREF
+	case lag < low/2:
+		level = DROPLEVEL_NONE
+	case level == DROPLEVEL_NONIDR && r.DropNonRef > 0:
+		// 已经追上一部分，从关键帧开始只丢弃非参考帧
+		level = DROPLEVEL_NONREF
+	}
+	if level != r.Drops.Level {
+		r.Info("drop level", zap.Uint8("from", r.Drops.Level), zap.Uint8("to", level), zap.Float64("lag", lag))
+		r.Drops.Level = level
+	}
+	switch level {
+	case DROPLEVEL_NONREF:
+		if frame.Droppable {
+			r.Drops.NonRef++
+			return true
+		}
+	case DROPLEVEL_NONIDR:
+		if !frame.IFrame {
+			r.Drops.NonIDR++
+			return true
+		}
+	}
+	return false
+}
+
 func (r *AVRingReader) TryRead() (item *common.AVFrame) {
 	if item = &r.Value; item.CanRead {
 		return
@@ -81,29 +228,54 @@ func (r *AVRingReader) Read(ctx context.Context, mode int) (err error) {
 	switch r.State {
 	case READSTATE_INIT:
 		r.Info("start read", zap.Int("mode", mode))
+		r.mode = mode
 		startRing := r.Track.Ring
 		if r.Track.IDRing != nil {
 			startRing = r.Track.IDRing
 		} else {
 			r.Warn("no IDRring")
 		}
-		switch mode {
-		case 0:
+		switch {
+		case r.Timeshift > 0:
+			// 音频的FirstTs为视频的起始帧时间戳，从同一位置开始
+			target := r.FirstTs
+			if target == 0 {
+				target = r.Track.LastValue.Timestamp - r.Timeshift
+			}
+			ring := r.Track.TimeshiftRing(target)
+			// 内存缓冲中没有目标位置时从磁盘开始读
+			if dvr := r.Track.GetDVR(); r.UseDVR && dvr != nil && (ring == nil || ring.Value.Timestamp > target) {
+				if r.dvr = dvr.Seek(target); r.dvr != nil && ring != nil && r.dvr.Timestamp >= ring.Value.Timestamp {
+					r.CloseDVR()
+				}
+			}
+			if ring != nil {
+				startRing = ring
+			} else if r.dvr == nil {
+				r.Warn("no timeshift buffer")
+			}
+			r.State = READSTATE_NORMAL
+		case mode == 0:
 			if r.Track.IDRing != nil {
 				r.State = READSTATE_FIRST
 			} else {
 				r.State = READSTATE_NORMAL
 			}
-		case 1:
+		case mode == 1:
 			r.State = READSTATE_NORMAL
-		case 2:
+		case mode == 2:
 			if r.Track.HistoryRing != nil {
 				startRing = r.Track.HistoryRing
 			}
 			r.State = READSTATE_NORMAL
 		}
 		r.Ring = startRing
-		r.ReadFrame()
+		if r.dvr != nil {
+			r.Info("read from dvr", zap.Duration("ts", r.dvr.Timestamp))
+			r.readDVR()
+		} else {
+			r.ReadFrame()
+		}
 		if err = r.ctx.Err(); err != nil {
 			return
 		}
@@ -112,8 +284,15 @@ func (r *AVRingReader) Read(ctx context.Context, mode int) (err error) {
 			r.FirstTs = r.Frame.Timestamp
 		}
 		r.SkipTs = r.FirstTs
+		if r.AbsTime > 0 {
+			// Seek之后输出的时间戳接着上一帧，保持递增
+			r.SkipTs -= time.Duration(float64(r.AbsTime+1)*r.Speed) * time.Millisecond
+		}
 		r.FirstSeq = r.Frame.Sequence
-		r.Info("first frame read", zap.Duration("firstTs", r.FirstTs), zap.Uint32("firstSeq", r.FirstSeq))
+		if r.Timeshift > 0 {
+			r.Offset = r.Track.LastValue.Timestamp - r.Frame.Timestamp
+		}
+		r.Info("first frame read", zap.Duration("firstTs", r.FirstTs), zap.Uint32("firstSeq", r.FirstSeq), zap.Duration("offset", r.Offset))
 	case READSTATE_FIRST:
 		if r.Track.IDRing.Value.Sequence != r.FirstSeq {
 			r.Ring = r.Track.IDRing
@@ -134,10 +313,17 @@ func (r *AVRingReader) Read(ctx context.Context, mode int) (err error) {
 			}
 		}
 	case READSTATE_NORMAL:
-		r.MoveNext()
-		r.ReadFrame()
+		if r.dvr != nil {
+			r.readDVR()
+		} else {
+			r.MoveNext()
+			for r.ReadFrame(); r.skip(); r.ReadFrame() {
+				r.MoveNext()
+			}
+		}
+		r.throttle()
 	}
-	r.AbsTime = uint32((r.Frame.Timestamp - r.SkipTs).Milliseconds())
+	r.AbsTime = uint32(r.scale(r.Frame.Timestamp - r.SkipTs).Milliseconds())
 	if r.AbsTime == 0 {
 		r.AbsTime = 1
 	}
@@ -147,10 +333,60 @@ func (r *AVRingReader) Read(ctx context.Context, mode int) (err error) {
 	return
 }
 func (r *AVRingReader) GetPTS32() uint32 {
-	return uint32((r.Frame.PTS - r.SkipTs*90/time.Millisecond))
+	return uint32(r.scale(r.Frame.PTS - r.SkipTs*90/time.Millisecond))
 }
 func (r *AVRingReader) GetDTS32() uint32 {
-	return uint32((r.Frame.DTS - r.SkipTs*90/time.Millisecond))
+	return uint32(r.scale(r.Frame.DTS - r.SkipTs*90/time.Millisecond))
+}
+
+// throttle 回看时与READSTATE_FIRST一样按墙上时间控制读取速度，否则磁盘和内存中的历史帧会被一次性读完
+// 超前或者落后超过1秒（时间戳跳变、暂停、修改倍速、消费过慢）时重新开始计时
+func (r *AVRingReader) throttle() {
+	if r.Timeshift <= 0 || r.ctx.Err() != nil {
+		return
+	}
+	elapsed := r.scale(r.Frame.Timestamp - r.FirstTs)
+	fast := elapsed - time.Since(r.startTime)
+	if fast > time.Second || fast < -time.Second {
+		r.startTime = time.Now().Add(-elapsed)
+		return
+	}
+	if fast > 0 {
+		select {
+		case <-time.After(fast):
+		case <-r.ctx.Done():
+		}
+	}
+}
+
+func (r *AVRingReader) scale(d time.Duration) time.Duration {
+	if r.Speed == 1 || r.Speed == 0 {
+		return d
+	}
+	return time.Duration(float64(d) / r.Speed)
+}
+
+// SetSpeed 修改倍速，调整SkipTs使当前帧的AbsTime不变，之后的时间戳按新的倍速增长
+func (r *AVRingReader) SetSpeed(speed float64) {
+	if r.State != READSTATE_INIT && r.Frame != nil {
+		r.SkipTs = r.Frame.Timestamp - time.Duration(float64(r.AbsTime)*speed)*time.Millisecond
+	}
+	r.Speed = speed
+	if r.State != READSTATE_INIT && r.Frame != nil {
+		// 回看时按新的倍速重新开始计时
+		r.startTime = time.Now().Add(-r.scale(r.Frame.Timestamp - r.FirstTs))
+	}
+}
+
+// Seek 下一次Read时从时间戳ts之前最近的关键帧开始读取，内存缓冲中没有时从磁盘回看中读取（UseDVR为true时），ts超过最新的帧时从最近的关键帧开始
+func (r *AVRingReader) Seek(ts time.Duration) {
+	r.CloseDVR()
+	r.Timeshift = r.Track.LastValue.Timestamp - ts
+	if r.Timeshift < 0 {
+		r.Timeshift = 0
+	}
+	r.FirstTs = 0
+	r.State = READSTATE_INIT
 }
 func (r *AVRingReader) ResetAbsTime() {
 	r.SkipTs = r.Frame.Timestamp
@@ -0,0 +1,147 @@
+//go:build !windows
+
+package track
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/config"
+	"m7s.live/engine/v4/log"
+)
+
+type benchStream struct {
+	common.IStream
+	config.Publish
+}
+
+func (s *benchStream) With(fields ...zap.Field) *log.Logger {
+	return &log.Logger{Logger: zap.NewNop()}
+}
+
+func (s *benchStream) GetPublisherConfig() *config.Publish {
+	return &s.Publish
+}
+
+func cpuTime() time.Duration {
+	var usage syscall.Rusage
+	syscall.Getrusage(syscall.RUSAGE_SELF, &usage)
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// BenchmarkAVRingReader 5000个订阅者读取同一个轨道，按40ms一帧写入
+// signal为等待写入通知，poll为原来按Subscribe.Poll（默认20ms）轮询CanRead
+// 输出每帧的CPU时间（cpu-ms/frame）和从写入到订阅者读到的平均延迟（latency-ms）
+func BenchmarkAVRingReader(b *testing.B) {
+	const readers, interval = 5000, 40 * time.Millisecond
+	readFrame := map[string]func(*AVRingReader){
+		"signal": func(r *AVRingReader) {
+			r.ReadFrame()
+		},
+		"poll": func(r *AVRingReader) {
+			for r.Frame = &r.Value; r.ctx.Err() == nil && !r.Frame.CanRead; time.Sleep(20 * time.Millisecond) {
+			}
+		},
+	}
+	for _, name := range []string{"signal", "poll"} {
+		read := readFrame[name]
+		b.Run(name, func(b *testing.B) {
+			var media Media
+			media.SetStuff("bench", &benchStream{})
+			media.RingBuffer.Init(256)
+			ctx, cancel := context.WithCancel(context.Background())
+			var wg sync.WaitGroup
+			var latency, count atomic.Int64
+			for i := 0; i < readers; i++ {
+				r := NewAVRingReader(&media, 0)
+				r.ctx = ctx
+				r.Ring = media.Ring
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for read(r); ctx.Err() == nil; read(r) {
+						latency.Add(int64(time.Since(r.Frame.WriteTime)))
+						count.Add(1)
+						r.MoveNext()
+					}
+				}()
+			}
+			time.Sleep(interval)
+			b.ResetTimer()
+			start := cpuTime()
+			for i := 0; i < b.N; i++ {
+				media.Value.Timestamp = time.Duration(i+1) * interval
+				media.Flush()
+				time.Sleep(interval)
+			}
+			b.StopTimer()
+			cpu := cpuTime() - start
+			cancel()
+			wg.Wait()
+			b.ReportMetric(float64(cpu.Milliseconds())/float64(b.N), "cpu-ms/frame")
+			if n := count.Load(); n > 0 {
+				b.ReportMetric(float64(latency.Load())/float64(n)/float64(time.Millisecond), "latency-ms")
+			}
+		})
+	}
+}
+
+func newTestReader(ctx context.Context) (*Media, *AVRingReader) {
+	var media Media
+	media.SetStuff("test", &benchStream{})
+	media.RingBuffer.Init(16)
+	r := NewAVRingReader(&media, 0)
+	r.ctx = ctx
+	r.Ring = media.Ring
+	return &media, r
+}
+
+// 写入的帧的CanRead没有同步，等待的帧与写入的帧分开，只检查Flush的通知能唤醒wait
+func TestAVRingReaderWakeOnFlush(t *testing.T) {
+	media, r := newTestReader(context.Background())
+	r.Frame = &common.AVFrame{}
+	done := make(chan struct{})
+	go func() {
+		r.wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("wait returned before Flush")
+	case <-time.After(50 * time.Millisecond):
+	}
+	media.Value.Timestamp = time.Second
+	media.Flush()
+	select {
+	case <-done:
+		if frame := media.LastValue; !frame.CanRead || frame.Timestamp != time.Second {
+			t.Errorf("frame = %+v", frame)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("wait not woken by Flush")
+	}
+}
+
+func TestAVRingReaderWaitCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, r := newTestReader(ctx)
+	r.Frame = &r.Value
+	done := make(chan struct{})
+	go func() {
+		r.wait()
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("wait not returned after ctx cancelled")
+	}
+}
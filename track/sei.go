@@ -0,0 +1,81 @@
+package track
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/util"
+)
+
+// SEI 发布到sei数据轨道上的值，Value为codec.SEIPicTiming、codec.SEIUserDataRegistered、codec.SEIUserDataUnregistered之一
+type SEI struct {
+	PTS   time.Duration
+	DTS   time.Duration
+	Type  codec.SEIPayloadType
+	Value any
+}
+
+func (vt *Video) isSEI(b0 byte) bool {
+	switch vt.CodecID {
+	case codec.CodecID_H264:
+		return codec.ParseH264NALUType(b0) == codec.NALU_SEI
+	case codec.CodecID_H265:
+		t := codec.ParseH265NALUType(b0)
+		return t == codec.NAL_UNIT_SEI || t == codec.NAL_UNIT_SEI_SUFFIX
+	}
+	return false
+}
+
+// parseSEI 解析当前帧中所有的SEI NALU
+func (vt *Video) parseSEI(rv *AVFrame) (result []*SEI) {
+	hevc := vt.CodecID == codec.CodecID_H265
+	rv.AUList.Range(func(au *util.BLL) bool {
+		if au.ByteLength == 0 || !vt.isSEI(au.GetByte(0)) {
+			return true
+		}
+		msgs, err := codec.ParseSEI(au.ToBytes(), hevc)
+		if err != nil {
+			vt.Warn("sei parse error", zap.Error(err))
+		}
+		for _, msg := range msgs {
+			sei := &SEI{Type: msg.PayloadType}
+			switch msg.PayloadType {
+			case codec.SEI_PIC_TIMING:
+				if hevc {
+					sei.Value, err = codec.ParseH265PicTiming(msg.Payload, vt.PicTiming)
+				} else {
+					sei.Value, err = codec.ParseH264PicTiming(msg.Payload, vt.PicTiming)
+				}
+			case codec.SEI_USER_DATA_REGISTERED_ITU_T_T35:
+				sei.Value, err = codec.ParseSEIUserDataRegistered(msg.Payload)
+			case codec.SEI_USER_DATA_UNREGISTERED:
+				sei.Value, err = codec.ParseSEIUserDataUnregistered(msg.Payload)
+			default:
+				continue
+			}
+			if err != nil {
+				vt.Warn("sei message parse error", zap.Uint("type", uint(msg.PayloadType)), zap.Error(err))
+				continue
+			}
+			result = append(result, sei)
+		}
+		return true
+	})
+	return
+}
+
+// pushSEI 带上刚写入的帧的时间戳，发布到sei数据轨道，第一次发布时创建轨道
+func (vt *Video) pushSEI(seis []*SEI) {
+	if vt.seiTrack == nil {
+		vt.seiTrack = &Data{}
+		vt.seiTrack.Init(10)
+		vt.seiTrack.SetStuff("sei", vt.Stream)
+		vt.seiTrack.Attach()
+	}
+	for _, sei := range seis {
+		sei.PTS, sei.DTS = vt.LastValue.PTS, vt.LastValue.DTS
+		vt.seiTrack.Push(sei)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"m7s.live/engine/v4/codec"
 	"m7s.live/engine/v4/common"
 	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/config"
 	"m7s.live/engine/v4/util"
 )
 
@@ -24,8 +25,13 @@ type Video struct {
 	lostFlag    bool // 是否丢帧
 	codec.SPSInfo
 	ParamaterSets `json:"-" yaml:"-"`
-	SPS           []byte `json:"-" yaml:"-"`
-	PPS           []byte `json:"-" yaml:"-"`
+	SPS           []byte            `json:"-" yaml:"-"`
+	PPS           []byte            `json:"-" yaml:"-"`
+	seiTrack      *Data             // 解析出的SEI发布到该数据轨道
+	caption       *captionExtractor // 从SEI中提取字幕
+	seiConf       *config.Publish   // 发布者的配置，设置发布者时缓存，避免每帧从流中读取
+	params        paramsWatcher     // 序列头变化时比较解码参数
+	Codecs        string            // RFC 6381 编码字符串，序列化json时更新
 }
 
 func (v *Video) Attach() {
@@ -41,9 +47,41 @@ func (v *Video) Attach() {
 func (v *Video) Detach() {
 	if v.Attached.CompareAndSwap(true, false) {
 		v.Stream.RemoveTrack(v)
+		// 从SEI中解析出的数据轨道随视频轨道一起移除，再次写入时重新创建
+		if v.seiTrack != nil {
+			v.Stream.RemoveTrack(v.seiTrack)
+			v.seiTrack = nil
+		}
+		if v.caption != nil && v.caption.track != nil {
+			v.Stream.RemoveTrack(v.caption.track)
+		}
+		v.caption = nil
+	}
+}
+
+// SetStuff 传入发布者或者发布配置时缓存SEI相关的开关
+func (vt *Video) SetStuff(stuff ...any) {
+	vt.Media.SetStuff(stuff...)
+	for _, s := range stuff {
+		switch v := s.(type) {
+		case TrackOwner:
+			vt.seiConf = v.PublishConfig()
+		case *config.Publish:
+			vt.seiConf = v
+		}
 	}
 }
 
+// CodecString RFC 6381 编码字符串，例如avc1.640028、hvc1.1.6.L120.90
+func (vt *Video) CodecString() string {
+	return codec.VideoCodecString(vt.CodecID, &vt.SPSInfo)
+}
+
+func (vt *Video) SnapForJson() {
+	vt.Codecs = vt.CodecString()
+	vt.Media.SnapForJson()
+}
+
 func (vt *Video) GetName() string {
 	if vt.Name == "" {
 		return vt.CodecID.String()
@@ -260,8 +298,54 @@ func (vt *Video) Flush() {
 			return
 		}
 	}
+	var seis []*SEI
+	if vt.seiConf == nil {
+		// 没有传入发布者创建的track
+		vt.seiConf = vt.Stream.GetPublisherConfig()
+	}
+	conf := vt.seiConf
+	if conf.ParseSEI || conf.ParseCaption {
+		seis = vt.parseSEI(rv)
+	}
+	vt.checkParams()
+	rv.Droppable = vt.isDroppable(rv)
 	vt.Media.Flush()
 	vt.dcChanged = false
+	if conf.ParseSEI && len(seis) > 0 {
+		vt.pushSEI(seis)
+	}
+	if conf.ParseCaption {
+		vt.pushCaption(seis)
+	}
+}
+
+// isDroppable 根据第一个VCL NALU判断，B帧通常是非参考帧，作为参考的B帧（B-pyramid）丢弃后会影响其他帧，不认为可以丢弃
+func (vt *Video) isDroppable(rv *AVFrame) (droppable bool) {
+	if rv.IFrame {
+		return false
+	}
+	rv.AUList.Range(func(au *util.BLL) bool {
+		if au.ByteLength == 0 {
+			return true
+		}
+		b0 := au.GetByte(0)
+		switch vt.CodecID {
+		case codec.CodecID_H264:
+			if t := codec.ParseH264NALUType(b0); t >= codec.NALU_Non_IDR_Picture && t <= codec.NALU_IDR_Picture {
+				droppable = codec.ParseH264NALURefIdc(b0) == 0
+				return false
+			}
+		case codec.CodecID_H265:
+			if t := codec.ParseH265NALUType(b0); t < codec.NAL_UNIT_VPS {
+				droppable = t.IsSubLayerNonRef()
+				return false
+			}
+		default:
+			return false
+		}
+		return true
+	})
+	return
 }
 
 func (vt *Video) WriteSequenceHead(sh []byte) {
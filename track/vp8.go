@@ -0,0 +1,114 @@
+package track
+
+import (
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/util"
+)
+
+var _ SpesificTrack = (*VP8)(nil)
+
+type VP8 struct {
+	vpx
+}
+
+func NewVP8(stream IStream, stuff ...any) (vt *VP8) {
+	vt = &VP8{}
+	vt.Video.CodecID = codec.CodecID_VP8
+	vt.fourCC = codec.FourCC_VP8
+	vt.SetStuff("vp8", int(256), byte(96), uint32(90000), stream, vt, time.Millisecond*10)
+	vt.SetStuff(stuff...)
+	if vt.BytesPool == nil {
+		vt.BytesPool = make(util.BytesPool, 17)
+	}
+	vt.dtsEst = NewDTSEstimator()
+	return
+}
+
+// WriteSliceBytes 写入完整的一帧
+func (vt *VP8) WriteSliceBytes(slice []byte) {
+	vt.writeFrame(net.Buffers{slice})
+}
+
+func (vt *VP8) writeFrame(frame net.Buffers) {
+	h, err := codec.ParseVP8FrameHeader(frameHead(frame))
+	if err != nil {
+		vt.Warn("vp8 frame header error", zap.Error(err))
+		return
+	}
+	if h.KeyFrame {
+		vt.setConfig(codec.VPCodecConfigurationRecord{
+			Profile:                 h.Version,
+			BitDepth:                8,
+			ChromaSubsampling:       1,
+			ColourPrimaries:         2,
+			TransferCharacteristics: 2,
+			MatrixCoefficients:      2,
+		}, h.Width, h.Height)
+	}
+	vt.startFrame(h.KeyFrame, frame...)
+}
+
+func (vt *VP8) WriteAVCC(ts uint32, frame *util.BLL) error {
+	return vt.writeAVCC(ts, frame, vt.writeFrame)
+}
+
+// WriteRTPFrame VP8 RTP 解包 https://datatracker.ietf.org/doc/html/rfc7741#section-4.2
+func (vt *VP8) WriteRTPFrame(frame *RTPFrame) {
+	buffer := util.Buffer(frame.Payload)
+	// X|R|N|S|R|PID
+	var desc, ext byte
+	if buffer.CanRead() {
+		desc = buffer.ReadByte()
+	}
+	if desc&0x80 != 0 && buffer.CanRead() {
+		// I|L|T|K|RSV
+		ext = buffer.ReadByte()
+	}
+	skip := 0
+	if ext&0x80 != 0 && buffer.CanRead() {
+		// PictureID，M为1时为15位
+		if skip = 1; buffer[0]&0x80 != 0 {
+			skip = 2
+		}
+	}
+	if ext&0x40 != 0 {
+		skip++ // TL0PICIDX
+	}
+	if ext&0x30 != 0 {
+		skip++ // TID|Y|KEYIDX
+	}
+	if !buffer.CanReadN(skip) {
+		vt.Warn("vp8 payload descriptor error", zap.Uint16("seq", frame.SequenceNumber))
+		return
+	}
+	buffer.ReadN(skip)
+	if start := desc&0x10 != 0 && desc&0x07 == 0; start && buffer.CanRead() {
+		vt.writeFrame(net.Buffers{buffer})
+	} else {
+		vt.appendFrame(buffer)
+	}
+	if frame.Marker {
+		vt.flushRTP(frame)
+	}
+}
+
+// CompleteRTP 带上15位的PictureID，方便WebRTC接收端判断丢帧
+func (vt *VP8) CompleteRTP(value *AVFrame) {
+	vt.pictureID = (vt.pictureID + 1) & 0x7FFF
+	var out [][][]byte
+	chunks := util.SplitBuffers(value.AUList.ToBuffers(), RTPMTU-4)
+	for i, chunk := range chunks {
+		// X=1 I=1 M=1
+		desc := []byte{0x80, 0x80, 0x80 | byte(vt.pictureID>>8), byte(vt.pictureID)}
+		if i == 0 {
+			desc[0] |= 0x10 // S 帧的开始，PID为0
+		}
+		out = append(out, append([][]byte{desc}, chunk...))
+	}
+	vt.PacketizeRTP(out...)
+}
@@ -0,0 +1,153 @@
+package track
+
+import (
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/util"
+)
+
+var _ SpesificTrack = (*VP9)(nil)
+
+type VP9 struct {
+	vpx
+}
+
+func NewVP9(stream IStream, stuff ...any) (vt *VP9) {
+	vt = &VP9{}
+	vt.Video.CodecID = codec.CodecID_VP9
+	vt.fourCC = codec.FourCC_VP9
+	vt.SetStuff("vp9", int(256), byte(96), uint32(90000), stream, vt, time.Millisecond*10)
+	vt.SetStuff(stuff...)
+	if vt.BytesPool == nil {
+		vt.BytesPool = make(util.BytesPool, 17)
+	}
+	vt.dtsEst = NewDTSEstimator()
+	return
+}
+
+// WriteSliceBytes 写入完整的一帧（或超级帧）
+func (vt *VP9) WriteSliceBytes(slice []byte) {
+	vt.writeFrame(net.Buffers{slice})
+}
+
+func (vt *VP9) writeFrame(frame net.Buffers) {
+	h, err := codec.ParseVP9FrameHeader(frameHead(frame))
+	if err != nil {
+		vt.Warn("vp9 frame header error", zap.Error(err))
+		return
+	}
+	if h.KeyFrame {
+		vt.setConfig(h.VPCC(), h.Width, h.Height)
+	}
+	vt.startFrame(h.KeyFrame, frame...)
+}
+
+func (vt *VP9) WriteAVCC(ts uint32, frame *util.BLL) error {
+	return vt.writeAVCC(ts, frame, vt.writeFrame)
+}
+
+// parseVP9Descriptor 解析VP9 RTP负载描述符，返回描述符的第一个字节和帧数据
+func parseVP9Descriptor(payload []byte) (desc byte, data util.Buffer, ok bool) {
+	data = payload
+	read := func(n int) (b util.Buffer) {
+		if ok = ok && data.CanReadN(n); ok {
+			b = data.ReadN(n)
+		}
+		return
+	}
+	ok = true
+	// I|P|L|F|B|E|V|Z
+	if b := read(1); ok {
+		desc = b[0]
+	}
+	if desc&0x80 != 0 {
+		// PictureID，M为1时为15位
+		if b := read(1); ok && b[0]&0x80 != 0 {
+			read(1)
+		}
+	}
+	if desc&0x20 != 0 {
+		// TID|U|SID|D 非flexible模式还有TL0PICIDX
+		if read(1); desc&0x10 == 0 {
+			read(1)
+		}
+	}
+	if desc&0x50 == 0x50 {
+		// flexible模式下的P_DIFF，N为1时后面还有，最多3个
+		for i := 0; i < 3; i++ {
+			if b := read(1); !ok || b[0]&1 == 0 {
+				break
+			}
+		}
+	}
+	if desc&0x02 != 0 {
+		// SS: N_S|Y|G|RSV
+		var ss byte
+		if b := read(1); ok {
+			ss = b[0]
+		}
+		if ss&0x10 != 0 {
+			read(4 * (int(ss>>5) + 1)) // WIDTH HEIGHT
+		}
+		if ss&0x08 != 0 {
+			var ng byte
+			if b := read(1); ok {
+				ng = b[0]
+			}
+			for ; ng > 0 && ok; ng-- {
+				// TID|U|R|RSV，后面有R个P_DIFF
+				if b := read(1); ok {
+					read(int(b[0]>>2) & 0b11)
+				}
+			}
+		}
+	}
+	return
+}
+
+// WriteRTPFrame VP9 RTP 解包 https://datatracker.ietf.org/doc/html/rfc9628#section-4.2
+func (vt *VP9) WriteRTPFrame(frame *RTPFrame) {
+	desc, data, ok := parseVP9Descriptor(frame.Payload)
+	if !ok {
+		vt.Warn("vp9 payload descriptor error", zap.Uint16("seq", frame.SequenceNumber))
+		return
+	}
+	if start := desc&0x08 != 0; start && data.CanRead() {
+		vt.writeFrame(net.Buffers{data})
+	} else {
+		vt.appendFrame(data)
+	}
+	if frame.Marker {
+		vt.flushRTP(frame)
+	}
+}
+
+// CompleteRTP 非flexible模式，带上15位的PictureID，关键帧带上SS说明分辨率
+func (vt *VP9) CompleteRTP(value *AVFrame) {
+	vt.pictureID = (vt.pictureID + 1) & 0x7FFF
+	var out [][][]byte
+	chunks := util.SplitBuffers(value.AUList.ToBuffers(), RTPMTU-8)
+	for i, chunk := range chunks {
+		// I=1 M=1
+		desc := []byte{0x80, 0x80 | byte(vt.pictureID>>8), byte(vt.pictureID)}
+		if !value.IFrame {
+			desc[0] |= 0x40 // P 帧间预测
+		}
+		if i == 0 {
+			desc[0] |= 0x08 // B 帧的开始
+		}
+		if i == len(chunks)-1 {
+			desc[0] |= 0x04 // E 帧的结束
+		}
+		if i == 0 && value.IFrame {
+			desc[0] |= 0x02 // V N_S=0 Y=1 G=0
+			desc = append(desc, 0x10, byte(vt.Width>>8), byte(vt.Width), byte(vt.Height>>8), byte(vt.Height))
+		}
+		out = append(out, append([][]byte{desc}, chunk...))
+	}
+	vt.PacketizeRTP(out...)
+}
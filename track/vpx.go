@@ -0,0 +1,150 @@
+package track
+
+import (
+	"io"
+	"net"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/codec"
+	. "m7s.live/engine/v4/common"
+	"m7s.live/engine/v4/util"
+)
+
+// vpx VP8和VP9共用的部分，每一帧为一个AU，SequenceHead为扩展头格式的视频Tag：1字节头+FourCC+vpcC
+type vpx struct {
+	Video
+	VPCC      codec.VPCodecConfigurationRecord
+	fourCC    []byte
+	pictureID uint16 // 打包RTP时使用的PictureID
+}
+
+// frameHead 返回帧开头的数据用于解析帧头，第一个切片太短时合并
+func frameHead(frame net.Buffers) []byte {
+	if len(frame[0]) < 32 && len(frame) > 1 {
+		return util.ConcatBuffers(frame)
+	}
+	return frame[0]
+}
+
+// startFrame 写入一帧的开始部分
+func (vt *vpx) startFrame(key bool, frame ...[]byte) {
+	rv := &vt.Value
+	if rv.AUList.Length == 0 {
+		rv.IFrame = false
+	}
+	rv.IFrame = rv.IFrame || key
+	vt.AppendAuBytes(frame...)
+}
+
+// appendFrame 写入RTP中一帧的后续部分，帧的开始部分丢失时丢弃
+func (vt *vpx) appendFrame(b []byte) {
+	if vt.Value.AUList.Length > 0 && len(b) > 0 {
+		vt.Value.AUList.Push(vt.BytesPool.GetShell(b))
+	}
+}
+
+// flushRTP 收到Marker后写入一帧，没有数据（开始部分丢失）时丢弃
+func (vt *vpx) flushRTP(frame *RTPFrame) {
+	if vt.Value.AUList.Length == 0 {
+		vt.Value.Reset()
+		return
+	}
+	vt.generateTimestamp(frame.Timestamp)
+	vt.Flush()
+}
+
+// setConfig 关键帧中的配置或者分辨率改变时写入新的SequenceHead
+func (vt *vpx) setConfig(vpcc codec.VPCodecConfigurationRecord, width, height uint) {
+	if vt.SequenceHeadSeq > 0 && vpcc == vt.VPCC && width == vt.Width && height == vt.Height {
+		return
+	}
+	vt.Debug("vpcC", zap.Any("vpcc", vpcc), zap.Uint("width", width), zap.Uint("height", height))
+	vt.VPCC = vpcc
+	vt.SPSInfo = codec.SPSInfo{
+		ProfileIdc: uint(vpcc.Profile),
+		LevelIdc:   uint(vpcc.Level),
+		Width:      width,
+		Height:     height,
+		// VP9中亮度和色度的位深相同
+		BitDepthLuma:   uint(vpcc.BitDepth),
+		BitDepthChroma: uint(vpcc.BitDepth),
+	}
+	sh := append([]byte{0x90 | codec.PacketTypeSequenceStart}, vt.fourCC...)
+	vt.WriteSequenceHead(append(sh, vpcc.Marshal()...))
+}
+
+func (vt *vpx) writeSequenceHead(head []byte) (err error) {
+	var vpcc codec.VPCodecConfigurationRecord
+	if len(head) < 5 {
+		err = io.ErrShortBuffer
+	} else {
+		err = vpcc.Parse(head[5:])
+	}
+	if err != nil {
+		vt.Error("vpcC parse error", zap.Error(err))
+		return
+	}
+	vt.WriteSequenceHead(head)
+	vt.VPCC = vpcc
+	vt.ProfileIdc, vt.LevelIdc = uint(vpcc.Profile), uint(vpcc.Level)
+	vt.BitDepthLuma, vt.BitDepthChroma = uint(vpcc.BitDepth), uint(vpcc.BitDepth)
+	return
+}
+
+// writeAVCC 写入 Enhanced RTMP 格式的数据，vp08、vp09没有CTS字段，writeFrame 负责解析帧头
+func (vt *vpx) writeAVCC(ts uint32, frame *util.BLL, writeFrame func(net.Buffers)) (err error) {
+	if l := frame.ByteLength; l < 6 {
+		vt.Error("AVCC data too short", zap.Int("len", l))
+		return io.ErrShortWrite
+	}
+	b0 := frame.GetByte(0)
+	if isExtHeader := b0 & 0b1000_0000; isExtHeader == 0 {
+		vt.Error("vp8/vp9 needs enhanced rtmp header")
+		frame.Recycle()
+		return codec.ErrVPX
+	}
+	switch packetType := b0 & 0b1111; packetType {
+	case codec.PacketTypeSequenceStart:
+		err = vt.writeSequenceHead(frame.ToBytes())
+		frame.Recycle()
+	case codec.PacketTypeCodedFrames, codec.PacketTypeCodedFramesX:
+		r := frame.NewReader()
+		r.Skip(5)
+		writeFrame(r.ReadN(frame.ByteLength - 5))
+		if vt.Value.AUList.Length == 0 {
+			frame.Recycle()
+			return codec.ErrVPX
+		}
+		if frameType := (b0 >> 4) & 0b0111; frameType == 1 {
+			vt.Value.IFrame = true
+		}
+		vt.Value.PTS = time.Duration(ts) * 90
+		vt.Value.DTS = vt.Value.PTS
+		vt.Value.WriteAVCC(ts, frame)
+		vt.Flush()
+	default:
+		frame.Recycle()
+	}
+	return
+}
+
+// CompleteAVCC 补完 Enhanced RTMP 格式
+func (vt *vpx) CompleteAVCC(rv *AVFrame) {
+	mem := vt.BytesPool.Get(5)
+	b := mem.Value
+	if rv.IFrame {
+		b[0] = 0b1001_0000 | codec.PacketTypeCodedFrames
+	} else {
+		b[0] = 0b1010_0000 | codec.PacketTypeCodedFrames
+	}
+	copy(b[1:], vt.fourCC)
+	rv.AVCC.Push(mem)
+	rv.AUList.Range(func(au *util.BLL) bool {
+		au.Range(func(slice util.Buffer) bool {
+			rv.AVCC.Push(vt.BytesPool.GetShell(slice))
+			return true
+		})
+		return true
+	})
+}
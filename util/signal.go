@@ -0,0 +1,29 @@
+package util
+
+import "sync/atomic"
+
+// Signal 广播通知，等待者取得当前的channel，Broadcast时关闭该channel唤醒所有等待者
+// channel在有等待者时才创建，没有等待者时Broadcast只有一次原子操作
+type Signal struct {
+	c atomic.Pointer[chan struct{}]
+}
+
+// Wait 返回下一次Broadcast时关闭的channel，需要先调用Wait再检查等待的条件，否则可能错过两者之间的通知
+func (s *Signal) Wait() <-chan struct{} {
+	for {
+		if c := s.c.Load(); c != nil {
+			return *c
+		}
+		c := make(chan struct{})
+		if s.c.CompareAndSwap(nil, &c) {
+			return c
+		}
+	}
+}
+
+// Broadcast 唤醒所有在Wait返回的channel上等待的协程
+func (s *Signal) Broadcast() {
+	if c := s.c.Swap(nil); c != nil {
+		close(*c)
+	}
+}
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSignal(t *testing.T) {
+	var s Signal
+	// 没有等待者时Broadcast不会创建channel
+	s.Broadcast()
+	c := s.Wait()
+	if c != s.Wait() {
+		t.Error("Wait before Broadcast should return the same channel")
+	}
+	// Broadcast发生在Wait和检查条件之间，之后的等待立即返回
+	s.Broadcast()
+	select {
+	case <-c:
+	default:
+		t.Fatal("channel not closed by Broadcast")
+	}
+	if s.Wait() == c {
+		t.Error("Wait after Broadcast should return a new channel")
+	}
+}
+
+// TestSignalRace 写入者修改条件后Broadcast，等待者先Wait再检查条件，不会错过通知
+func TestSignalRace(t *testing.T) {
+	var s Signal
+	var value atomic.Int64
+	const waiters, rounds = 8, 1000
+	var wg sync.WaitGroup
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for want := int64(1); want <= rounds; want++ {
+				for {
+					c := s.Wait()
+					if value.Load() >= want {
+						break
+					}
+					select {
+					case <-c:
+					case <-time.After(5 * time.Second):
+						t.Errorf("missed broadcast at %d", want)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 0; i < rounds; i++ {
+		value.Add(1)
+		s.Broadcast()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,302 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"m7s.live/engine/v4/config"
+)
+
+// 回调事件名称
+const (
+	WEBHOOK_CREATE           = "create"
+	WEBHOOK_PUBLISH          = "publish"
+	WEBHOOK_REPUBLISH        = "republish"
+	WEBHOOK_PUBLISH_LOST     = "publish_lost"
+	WEBHOOK_SWITCH_PUBLISHER = "switch_publisher"
+	WEBHOOK_CLOSE            = "close"
+	WEBHOOK_MOVE             = "move"
+	WEBHOOK_KICK             = "kick"
+	WEBHOOK_SUBSCRIBE        = "subscribe"
+	WEBHOOK_UNSUBSCRIBE      = "unsubscribe"
+)
+
+const WebhookSignatureHeader = "X-M7S-Signature"
+
+type WebhookIO struct {
+	ID         string
+	Type       string
+	StartTime  time.Time
+	RemoteAddr string `json:",omitempty"`
+	Args       url.Values
+}
+
+// WebhookPayload 回调请求的JSON内容
+type WebhookPayload struct {
+	ID         uint64 // 事件序号，用于接收方去重
+	Event      string
+	Time       time.Time
+	StreamPath string
+	OldPath    string     `json:",omitempty"` // 流被移动前的路径
+	Reason     string     `json:",omitempty"` // 被踢出的原因
+	Publisher  *WebhookIO `json:",omitempty"`
+	Subscriber *WebhookIO `json:",omitempty"`
+}
+
+// WebhookTarget 单个回调地址的投递状态
+type WebhookTarget struct {
+	URL        string
+	Queued     int
+	Sent       uint64
+	Failed     uint64
+	Dropped    uint64 // 队列满被丢弃的事件数
+	LastError  string
+	LastStatus int
+	LastTime   time.Time
+	queue      chan []byte
+	mu         sync.Mutex
+}
+
+func (t *WebhookTarget) report(status int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.LastStatus = status
+	t.LastTime = time.Now()
+	if err != nil {
+		t.Failed++
+		t.LastError = err.Error()
+	} else {
+		t.Sent++
+		t.LastError = ""
+	}
+}
+
+func (t *WebhookTarget) Snapshot() WebhookTarget {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return WebhookTarget{
+		URL:        t.URL,
+		Queued:     len(t.queue),
+		Sent:       t.Sent,
+		Failed:     t.Failed,
+		Dropped:    atomic.LoadUint64(&t.Dropped),
+		LastError:  t.LastError,
+		LastStatus: t.LastStatus,
+		LastTime:   t.LastTime,
+	}
+}
+
+// Webhook 将流状态事件通过HTTP回调通知外部系统，每个回调地址独立队列，不会阻塞EventBus
+type Webhook struct {
+	*config.Webhook
+	targets       []*WebhookTarget
+	events        map[string]struct{}
+	seq           atomic.Uint64
+	client        http.Client
+	retryInterval time.Duration
+}
+
+var webhook Webhook
+
+func (w *Webhook) Start(ctx context.Context, conf *config.Webhook) {
+	w.Webhook = conf
+	w.client.Timeout = conf.Timeout
+	if w.retryInterval = conf.RetryInterval; w.retryInterval <= 0 {
+		Engine.Warn("webhook retryinterval must be positive, use 1s", zap.Duration("retryinterval", conf.RetryInterval))
+		w.retryInterval = time.Second
+	}
+	if len(conf.Events) > 0 {
+		w.events = make(map[string]struct{})
+		for _, e := range conf.Events {
+			w.events[e] = struct{}{}
+		}
+	}
+	queueSize := conf.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	for _, u := range conf.URLList {
+		t := &WebhookTarget{URL: u, queue: make(chan []byte, queueSize)}
+		w.targets = append(w.targets, t)
+		go w.deliver(ctx, t)
+	}
+}
+
+func (w *Webhook) Targets() (r []WebhookTarget) {
+	for _, t := range w.targets {
+		r = append(r, t.Snapshot())
+	}
+	return
+}
+
+func (w *Webhook) accept(event string) bool {
+	if w.events == nil {
+		return true
+	}
+	_, ok := w.events[event]
+	return ok
+}
+
+func webhookIO(io *IO) *WebhookIO {
+	if io == nil {
+		return nil
+	}
+	return &WebhookIO{
+		ID:         io.ID,
+		Type:       io.Type,
+		StartTime:  io.StartTime,
+		RemoteAddr: io.remoteAddr(),
+		Args:       io.Args,
+	}
+}
+
+func publisherIO(pub IPublisher) *WebhookIO {
+	if pub == nil {
+		return nil
+	}
+	return webhookIO(&pub.GetPublisher().IO)
+}
+
+// OnEvent 在EventBus协程中调用，只做序列化和入队
+func (w *Webhook) OnEvent(event any) {
+	if len(w.targets) == 0 {
+		return
+	}
+	var payload WebhookPayload
+	var s *Stream
+	switch v := event.(type) {
+	case SEcreate:
+		payload.Event, s = WEBHOOK_CREATE, v.Target
+	case SEpublish:
+		payload.Event, s = WEBHOOK_PUBLISH, v.Target
+		payload.Publisher = publisherIO(v.Publisher)
+	case SErepublish:
+		payload.Event, s = WEBHOOK_REPUBLISH, v.Target
+		payload.Publisher = publisherIO(v.Publisher)
+	case SEwaitPublish:
+		payload.Event, s = WEBHOOK_PUBLISH_LOST, v.Target
+		payload.Publisher = publisherIO(v.Publisher)
+	case SwitchPublisherEvent:
+		payload.Event, s = WEBHOOK_SWITCH_PUBLISHER, v.Target
+		payload.Publisher = publisherIO(v.New)
+	case SEclose:
+		payload.Event, s = WEBHOOK_CLOSE, v.Target
+	case SEmove:
+		payload.Event, s = WEBHOOK_MOVE, v.Target
+		payload.OldPath = v.OldPath
+	case KickEvent:
+		payload.Event, payload.Reason = WEBHOOK_KICK, v.Reason
+		switch io := v.Target.(type) {
+		case IPublisher:
+			s = io.GetPublisher().Stream
+			payload.Publisher = publisherIO(io)
+		case ISubscriber:
+			sub := io.GetSubscriber()
+			s = sub.Stream
+			payload.Subscriber = webhookIO(&sub.IO)
+		}
+	case ISubscriber:
+		sub := v.GetSubscriber()
+		payload.Event, s = WEBHOOK_SUBSCRIBE, sub.Stream
+		payload.Subscriber = webhookIO(&sub.IO)
+	case UnsubscribeEvent:
+		sub := v.Target.GetSubscriber()
+		payload.Event, s = WEBHOOK_UNSUBSCRIBE, sub.Stream
+		payload.Subscriber = webhookIO(&sub.IO)
+	default:
+		return
+	}
+	if s == nil || !w.accept(payload.Event) {
+		return
+	}
+	payload.ID = w.seq.Add(1)
+	payload.Time = time.Now()
+	payload.StreamPath = s.GetPath()
+	body, err := json.Marshal(&payload)
+	if err != nil {
+		Engine.Error("webhook marshal", zap.Error(err))
+		return
+	}
+	for _, t := range w.targets {
+		select {
+		case t.queue <- body:
+		default:
+			atomic.AddUint64(&t.Dropped, 1)
+			Engine.Warn("webhook queue full", zap.String("url", t.URL), zap.String("event", payload.Event))
+		}
+	}
+}
+
+func (w *Webhook) sign(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(w.Secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Webhook) post(ctx context.Context, target string, body []byte) (status int, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, w.sign(body))
+	}
+	res, err := w.client.Do(req)
+	if err != nil {
+		return
+	}
+	res.Body.Close()
+	status = res.StatusCode
+	if status < 200 || status >= 300 {
+		err = fmt.Errorf("webhook response status %d", status)
+	}
+	return
+}
+
+// send 失败后最多重试RetryTimes次，重试间隔逐次翻倍，等待时ctx结束则立即返回
+func (w *Webhook) send(ctx context.Context, target string, body []byte) (status int, err error) {
+	interval := w.retryInterval
+	for retries := w.RetryTimes; ; interval *= 2 {
+		if status, err = w.post(ctx, target, body); err == nil || ctx.Err() != nil {
+			return
+		}
+		if retries--; retries < 0 {
+			return
+		}
+		// 加入随机抖动，避免多个回调同时重试
+		interval += time.Duration(rand.Int63n(int64(interval))) / 2
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+func (w *Webhook) deliver(ctx context.Context, t *WebhookTarget) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case body := <-t.queue:
+			status, err := w.send(ctx, t.URL, body)
+			if err != nil {
+				Engine.Warn("webhook failed", zap.String("url", t.URL), zap.Error(err))
+			}
+			t.report(status, err)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package engine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"m7s.live/engine/v4/config"
+)
+
+func TestWebhookRetry(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	for _, retryTimes := range []int{0, 2} {
+		requests.Store(0)
+		w := &Webhook{Webhook: &config.Webhook{RetryTimes: retryTimes}, retryInterval: time.Millisecond}
+		if status, err := w.send(context.Background(), server.URL, []byte("{}")); err == nil || status != http.StatusInternalServerError {
+			t.Errorf("retrytimes %d: send = %d, %v", retryTimes, status, err)
+		}
+		// 首次请求加上重试次数
+		if n := requests.Load(); n != int32(retryTimes+1) {
+			t.Errorf("retrytimes %d: %d requests, want %d", retryTimes, n, retryTimes+1)
+		}
+	}
+}